@@ -18,9 +18,13 @@ package v1alpha4
 
 import (
 	"fmt"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/nodeimage"
 )
 
 // KindClusterPhase indicates the current phase of a clusters life
@@ -35,6 +39,8 @@ var (
 	KindClusterPhaseReady KindClusterPhase = "Ready"
 	// KindClusterPhaseDeleting is the phase used when deleting an existing cluster
 	KindClusterPhaseDeleting KindClusterPhase = "Deleting"
+	// KindClusterPhaseSuspended is the phase used when the cluster's nodes have been stopped
+	KindClusterPhaseSuspended KindClusterPhase = "Suspended"
 )
 
 // FailureReason contains machine-readable details of what error occurred
@@ -51,26 +57,98 @@ var (
 	FailureReasonClusterNotFound FailureReason = "ClusterNotFound"
 	// FailureReasonDeleteFailed indicates there was an error while deleting a cluster
 	FailureReasonDeleteFailed FailureReason = "DeleteFailed"
+	// FailureReasonKindConfig indicates there was an error retrieving the raw Kind config for the cluster
+	FailureReasonKindConfig FailureReason = "KindConfigInvalid"
+	// FailureReasonSuspendFailed indicates there was an error stopping the cluster nodes
+	FailureReasonSuspendFailed FailureReason = "SuspendFailed"
+	// FailureReasonResumeFailed indicates there was an error restarting the cluster nodes
+	FailureReasonResumeFailed FailureReason = "ResumeFailed"
+	// FailureReasonSnapshot indicates the snapshot the cluster is restored from couldn't be used
+	FailureReasonSnapshot FailureReason = "SnapshotInvalid"
+)
+
+const (
+	// DefaultImage is the node image used when none is specified
+	DefaultImage = nodeimage.Repository
+	// DefaultVersion is the Kubernetes version used when none is specified
+	DefaultVersion = "v1.21.2"
+	// DefaultReplicas is the number of control plane nodes created when none is specified
+	DefaultReplicas int32 = 1
+	// DefaultWaitForReady is how long Kind waits for the control plane to be ready when none is specified
+	DefaultWaitForReady = 60 * time.Second
+)
+
+const (
+	// OwnerNamespaceLabel identifies the namespace of the KindCluster that owns a Kind cluster
+	OwnerNamespaceLabel = "kindcluster.cluster.x-k8s.io/namespace"
+	// OwnerNameLabel identifies the name of the KindCluster that owns a Kind cluster
+	OwnerNameLabel = "kindcluster.cluster.x-k8s.io/name"
+	// OwnerUIDLabel identifies the UID of the KindCluster that owns a Kind cluster
+	OwnerUIDLabel = "kindcluster.cluster.x-k8s.io/uid"
+	// ManagementClusterLabel identifies the management cluster that created a Kind cluster
+	ManagementClusterLabel = "kindcluster.cluster.x-k8s.io/management-cluster"
+	// VersionLabel is the version of the Kind server that created a Kind cluster
+	VersionLabel = "kindcluster.cluster.x-k8s.io/capk-version"
 )
 
+// CollectLogsAnnotation requests an archive of the cluster's node logs be made on its Kind server, it's removed once
+// the logs have been collected
+const CollectLogsAnnotation = "kindcluster.cluster.x-k8s.io/collect-logs"
+
 // KindClusterSpec defines the desired state of KindCluster
 type KindClusterSpec struct {
+	// Name is the name of the cluster in Kind
+	//
+	// Defaults to the KindCluster name prefixed with the namespace.
+	// +optional
+	Name string `json:"name,omitempty"`
+
 	// Image is the node image used for the cluster nodes
 	//
-	// +kubebuilder:default=kindest/node
+	// Defaults to kindest/node, which is pinned by digest for the supported versions.
+	// +optional
 	Image string `json:"image,omitempty"`
 
 	// Version is the Kubernetes version to use (e.g. v1.21.2)
 	//
-	// +kubebuilder:default=v1.21.2
+	// Defaults to v1.21.2.
 	// +kubebuilder:validation:Pattern=^v\d\.\d+\.\d+$
+	// +optional
 	Version string `json:"version,omitempty"`
 
 	// Replicas controls the number of control plane nodes to create
 	//
-	// +kubebuilder:default=1
+	// Defaults to the total count of control plane nodes, or 1 if none are specified.
+	// +optional
 	Replicas int32 `json:"replicas,omitempty"`
 
+	// Nodes allows customising the nodes created in the cluster
+	//
+	// A control plane entry is added using Replicas if none are specified.
+	// +optional
+	Nodes []KindNode `json:"nodes,omitempty"`
+
+	// HostSelector restricts which KindHosts the cluster can be placed on
+	//
+	// The least loaded matching host with enough capacity is chosen. If no KindHosts
+	// exist the Kind server configured on the controller is used.
+	// +optional
+	HostSelector *metav1.LabelSelector `json:"hostSelector,omitempty"`
+
+	// Provider is the container runtime used to run the cluster nodes
+	//
+	// Defaults to the node provider configured on the Kind server, or auto-detected if not set.
+	// nerdctl isn't supported by the version of Kind currently used.
+	// +kubebuilder:validation:Enum=docker;podman
+	// +optional
+	Provider NodeProvider `json:"provider,omitempty"`
+
+	// Networking contains the network configuration of the cluster
+	//
+	// Defaults to the cluster network of the owner Cluster.
+	// +optional
+	Networking KindClusterNetworking `json:"networking,omitempty"`
+
 	// FeatureGates enables or disabled Kubernetes feature gates
 	//
 	// See https://kubernetes.io/docs/reference/command-line-tools-reference/feature-gates/
@@ -83,11 +161,197 @@ type KindClusterSpec struct {
 	// for the available values.
 	RuntimeConfig map[string]string `json:"runtimeConfig,omitempty"`
 
+	// KindConfig provides a raw Kind cluster config for options not available in this spec
+	//
+	// The raw config is merged with the config generated from this spec and must
+	// not set any of the same fields.
+	// +optional
+	KindConfig *KindConfigSource `json:"kindConfig,omitempty"`
+
+	// TTL is how long the cluster is kept for, measured from when the KindCluster was created (e.g. 4h)
+	//
+	// Once elapsed the owner Cluster is deleted, which deletes the KindCluster along with it.
+	// The TTL can be changed at any time, e.g. increased to extend the life of the cluster.
+	// +optional
+	TTL *metav1.Duration `json:"ttl,omitempty"`
+
+	// WaitForReady is how long Kind waits for the control plane to be ready when creating the cluster (e.g. 5m),
+	// defaults to 60s
+	//
+	// The KindCluster isn't ready until all of its nodes are ready, however long that takes.
+	// +optional
+	WaitForReady *metav1.Duration `json:"waitForReady,omitempty"`
+
+	// Suspended stops the cluster nodes to free up resources on the host while the cluster isn't needed
+	//
+	// The nodes are restarted, and the cluster becomes ready again once the API server is
+	// available, when set back to false.
+	// +optional
+	Suspended bool `json:"suspended,omitempty"`
+
+	// SnapshotRef references a KindClusterSnapshot, in the same namespace, to restore the cluster from
+	//
+	// The cluster is created on the same host as the snapshot, with the etcd data of the
+	// snapshotted cluster restored before it becomes ready. Nothing kept on the nodes'
+	// filesystems, such as hostPath or local-path volumes and images loaded into the
+	// nodes, is restored. It must use the same node image, and so Kubernetes version, as
+	// the snapshotted cluster and have a single control plane node, the cluster fails
+	// otherwise.
+	// +optional
+	SnapshotRef *corev1.LocalObjectReference `json:"snapshotRef,omitempty"`
+
+	// Addons are manifests applied to the cluster once its API server is ready, e.g. a CNI when the default is disabled
+	//
+	// Addons are applied in order, and applied again whenever their manifests change.
+	// Resources aren't removed from the cluster when an addon is removed.
+	// +optional
+	Addons []KindClusterAddon `json:"addons,omitempty"`
+
 	// ControlPlaneEndpoint represents the endpoint used to communicate with the control plane.
 	// +optional
 	ControlPlaneEndpoint clusterv1.APIEndpoint `json:"controlPlaneEndpoint"`
 }
 
+// NodeRole is the role of a node within the cluster
+type NodeRole string
+
+var (
+	// NodeRoleControlPlane indicates the node runs the control plane components
+	NodeRoleControlPlane NodeRole = "control-plane"
+	// NodeRoleWorker indicates the node only runs workloads
+	NodeRoleWorker NodeRole = "worker"
+)
+
+// NodeProvider is the container runtime used by Kind to run the cluster nodes
+type NodeProvider string
+
+var (
+	// NodeProviderDocker runs the cluster nodes with Docker
+	NodeProviderDocker NodeProvider = "docker"
+	// NodeProviderPodman runs the cluster nodes with Podman
+	NodeProviderPodman NodeProvider = "podman"
+	// NodeProviderNerdctl runs the cluster nodes with nerdctl
+	NodeProviderNerdctl NodeProvider = "nerdctl"
+)
+
+// IPFamily is the IP family of a cluster's network
+type IPFamily string
+
+var (
+	// IPFamilyIPv4 gives the cluster an IPv4 only network
+	IPFamilyIPv4 IPFamily = "ipv4"
+	// IPFamilyIPv6 gives the cluster an IPv6 only network
+	IPFamilyIPv6 IPFamily = "ipv6"
+	// IPFamilyDualStack gives the cluster both an IPv4 and an IPv6 network
+	IPFamilyDualStack IPFamily = "dual"
+)
+
+// KindNode defines the configuration of one or more nodes in a KindCluster
+type KindNode struct {
+	// Role is the role of the nodes in the cluster
+	//
+	// +kubebuilder:validation:Enum=control-plane;worker
+	Role NodeRole `json:"role"`
+
+	// Count is the number of nodes to create with this configuration
+	//
+	// Defaults to 1.
+	// +optional
+	Count int32 `json:"count,omitempty"`
+
+	// Labels are added to the nodes when they register with the cluster
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Taints are added to the nodes when they register with the cluster
+	// +optional
+	Taints []corev1.Taint `json:"taints,omitempty"`
+
+	// KubeletExtraArgs are passed as additional arguments to the kubelet on the nodes
+	// +optional
+	KubeletExtraArgs map[string]string `json:"kubeletExtraArgs,omitempty"`
+}
+
+// KindClusterNetworking defines the network configuration of a KindCluster
+type KindClusterNetworking struct {
+	// IPFamily is the IP family of the cluster's network, one of ipv4, ipv6 or dual for dual-stack
+	//
+	// Defaults to ipv6 or dual when the pod and service subnets are IPv6 or dual-stack, otherwise it's left to Kind,
+	// which uses ipv4.
+	// +kubebuilder:validation:Enum=ipv4;ipv6;dual
+	// +optional
+	IPFamily IPFamily `json:"ipFamily,omitempty"`
+
+	// PodSubnet is the CIDR range used for pod IPs
+	//
+	// Multiple comma separated ranges can be provided for dual-stack clusters.
+	// +optional
+	PodSubnet string `json:"podSubnet,omitempty"`
+
+	// ServiceSubnet is the CIDR range used for service VIPs
+	//
+	// Multiple comma separated ranges can be provided for dual-stack clusters.
+	// +optional
+	ServiceSubnet string `json:"serviceSubnet,omitempty"`
+
+	// DisableDefaultCNI stops Kind installing its default CNI, so another can be installed as an addon
+	//
+	// Nodes aren't ready until a CNI has been installed.
+	// +optional
+	DisableDefaultCNI bool `json:"disableDefaultCNI,omitempty"`
+}
+
+// KindClusterAddon references manifests, in a ConfigMap or Secret in the same namespace, to apply to the cluster
+//
+// Every key of the ConfigMap or Secret is applied, in order of key, and may contain multiple YAML or JSON documents.
+type KindClusterAddon struct {
+	// Name identifies the addon in the status
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// ConfigMapRef references a ConfigMap containing the manifests
+	// +optional
+	ConfigMapRef *corev1.LocalObjectReference `json:"configMapRef,omitempty"`
+
+	// SecretRef references a Secret containing the manifests
+	// +optional
+	SecretRef *corev1.LocalObjectReference `json:"secretRef,omitempty"`
+}
+
+// AddonStatus describes the result of applying an addon to the cluster
+type AddonStatus struct {
+	// Name is the name of the addon
+	Name string `json:"name"`
+
+	// Applied indicates all of the addon's manifests have been applied
+	Applied bool `json:"applied"`
+
+	// Hash identifies the manifests that were last applied, so they're applied again when changed
+	// +optional
+	Hash string `json:"hash,omitempty"`
+
+	// LastAppliedTime is when the manifests were last applied
+	// +optional
+	LastAppliedTime *metav1.Time `json:"lastAppliedTime,omitempty"`
+
+	// Message describes why the addon couldn't be applied
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// KindConfigSource contains a raw Kind cluster config (kind.x-k8s.io/v1alpha4)
+//
+// Only one of Inline or ConfigMapKeyRef may be set.
+type KindConfigSource struct {
+	// Inline contains the raw Kind config
+	// +optional
+	Inline string `json:"inline,omitempty"`
+
+	// ConfigMapKeyRef references a ConfigMap key in the same namespace containing the raw Kind config
+	// +optional
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+}
+
 // KindClusterStatus defines the observed state of KindCluster
 type KindClusterStatus struct {
 	// Ready indicates if the cluster is ready to use or not
@@ -98,10 +362,39 @@ type KindClusterStatus struct {
 	// +optional
 	Phase *KindClusterPhase `json:"phase"`
 
+	// Image is the fully resolved node image used for the cluster nodes
+	// +optional
+	Image *string `json:"image,omitempty"`
+
+	// Host is the name of the KindHost the cluster has been placed on
+	// +optional
+	Host *string `json:"host,omitempty"`
+
+	// Provider is the container runtime used to run the cluster nodes
+	// +optional
+	Provider *NodeProvider `json:"provider,omitempty"`
+
 	// KubeConfig contains the KubeConfig to use to communicate with the cluster
 	// +optional
 	KubeConfig *string `json:"kubeConfig,omitempty"`
 
+	// ExpiresAt is when the cluster will be deleted, if it has a TTL
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// TTLRemaining is roughly how long is left until the cluster is deleted, if it has a TTL
+	// +optional
+	TTLRemaining *string `json:"ttlRemaining,omitempty"`
+
+	// Addons contains the result of applying each of the addons
+	// +optional
+	Addons []AddonStatus `json:"addons,omitempty"`
+
+	// LogsArchive is the URL of the latest archive of the cluster's node logs on its Kind server, collected when
+	// requested with the collect-logs annotation or when a failed cluster is deleted
+	// +optional
+	LogsArchive *string `json:"logsArchive,omitempty"`
+
 	// FailureReason indicates there is a fatal problem reconciling the infrastructure
 	// suitable for programmatic interpretation
 	// +optional
@@ -115,6 +408,9 @@ type KindClusterStatus struct {
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="TTL",type=string,JSONPath=`.spec.ttl`
+//+kubebuilder:printcolumn:name="Remaining",type=string,JSONPath=`.status.ttlRemaining`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // KindCluster is the Schema for the kindclusters API
 type KindCluster struct {
@@ -125,11 +421,68 @@ type KindCluster struct {
 	Status KindClusterStatus `json:"status,omitempty"`
 }
 
+// KindNodes returns the nodes of the cluster, falling back to Replicas control plane nodes for KindClusters created
+// before the nodes were defaulted by the webhook
+func (kc *KindCluster) KindNodes() []KindNode {
+	if len(kc.Spec.Nodes) > 0 {
+		return kc.Spec.Nodes
+	}
+	replicas := kc.Spec.Replicas
+	if replicas == 0 {
+		replicas = DefaultReplicas
+	}
+	return []KindNode{{Role: NodeRoleControlPlane, Count: replicas}}
+}
+
+// NodeCount returns the total number of nodes in the cluster
+func (kc *KindCluster) NodeCount() int32 {
+	count := int32(0)
+	for _, node := range kc.KindNodes() {
+		count += node.Count
+	}
+	return count
+}
+
+// WaitForReady returns how long Kind waits for the control plane to be ready when creating the cluster
+func (kc *KindCluster) WaitForReady() time.Duration {
+	if kc.Spec.WaitForReady == nil {
+		return DefaultWaitForReady
+	}
+	return kc.Spec.WaitForReady.Duration
+}
+
+// KindName returns the name of the cluster in Kind, falling back to the namespaced name for KindClusters created
+// before the name was defaulted by the webhook
+func (kc *KindCluster) KindName() string {
+	if kc.Spec.Name == "" {
+		return kc.NamespacedName()
+	}
+	return kc.Spec.Name
+}
+
 // NamespacedName returns the KindCluster name prefixed with the namespace
 func (kc *KindCluster) NamespacedName() string {
 	return fmt.Sprintf("%s-%s", kc.Namespace, kc.Name)
 }
 
+// SnapshotName returns the name of the snapshot on the Kind server the cluster is restored from, if any
+func (kc *KindCluster) SnapshotName() string {
+	if kc.Spec.SnapshotRef == nil {
+		return ""
+	}
+	return snapshotName(kc.Namespace, kc.Spec.SnapshotRef.Name)
+}
+
+// OwnershipLabels returns the labels the Kind server records against the cluster to identify this KindCluster
+// as its owner
+func (kc *KindCluster) OwnershipLabels() map[string]string {
+	return map[string]string{
+		OwnerNamespaceLabel: kc.Namespace,
+		OwnerNameLabel:      kc.Name,
+		OwnerUIDLabel:       string(kc.UID),
+	}
+}
+
 //+kubebuilder:object:root=true
 
 // KindClusterList contains a list of KindCluster
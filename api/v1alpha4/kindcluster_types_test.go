@@ -17,8 +17,10 @@ limitations under the License.
 package v1alpha4
 
 import (
+	"reflect"
 	"testing"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -55,3 +57,108 @@ func TestKindClusterNamespacedName(t *testing.T) {
 		})
 	}
 }
+
+func TestKindClusterKindName(t *testing.T) {
+	tests := []struct {
+		name    string
+		cluster *KindCluster
+		want    string
+	}{
+		{
+			name: "defaulted name",
+			cluster: &KindCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"},
+				Spec:       KindClusterSpec{Name: "custom-name"},
+			},
+			want: "custom-name",
+		},
+		{
+			name: "name not defaulted",
+			cluster: &KindCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"},
+			},
+			want: "default-test-cluster",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.cluster.KindName() != tt.want {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tt.want, tt.cluster.KindName())
+			}
+		})
+	}
+}
+
+func TestSnapshotName(t *testing.T) {
+	kindCluster := &KindCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "a-b"},
+		Spec:       KindClusterSpec{SnapshotRef: &corev1.LocalObjectReference{Name: "c"}},
+	}
+	snapshot := &KindClusterSnapshot{ObjectMeta: metav1.ObjectMeta{Name: "c", Namespace: "a-b"}}
+	other := &KindClusterSnapshot{ObjectMeta: metav1.ObjectMeta{Name: "b-c", Namespace: "a"}}
+
+	if kindCluster.SnapshotName() != snapshot.SnapshotName() {
+		t.Errorf("unexpected result - wanted %+v, got %+v", snapshot.SnapshotName(), kindCluster.SnapshotName())
+	}
+	if snapshot.SnapshotName() == other.SnapshotName() {
+		t.Errorf("was expecting snapshots in different namespaces to have different names - %+v", snapshot.SnapshotName())
+	}
+}
+
+func TestKindClusterOwnershipLabels(t *testing.T) {
+	cluster := &KindCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "default",
+			UID:       "1234",
+		},
+	}
+	want := map[string]string{
+		OwnerNamespaceLabel: "default",
+		OwnerNameLabel:      "test-cluster",
+		OwnerUIDLabel:       "1234",
+	}
+
+	if got := cluster.OwnershipLabels(); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected result - wanted %+v, got %+v", want, got)
+	}
+}
+
+func TestKindClusterNodeCount(t *testing.T) {
+	tests := []struct {
+		name    string
+		cluster *KindCluster
+		want    int32
+	}{
+		{
+			name: "defaulted nodes",
+			cluster: &KindCluster{
+				Spec: KindClusterSpec{
+					Replicas: 3,
+					Nodes: []KindNode{
+						{Role: NodeRoleControlPlane, Count: 3},
+						{Role: NodeRoleWorker, Count: 2},
+					},
+				},
+			},
+			want: 5,
+		},
+		{
+			name:    "replicas without nodes",
+			cluster: &KindCluster{Spec: KindClusterSpec{Replicas: 3}},
+			want:    3,
+		},
+		{
+			name:    "no replicas or nodes",
+			cluster: &KindCluster{},
+			want:    DefaultReplicas,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.cluster.NodeCount() != tt.want {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tt.want, tt.cluster.NodeCount())
+			}
+		})
+	}
+}
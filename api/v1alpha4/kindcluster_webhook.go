@@ -17,19 +17,40 @@ limitations under the License.
 package v1alpha4
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"reflect"
+	"strings"
 
+	"github.com/docker/distribution/reference"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/cluster-api/util"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	kindv1alpha4 "sigs.k8s.io/kind/pkg/apis/config/v1alpha4"
+
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/featuregates"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/kindconfig"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/nodeimage"
 )
 
 // log is for logging in this package.
 var kindclusterlog = logf.Log.WithName("kindcluster-resource")
 
+// webhookClient is used to look up the owner Cluster when defaulting
+var webhookClient client.Client
+
 func (r *KindCluster) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	webhookClient = mgr.GetClient()
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(r).
 		Complete()
@@ -42,6 +63,80 @@ var _ webhook.Defaulter = &KindCluster{}
 // Default implements webhook.Defaulter so a webhook will be registered for the type
 func (r *KindCluster) Default() {
 	kindclusterlog.Info("default", "name", r.Name)
+
+	if r.Spec.Name == "" && r.Name != "" {
+		r.Spec.Name = r.NamespacedName()
+	}
+
+	if r.Spec.Image == "" {
+		r.Spec.Image = DefaultImage
+	}
+
+	if r.Spec.Version == "" {
+		r.Spec.Version = DefaultVersion
+	}
+
+	r.defaultNodes()
+	r.defaultNetworking()
+	r.defaultIPFamily()
+}
+
+// defaultNodes ensures the control plane replicas and node list agree
+func (r *KindCluster) defaultNodes() {
+	controlPlaneCount := int32(0)
+	for i := range r.Spec.Nodes {
+		if r.Spec.Nodes[i].Count == 0 {
+			r.Spec.Nodes[i].Count = 1
+		}
+		if r.Spec.Nodes[i].Role == NodeRoleControlPlane {
+			controlPlaneCount += r.Spec.Nodes[i].Count
+		}
+	}
+
+	if r.Spec.Replicas == 0 {
+		r.Spec.Replicas = controlPlaneCount
+		if r.Spec.Replicas == 0 {
+			r.Spec.Replicas = DefaultReplicas
+		}
+	}
+
+	if controlPlaneCount == 0 {
+		controlPlane := KindNode{
+			Role:  NodeRoleControlPlane,
+			Count: r.Spec.Replicas,
+		}
+		r.Spec.Nodes = append([]KindNode{controlPlane}, r.Spec.Nodes...)
+	}
+}
+
+// defaultNetworking populates any missing networking config from the owner Cluster, if it has been set
+func (r *KindCluster) defaultNetworking() {
+	if webhookClient == nil {
+		return
+	}
+
+	if r.Spec.Networking.PodSubnet != "" && r.Spec.Networking.ServiceSubnet != "" {
+		return
+	}
+
+	cluster, err := util.GetOwnerCluster(context.TODO(), webhookClient, r.ObjectMeta)
+	if err != nil {
+		kindclusterlog.Error(err, "failed to get owner cluster", "name", r.Name)
+		return
+	}
+
+	if cluster == nil || cluster.Spec.ClusterNetwork == nil {
+		return
+	}
+
+	clusterNetwork := cluster.Spec.ClusterNetwork
+	if r.Spec.Networking.PodSubnet == "" && clusterNetwork.Pods != nil {
+		r.Spec.Networking.PodSubnet = strings.Join(clusterNetwork.Pods.CIDRBlocks, ",")
+	}
+
+	if r.Spec.Networking.ServiceSubnet == "" && clusterNetwork.Services != nil {
+		r.Spec.Networking.ServiceSubnet = strings.Join(clusterNetwork.Services.CIDRBlocks, ",")
+	}
 }
 
 //+kubebuilder:webhook:path=/validate-infrastructure-cluster-x-k8s-io-v1alpha4-kindcluster,mutating=false,failurePolicy=fail,sideEffects=None,groups=infrastructure.cluster.x-k8s.io,resources=kindclusters,verbs=create;update,versions=v1alpha4,name=vkindcluster.kb.io,admissionReviewVersions={v1,v1beta1}
@@ -51,7 +146,331 @@ var _ webhook.Validator = &KindCluster{}
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type
 func (r *KindCluster) ValidateCreate() error {
 	kindclusterlog.Info("validate create", "name", r.Name)
-	return nil
+
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	if r.Spec.Replicas < 1 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("replicas"), r.Spec.Replicas, "must be at least 1"))
+	} else if r.Spec.Replicas%2 == 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("replicas"), r.Spec.Replicas, "must be an odd number to maintain etcd quorum"))
+	}
+
+	if named, err := reference.ParseNormalizedNamed(r.Spec.Image); err != nil {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("image"), r.Spec.Image, err.Error()))
+	} else if !reference.IsNameOnly(named) {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("image"), r.Spec.Image, "must not include a tag or digest, use version instead"))
+	}
+
+	if r.Spec.Image == DefaultImage && !nodeimage.IsSupported(r.Spec.Version) {
+		allErrs = append(allErrs, field.NotSupported(specPath.Child("version"), r.Spec.Version, nodeimage.SupportedVersions()))
+	}
+
+	allErrs = append(allErrs, r.validateNodes(specPath)...)
+	allErrs = append(allErrs, r.validateNetworking(specPath)...)
+	allErrs = append(allErrs, r.validateKindConfig(specPath)...)
+	allErrs = append(allErrs, r.validateTTL(specPath)...)
+	allErrs = append(allErrs, r.validateAddons(specPath)...)
+
+	if r.Spec.WaitForReady != nil && r.Spec.WaitForReady.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("waitForReady"), r.Spec.WaitForReady.Duration.String(), "must be greater than 0"))
+	}
+
+	if r.Spec.SnapshotRef != nil {
+		if r.Spec.SnapshotRef.Name == "" {
+			allErrs = append(allErrs, field.Required(specPath.Child("snapshotRef", "name"), "must be set"))
+		}
+		// Only a single etcd member can be restored from the snapshot
+		if r.Spec.Replicas != 1 {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("replicas"), r.Spec.Replicas, "must be 1 when restoring from a snapshot"))
+		}
+	}
+
+	if r.Spec.HostSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(r.Spec.HostSelector); err != nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("hostSelector"), r.Spec.HostSelector, err.Error()))
+		}
+	}
+
+	for name := range r.Spec.FeatureGates {
+		if !featuregates.IsKnown(name) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("featureGates").Key(name), name, "unknown feature gate"))
+		}
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("KindCluster").GroupKind(), r.Name, allErrs)
+}
+
+// defaultIPFamily sets the IP family to match the pod and service subnets if they're IPv6 or dual-stack, otherwise
+// it's left to Kind's default of IPv4
+func (r *KindCluster) defaultIPFamily() {
+	if r.Spec.Networking.IPFamily != "" {
+		return
+	}
+
+	hasIPv4, hasIPv6 := false, false
+	for _, subnets := range []string{r.Spec.Networking.PodSubnet, r.Spec.Networking.ServiceSubnet} {
+		ipv4, ipv6, err := subnetFamilies(subnets)
+		if err != nil {
+			// Invalid subnets are reported by the validating webhook
+			return
+		}
+		hasIPv4 = hasIPv4 || ipv4 > 0
+		hasIPv6 = hasIPv6 || ipv6 > 0
+	}
+
+	switch {
+	case hasIPv4 && hasIPv6:
+		r.Spec.Networking.IPFamily = IPFamilyDualStack
+	case hasIPv6:
+		r.Spec.Networking.IPFamily = IPFamilyIPv6
+	}
+}
+
+// ipFamily returns the IP family of the cluster, Kind defaulting to IPv4 if it isn't set
+func (n KindClusterNetworking) ipFamily() IPFamily {
+	if n.IPFamily == "" {
+		return IPFamilyIPv4
+	}
+	return n.IPFamily
+}
+
+// subnetFamilies returns the number of IPv4 and IPv6 ranges in the comma separated CIDR ranges
+func subnetFamilies(subnets string) (ipv4, ipv6 int, err error) {
+	if subnets == "" {
+		return 0, 0, nil
+	}
+	for _, subnet := range strings.Split(subnets, ",") {
+		ip, _, err := net.ParseCIDR(strings.TrimSpace(subnet))
+		if err != nil {
+			return 0, 0, err
+		}
+		if ip.To4() != nil {
+			ipv4++
+		} else {
+			ipv6++
+		}
+	}
+	return ipv4, ipv6, nil
+}
+
+// validateNodes checks the node list is consistent with the control plane replicas and
+// that the requested labels, taints and kubelet args can be applied
+func (r *KindCluster) validateNodes(specPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	controlPlaneCount := int32(0)
+	for i, node := range r.Spec.Nodes {
+		nodePath := specPath.Child("nodes").Index(i)
+
+		if node.Count < 1 {
+			allErrs = append(allErrs, field.Invalid(nodePath.Child("count"), node.Count, "must be at least 1"))
+		}
+
+		if node.Role == NodeRoleControlPlane {
+			controlPlaneCount += node.Count
+		}
+
+		allErrs = append(allErrs, metav1validation.ValidateLabels(node.Labels, nodePath.Child("labels"))...)
+
+		for j, taint := range node.Taints {
+			taintPath := nodePath.Child("taints").Index(j)
+			for _, msg := range validation.IsQualifiedName(taint.Key) {
+				allErrs = append(allErrs, field.Invalid(taintPath.Child("key"), taint.Key, msg))
+			}
+			if taint.Value != "" {
+				for _, msg := range validation.IsValidLabelValue(taint.Value) {
+					allErrs = append(allErrs, field.Invalid(taintPath.Child("value"), taint.Value, msg))
+				}
+			}
+			switch taint.Effect {
+			case corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+			default:
+				allErrs = append(allErrs, field.NotSupported(taintPath.Child("effect"), taint.Effect, []string{
+					string(corev1.TaintEffectNoSchedule),
+					string(corev1.TaintEffectPreferNoSchedule),
+					string(corev1.TaintEffectNoExecute),
+				}))
+			}
+		}
+
+		for _, arg := range []string{"node-labels", "register-with-taints"} {
+			if _, ok := node.KubeletExtraArgs[arg]; ok {
+				allErrs = append(allErrs, field.Forbidden(nodePath.Child("kubeletExtraArgs").Key(arg), "use labels and taints instead"))
+			}
+		}
+	}
+
+	if controlPlaneCount != r.Spec.Replicas {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("replicas"), r.Spec.Replicas, fmt.Sprintf("must match the number of control plane nodes (%d)", controlPlaneCount)))
+	}
+
+	return allErrs
+}
+
+// validateNetworking checks the pod and service subnets are valid CIDR ranges matching the IP family, a single
+// range for IPv4 or IPv6 clusters and one of each for dual-stack clusters
+func (r *KindCluster) validateNetworking(specPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	networkingPath := specPath.Child("networking")
+
+	family := r.Spec.Networking.ipFamily()
+	switch family {
+	case IPFamilyIPv4, IPFamilyIPv6, IPFamilyDualStack:
+	default:
+		return append(allErrs, field.NotSupported(networkingPath.Child("ipFamily"), family, []string{
+			string(IPFamilyIPv4),
+			string(IPFamilyIPv6),
+			string(IPFamilyDualStack),
+		}))
+	}
+
+	subnets := []struct {
+		name  string
+		value string
+	}{
+		{name: "podSubnet", value: r.Spec.Networking.PodSubnet},
+		{name: "serviceSubnet", value: r.Spec.Networking.ServiceSubnet},
+	}
+	for _, subnet := range subnets {
+		if subnet.value == "" {
+			continue
+		}
+		subnetPath := networkingPath.Child(subnet.name)
+
+		ipv4, ipv6, err := subnetFamilies(subnet.value)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(subnetPath, subnet.value, err.Error()))
+			continue
+		}
+
+		switch {
+		case family == IPFamilyIPv4 && (ipv4 != 1 || ipv6 != 0):
+			allErrs = append(allErrs, field.Invalid(subnetPath, subnet.value, "must be a single IPv4 range for an ipv4 cluster"))
+		case family == IPFamilyIPv6 && (ipv4 != 0 || ipv6 != 1):
+			allErrs = append(allErrs, field.Invalid(subnetPath, subnet.value, "must be a single IPv6 range for an ipv6 cluster"))
+		case family == IPFamilyDualStack && (ipv4 != 1 || ipv6 != 1):
+			allErrs = append(allErrs, field.Invalid(subnetPath, subnet.value, "must be an IPv4 and an IPv6 range for a dual-stack cluster"))
+		}
+	}
+
+	return allErrs
+}
+
+// validateKindConfig checks the raw Kind config can be decoded by Kind and doesn't set
+// any fields that are already set by the spec
+func (r *KindCluster) validateKindConfig(specPath *field.Path) field.ErrorList {
+	if r.Spec.KindConfig == nil {
+		return nil
+	}
+
+	var allErrs field.ErrorList
+	configPath := specPath.Child("kindConfig")
+	source := r.Spec.KindConfig
+
+	var raw string
+	var rawPath *field.Path
+	switch {
+	case source.Inline != "" && source.ConfigMapKeyRef != nil:
+		return append(allErrs, field.Forbidden(configPath, "only one of inline or configMapKeyRef may be set"))
+	case source.Inline != "":
+		raw = source.Inline
+		rawPath = configPath.Child("inline")
+	case source.ConfigMapKeyRef != nil:
+		rawPath = configPath.Child("configMapKeyRef")
+		if webhookClient == nil {
+			// The ConfigMap can't be checked without a client so leave it to the controller
+			return nil
+		}
+		configMap := &corev1.ConfigMap{}
+		key := client.ObjectKey{Namespace: r.Namespace, Name: source.ConfigMapKeyRef.Name}
+		if err := webhookClient.Get(context.TODO(), key, configMap); err != nil {
+			return append(allErrs, field.Invalid(rawPath.Child("name"), source.ConfigMapKeyRef.Name, err.Error()))
+		}
+		var ok bool
+		if raw, ok = configMap.Data[source.ConfigMapKeyRef.Key]; !ok {
+			return append(allErrs, field.Invalid(rawPath.Child("key"), source.ConfigMapKeyRef.Key, "key not found in ConfigMap"))
+		}
+	default:
+		return append(allErrs, field.Required(configPath, "one of inline or configMapKeyRef must be set"))
+	}
+
+	config, err := kindconfig.Parse(raw)
+	if err != nil {
+		return append(allErrs, field.Invalid(rawPath, raw, err.Error()))
+	}
+
+	for _, conflict := range kindconfig.Conflicts(r.specKindConfig(), config) {
+		allErrs = append(allErrs, field.Forbidden(rawPath, fmt.Sprintf("%s is already set by the spec", conflict)))
+	}
+
+	return allErrs
+}
+
+// validateTTL checks the TTL, if set, is positive
+func (r *KindCluster) validateTTL(specPath *field.Path) field.ErrorList {
+	if r.Spec.TTL == nil || r.Spec.TTL.Duration > 0 {
+		return nil
+	}
+	return field.ErrorList{field.Invalid(specPath.Child("ttl"), r.Spec.TTL.Duration.String(), "must be greater than 0")}
+}
+
+// validateAddons checks each addon has a unique name and references exactly one ConfigMap or Secret
+func (r *KindCluster) validateAddons(specPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	names := map[string]bool{}
+	for i, addon := range r.Spec.Addons {
+		addonPath := specPath.Child("addons").Index(i)
+
+		if addon.Name == "" {
+			allErrs = append(allErrs, field.Required(addonPath.Child("name"), "must be set"))
+		} else if names[addon.Name] {
+			allErrs = append(allErrs, field.Duplicate(addonPath.Child("name"), addon.Name))
+		}
+		names[addon.Name] = true
+
+		switch {
+		case addon.ConfigMapRef != nil && addon.SecretRef != nil:
+			allErrs = append(allErrs, field.Forbidden(addonPath, "only one of configMapRef or secretRef may be set"))
+		case addon.ConfigMapRef != nil:
+			if addon.ConfigMapRef.Name == "" {
+				allErrs = append(allErrs, field.Required(addonPath.Child("configMapRef", "name"), "must be set"))
+			}
+		case addon.SecretRef != nil:
+			if addon.SecretRef.Name == "" {
+				allErrs = append(allErrs, field.Required(addonPath.Child("secretRef", "name"), "must be set"))
+			}
+		default:
+			allErrs = append(allErrs, field.Required(addonPath, "one of configMapRef or secretRef must be set"))
+		}
+	}
+
+	return allErrs
+}
+
+// specKindConfig returns the parts of the Kind config that are set by the spec, used to detect conflicts
+func (r *KindCluster) specKindConfig() *kindv1alpha4.Cluster {
+	config := &kindv1alpha4.Cluster{
+		FeatureGates:  r.Spec.FeatureGates,
+		RuntimeConfig: r.Spec.RuntimeConfig,
+		Networking: kindv1alpha4.Networking{
+			IPFamily:      kindv1alpha4.ClusterIPFamily(r.Spec.Networking.IPFamily),
+			PodSubnet:     r.Spec.Networking.PodSubnet,
+			ServiceSubnet: r.Spec.Networking.ServiceSubnet,
+		},
+	}
+
+	for _, node := range r.Spec.Nodes {
+		for i := int32(0); i < node.Count; i++ {
+			config.Nodes = append(config.Nodes, kindv1alpha4.Node{Role: kindv1alpha4.NodeRole(node.Role)})
+		}
+	}
+
+	return config
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
@@ -59,27 +478,84 @@ func (r *KindCluster) ValidateUpdate(old runtime.Object) error {
 	kindclusterlog.Info("validate update", "name", r.Name)
 	oldCluster := old.(*KindCluster)
 
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	// Fields populated by the defaulting webhook may be set once but not modified after
+	if oldCluster.Spec.Name != "" && oldCluster.Spec.Name != r.Spec.Name {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("name"), "Unable to modify name"))
+	}
+
+	if oldCluster.Spec.Networking.PodSubnet != "" && oldCluster.Spec.Networking.PodSubnet != r.Spec.Networking.PodSubnet {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("networking", "podSubnet"), "Unable to modify podSubnet"))
+	}
+
+	if oldCluster.Spec.Networking.ServiceSubnet != "" && oldCluster.Spec.Networking.ServiceSubnet != r.Spec.Networking.ServiceSubnet {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("networking", "serviceSubnet"), "Unable to modify serviceSubnet"))
+	}
+
+	if oldCluster.Spec.Networking.IPFamily != "" && oldCluster.Spec.Networking.IPFamily != r.Spec.Networking.IPFamily {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("networking", "ipFamily"), "Unable to modify ipFamily"))
+	}
+
+	if oldCluster.Spec.Networking.DisableDefaultCNI != r.Spec.Networking.DisableDefaultCNI {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("networking", "disableDefaultCNI"), "Unable to modify disableDefaultCNI"))
+	}
+
+	if len(oldCluster.Spec.Nodes) > 0 && !reflect.DeepEqual(oldCluster.Spec.Nodes, r.Spec.Nodes) {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("nodes"), "Unable to modify nodes"))
+	}
+
 	if oldCluster.Spec.Replicas != r.Spec.Replicas {
-		return fmt.Errorf("Unable to modify replicas")
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("replicas"), "Unable to modify replicas"))
 	}
 
 	if oldCluster.Spec.Image != r.Spec.Image {
-		return fmt.Errorf("Unable to modify image")
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("image"), "Unable to modify image"))
 	}
 
 	if oldCluster.Spec.Version != r.Spec.Version {
-		return fmt.Errorf("Unable to modify version")
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("version"), "Unable to modify version"))
+	}
+
+	if !reflect.DeepEqual(oldCluster.Spec.HostSelector, r.Spec.HostSelector) {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("hostSelector"), "Unable to modify hostSelector"))
+	}
+
+	if oldCluster.Spec.Provider != r.Spec.Provider {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("provider"), "Unable to modify provider"))
 	}
 
 	if !reflect.DeepEqual(oldCluster.Spec.FeatureGates, r.Spec.FeatureGates) {
-		return fmt.Errorf("Unable to modify featureGates")
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("featureGates"), "Unable to modify featureGates"))
 	}
 
 	if !reflect.DeepEqual(oldCluster.Spec.RuntimeConfig, r.Spec.RuntimeConfig) {
-		return fmt.Errorf("Unable to modify runtimeConfig")
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("runtimeConfig"), "Unable to modify runtimeConfig"))
 	}
 
-	return nil
+	if !reflect.DeepEqual(oldCluster.Spec.KindConfig, r.Spec.KindConfig) {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("kindConfig"), "Unable to modify kindConfig"))
+	}
+
+	if !reflect.DeepEqual(oldCluster.Spec.WaitForReady, r.Spec.WaitForReady) {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("waitForReady"), "Unable to modify waitForReady"))
+	}
+
+	if !reflect.DeepEqual(oldCluster.Spec.SnapshotRef, r.Spec.SnapshotRef) {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("snapshotRef"), "Unable to modify snapshotRef"))
+	}
+
+	// The TTL can be changed, e.g. to extend the life of the cluster, so is only checked to be valid
+	allErrs = append(allErrs, r.validateTTL(specPath)...)
+
+	// Addons can be added, changed or removed at any time
+	allErrs = append(allErrs, r.validateAddons(specPath)...)
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("KindCluster").GroupKind(), r.Name, allErrs)
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
@@ -17,16 +17,709 @@ limitations under the License.
 package v1alpha4
 
 import (
+	"reflect"
 	"testing"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
+func TestKindClusterDefault(t *testing.T) {
+	tests := []struct {
+		name    string
+		cluster *KindCluster
+		want    KindClusterSpec
+	}{
+		{
+			name: "populate all defaults",
+			cluster: &KindCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-cluster",
+					Namespace: "default",
+				},
+			},
+			want: KindClusterSpec{
+				Name:     "default-test-cluster",
+				Image:    DefaultImage,
+				Version:  DefaultVersion,
+				Replicas: DefaultReplicas,
+				Nodes: []KindNode{
+					{Role: NodeRoleControlPlane, Count: DefaultReplicas},
+				},
+			},
+		},
+		{
+			name: "don't override provided values",
+			cluster: &KindCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-cluster",
+					Namespace: "default",
+				},
+				Spec: KindClusterSpec{
+					Name:     "custom-name",
+					Image:    "example/node",
+					Version:  "v1.20.7",
+					Replicas: 3,
+				},
+			},
+			want: KindClusterSpec{
+				Name:     "custom-name",
+				Image:    "example/node",
+				Version:  "v1.20.7",
+				Replicas: 3,
+				Nodes: []KindNode{
+					{Role: NodeRoleControlPlane, Count: 3},
+				},
+			},
+		},
+		{
+			name: "add control plane to provided nodes",
+			cluster: &KindCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-cluster",
+					Namespace: "default",
+				},
+				Spec: KindClusterSpec{
+					Nodes: []KindNode{
+						{Role: NodeRoleWorker},
+					},
+				},
+			},
+			want: KindClusterSpec{
+				Name:     "default-test-cluster",
+				Image:    DefaultImage,
+				Version:  DefaultVersion,
+				Replicas: DefaultReplicas,
+				Nodes: []KindNode{
+					{Role: NodeRoleControlPlane, Count: DefaultReplicas},
+					{Role: NodeRoleWorker, Count: 1},
+				},
+			},
+		},
+		{
+			name: "replicas derived from control plane nodes",
+			cluster: &KindCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-cluster",
+					Namespace: "default",
+				},
+				Spec: KindClusterSpec{
+					Nodes: []KindNode{
+						{Role: NodeRoleControlPlane, Count: 3},
+						{Role: NodeRoleWorker, Count: 2},
+					},
+				},
+			},
+			want: KindClusterSpec{
+				Name:     "default-test-cluster",
+				Image:    DefaultImage,
+				Version:  DefaultVersion,
+				Replicas: 3,
+				Nodes: []KindNode{
+					{Role: NodeRoleControlPlane, Count: 3},
+					{Role: NodeRoleWorker, Count: 2},
+				},
+			},
+		},
+		{
+			name: "don't set name before it is generated",
+			cluster: &KindCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: "test-cluster-",
+					Namespace:    "default",
+				},
+			},
+			want: KindClusterSpec{
+				Image:    DefaultImage,
+				Version:  DefaultVersion,
+				Replicas: DefaultReplicas,
+				Nodes: []KindNode{
+					{Role: NodeRoleControlPlane, Count: DefaultReplicas},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cluster.Default()
+			if !reflect.DeepEqual(tt.cluster.Spec, tt.want) {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tt.want, tt.cluster.Spec)
+			}
+		})
+	}
+}
+
+func TestKindClusterDefaultNetworking(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clusterv1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build scheme - %+v", err)
+	}
+
+	ownerCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "default",
+		},
+		Spec: clusterv1.ClusterSpec{
+			ClusterNetwork: &clusterv1.ClusterNetwork{
+				Pods: &clusterv1.NetworkRanges{
+					CIDRBlocks: []string{"192.168.0.0/16", "fd00:10:244::/56"},
+				},
+				Services: &clusterv1.NetworkRanges{
+					CIDRBlocks: []string{"10.96.0.0/12"},
+				},
+			},
+		},
+	}
+
+	webhookClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(ownerCluster).Build()
+	defer func() { webhookClient = nil }()
+
+	tests := []struct {
+		name    string
+		cluster *KindCluster
+		want    KindClusterNetworking
+	}{
+		{
+			name: "no owner cluster",
+			cluster: &KindCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-cluster",
+					Namespace: "default",
+				},
+			},
+			want: KindClusterNetworking{},
+		},
+		{
+			name: "derived from owner cluster",
+			cluster: &KindCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-cluster",
+					Namespace: "default",
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							APIVersion: clusterv1.GroupVersion.String(),
+							Kind:       "Cluster",
+							Name:       "test-cluster",
+						},
+					},
+				},
+			},
+			want: KindClusterNetworking{
+				IPFamily:      IPFamilyDualStack,
+				PodSubnet:     "192.168.0.0/16,fd00:10:244::/56",
+				ServiceSubnet: "10.96.0.0/12",
+			},
+		},
+		{
+			name: "don't override provided values",
+			cluster: &KindCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-cluster",
+					Namespace: "default",
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							APIVersion: clusterv1.GroupVersion.String(),
+							Kind:       "Cluster",
+							Name:       "test-cluster",
+						},
+					},
+				},
+				Spec: KindClusterSpec{
+					Networking: KindClusterNetworking{
+						PodSubnet: "10.244.0.0/16",
+					},
+				},
+			},
+			want: KindClusterNetworking{
+				PodSubnet:     "10.244.0.0/16",
+				ServiceSubnet: "10.96.0.0/12",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cluster.Default()
+			if tt.cluster.Spec.Networking != tt.want {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tt.want, tt.cluster.Spec.Networking)
+			}
+		})
+	}
+}
+
+func TestKindClusterDefaultIPFamily(t *testing.T) {
+	tests := []struct {
+		name       string
+		networking KindClusterNetworking
+		want       IPFamily
+	}{
+		{
+			name: "no subnets",
+			want: "",
+		},
+		{
+			name:       "ipv4 subnets",
+			networking: KindClusterNetworking{PodSubnet: "10.244.0.0/16", ServiceSubnet: "10.96.0.0/12"},
+			want:       "",
+		},
+		{
+			name:       "ipv6 subnets",
+			networking: KindClusterNetworking{PodSubnet: "fd00:10:244::/56", ServiceSubnet: "fd00:10:96::/112"},
+			want:       IPFamilyIPv6,
+		},
+		{
+			name:       "dual-stack subnets",
+			networking: KindClusterNetworking{PodSubnet: "10.244.0.0/16,fd00:10:244::/56", ServiceSubnet: "10.96.0.0/12,fd00:10:96::/112"},
+			want:       IPFamilyDualStack,
+		},
+		{
+			name:       "don't override provided value",
+			networking: KindClusterNetworking{IPFamily: IPFamilyIPv4, PodSubnet: "fd00:10:244::/56"},
+			want:       IPFamilyIPv4,
+		},
+		{
+			name:       "invalid subnet",
+			networking: KindClusterNetworking{PodSubnet: "fd00:10:244::/56", ServiceSubnet: "not-a-cidr"},
+			want:       "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := &KindCluster{Spec: KindClusterSpec{Networking: tt.networking}}
+			cluster.Default()
+			if cluster.Spec.Networking.IPFamily != tt.want {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tt.want, cluster.Spec.Networking.IPFamily)
+			}
+		})
+	}
+}
+
+func TestKindClusterCreateInvalid(t *testing.T) {
+	validCluster := KindCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "default",
+		},
+		Spec: KindClusterSpec{
+			Name:     "default-test-cluster",
+			Replicas: 1,
+			Image:    "kindest/node",
+			Version:  "v1.21.2",
+		},
+	}
+
+	tests := []struct {
+		name       string
+		newCluster *KindCluster
+		wantErrors int
+	}{
+		{
+			name:       "return no error for a valid cluster",
+			newCluster: validCluster.DeepCopy(),
+			wantErrors: 0,
+		},
+		{
+			name: "allow multiple control plane nodes",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.Replicas = 3
+				return newCluster
+			}(),
+			wantErrors: 0,
+		},
+		{
+			name: "allow a TTL",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.TTL = &metav1.Duration{Duration: 4 * time.Hour}
+				return newCluster
+			}(),
+			wantErrors: 0,
+		},
+		{
+			name: "don't allow a negative TTL",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.TTL = &metav1.Duration{Duration: -time.Hour}
+				return newCluster
+			}(),
+			wantErrors: 1,
+		},
+		{
+			name: "allow a wait for ready",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.WaitForReady = &metav1.Duration{Duration: 5 * time.Minute}
+				return newCluster
+			}(),
+			wantErrors: 0,
+		},
+		{
+			name: "don't allow a zero wait for ready",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.WaitForReady = &metav1.Duration{}
+				return newCluster
+			}(),
+			wantErrors: 1,
+		},
+		{
+			name: "allow restoring from a snapshot",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.SnapshotRef = &corev1.LocalObjectReference{Name: "test-snapshot"}
+				return newCluster
+			}(),
+			wantErrors: 0,
+		},
+		{
+			name: "don't allow restoring a snapshot into multiple control plane nodes",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.Replicas = 3
+				newCluster.Spec.SnapshotRef = &corev1.LocalObjectReference{}
+				return newCluster
+			}(),
+			wantErrors: 2,
+		},
+		{
+			name: "allow ipv6 subnets",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.Networking.PodSubnet = "fd00:10:244::/56"
+				newCluster.Spec.Networking.ServiceSubnet = "fd00:10:96::/112"
+				return newCluster
+			}(),
+			wantErrors: 0,
+		},
+		{
+			name: "allow dual-stack subnets",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.Networking.PodSubnet = "10.244.0.0/16,fd00:10:244::/56"
+				newCluster.Spec.Networking.ServiceSubnet = "10.96.0.0/12, fd00:10:96::/112"
+				return newCluster
+			}(),
+			wantErrors: 0,
+		},
+		{
+			name: "allow ipv6 without subnets",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.Networking.IPFamily = IPFamilyIPv6
+				return newCluster
+			}(),
+			wantErrors: 0,
+		},
+		{
+			name: "don't allow invalid subnets",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.Networking.PodSubnet = "10.244.0.0"
+				newCluster.Spec.Networking.ServiceSubnet = "fd00:10:96::/129"
+				return newCluster
+			}(),
+			wantErrors: 2,
+		},
+		{
+			name: "don't allow subnets not matching the ip family",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.Networking.IPFamily = IPFamilyIPv4
+				newCluster.Spec.Networking.PodSubnet = "fd00:10:244::/56"
+				return newCluster
+			}(),
+			wantErrors: 1,
+		},
+		{
+			name: "don't allow single stack subnets for dual-stack",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.Networking.PodSubnet = "10.244.0.0/16,fd00:10:244::/56"
+				newCluster.Spec.Networking.ServiceSubnet = "10.96.0.0/12"
+				return newCluster
+			}(),
+			wantErrors: 1,
+		},
+		{
+			name: "don't allow multiple ranges of the same family",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.Networking.PodSubnet = "10.244.0.0/16,10.245.0.0/16"
+				return newCluster
+			}(),
+			wantErrors: 1,
+		},
+		{
+			name: "don't allow an unknown ip family",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.Networking.IPFamily = "ipv5"
+				return newCluster
+			}(),
+			wantErrors: 1,
+		},
+		{
+			name: "allow addons",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.Networking.DisableDefaultCNI = true
+				newCluster.Spec.Addons = []KindClusterAddon{
+					{Name: "cni", ConfigMapRef: &corev1.LocalObjectReference{Name: "calico"}},
+					{Name: "certs", SecretRef: &corev1.LocalObjectReference{Name: "certs"}},
+				}
+				return newCluster
+			}(),
+			wantErrors: 0,
+		},
+		{
+			name: "don't allow invalid addons",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.Addons = []KindClusterAddon{
+					{Name: "cni", ConfigMapRef: &corev1.LocalObjectReference{Name: "calico"}},
+					{Name: "cni", ConfigMapRef: &corev1.LocalObjectReference{Name: "cilium"}},
+					{Name: "both", ConfigMapRef: &corev1.LocalObjectReference{Name: "a"}, SecretRef: &corev1.LocalObjectReference{Name: "b"}},
+					{Name: "neither"},
+					{Name: "unnamed", SecretRef: &corev1.LocalObjectReference{}},
+				}
+				return newCluster
+			}(),
+			wantErrors: 4,
+		},
+		{
+			name: "don't allow an even number of control plane nodes",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.Replicas = 2
+				return newCluster
+			}(),
+			wantErrors: 1,
+		},
+		{
+			name: "don't allow a malformed image",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.Image = "Not A Valid Image"
+				return newCluster
+			}(),
+			wantErrors: 1,
+		},
+		{
+			name: "don't allow a tag in the image",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.Image = "kindest/node:v1.21.2"
+				return newCluster
+			}(),
+			wantErrors: 1,
+		},
+		{
+			name: "don't allow an unpublished version of the default image",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.Version = "v1.99.0"
+				return newCluster
+			}(),
+			wantErrors: 1,
+		},
+		{
+			name: "allow any version of a custom image",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.Image = "example.com/custom/node"
+				newCluster.Spec.Version = "v1.99.0"
+				return newCluster
+			}(),
+			wantErrors: 0,
+		},
+		{
+			name: "don't allow unknown feature gates",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.FeatureGates = map[string]bool{
+					"EphemeralContainers": true,
+					"NotARealFeature":     true,
+				}
+				return newCluster
+			}(),
+			wantErrors: 1,
+		},
+		{
+			name: "allow labels, taints and kubelet args on nodes",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.Nodes = []KindNode{
+					{
+						Role:   NodeRoleWorker,
+						Labels: map[string]string{"example.com/role": "ingress"},
+						Taints: []corev1.Taint{
+							{Key: "example.com/dedicated", Value: "ingress", Effect: corev1.TaintEffectNoSchedule},
+						},
+						KubeletExtraArgs: map[string]string{"max-pods": "50"},
+					},
+				}
+				return newCluster
+			}(),
+			wantErrors: 0,
+		},
+		{
+			name: "don't allow replicas to differ from control plane nodes",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.Replicas = 3
+				newCluster.Spec.Nodes = []KindNode{
+					{Role: NodeRoleControlPlane, Count: 1},
+				}
+				return newCluster
+			}(),
+			wantErrors: 1,
+		},
+		{
+			name: "don't allow invalid labels and taints",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.Nodes = []KindNode{
+					{
+						Role:   NodeRoleWorker,
+						Labels: map[string]string{"not a label": "value"},
+						Taints: []corev1.Taint{
+							{Key: "example.com/dedicated", Effect: "Sometimes"},
+						},
+					},
+				}
+				return newCluster
+			}(),
+			wantErrors: 2,
+		},
+		{
+			name: "don't allow labels or taints via kubelet args",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.Nodes = []KindNode{
+					{
+						Role:             NodeRoleWorker,
+						KubeletExtraArgs: map[string]string{"node-labels": "example=true"},
+					},
+				}
+				return newCluster
+			}(),
+			wantErrors: 1,
+		},
+		{
+			name: "allow raw kind config",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.KindConfig = &KindConfigSource{
+					Inline: `kind: Cluster
+apiVersion: kind.x-k8s.io/v1alpha4
+networking:
+  disableDefaultCNI: true
+nodes:
+- extraPortMappings:
+  - containerPort: 80
+    hostPort: 8080`,
+				}
+				return newCluster
+			}(),
+			wantErrors: 0,
+		},
+		{
+			name: "don't allow malformed raw kind config",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.KindConfig = &KindConfigSource{
+					Inline: `kind: Cluster
+apiVersion: kind.x-k8s.io/v1alpha4
+notAField: true`,
+				}
+				return newCluster
+			}(),
+			wantErrors: 1,
+		},
+		{
+			name: "don't allow raw kind config to conflict with spec",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.FeatureGates = map[string]bool{"EphemeralContainers": true}
+				newCluster.Spec.KindConfig = &KindConfigSource{
+					Inline: `kind: Cluster
+apiVersion: kind.x-k8s.io/v1alpha4
+name: other
+featureGates:
+  EphemeralContainers: false`,
+				}
+				return newCluster
+			}(),
+			wantErrors: 2,
+		},
+		{
+			name: "don't allow both inline and configMap raw kind config",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.KindConfig = &KindConfigSource{
+					Inline: `kind: Cluster
+apiVersion: kind.x-k8s.io/v1alpha4`,
+					ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "kind-config"},
+						Key:                  "config.yaml",
+					},
+				}
+				return newCluster
+			}(),
+			wantErrors: 1,
+		},
+		{
+			name: "return all errors at once",
+			newCluster: func() *KindCluster {
+				newCluster := validCluster.DeepCopy()
+				newCluster.Spec.Replicas = 4
+				newCluster.Spec.Version = "v1.99.0"
+				newCluster.Spec.FeatureGates = map[string]bool{
+					"NotARealFeature": true,
+				}
+				return newCluster
+			}(),
+			wantErrors: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The mutating webhook always runs before validation
+			tt.newCluster.Default()
+			err := tt.newCluster.ValidateCreate()
+			if tt.wantErrors == 0 {
+				if err != nil {
+					t.Errorf("unexpected error - %+v", err)
+				}
+				return
+			}
+
+			statusErr, ok := err.(*apierrors.StatusError)
+			if !ok {
+				t.Fatalf("was expecting a status error, got %+v", err)
+			}
+			if len(statusErr.ErrStatus.Details.Causes) != tt.wantErrors {
+				t.Errorf("unexpected result - wanted %d errors, got %+v", tt.wantErrors, statusErr.ErrStatus.Details.Causes)
+			}
+		})
+	}
+}
+
 func TestKindClusterUpdateInvalid(t *testing.T) {
 	oldCluster := KindCluster{
 		ObjectMeta: metav1.ObjectMeta{},
 		Spec: KindClusterSpec{
-			Replicas:      1,
+			Name:     "default-test-cluster",
+			Replicas: 1,
+			Nodes: []KindNode{
+				{Role: NodeRoleControlPlane, Count: 1},
+			},
 			Image:         "kindest/node",
 			Version:       "v1.21.2",
 			FeatureGates:  map[string]bool{},
@@ -84,6 +777,110 @@ func TestKindClusterUpdateInvalid(t *testing.T) {
 			}(),
 			wantError: true,
 		},
+		{
+			name: "don't allow modification of name",
+			newCluster: func() *KindCluster {
+				newCluster := oldCluster.DeepCopy()
+				newCluster.Spec.Name = "other-name"
+				return newCluster
+			}(),
+			wantError: true,
+		},
+		{
+			name: "don't allow modification of nodes",
+			newCluster: func() *KindCluster {
+				newCluster := oldCluster.DeepCopy()
+				newCluster.Spec.Nodes = append(newCluster.Spec.Nodes, KindNode{Role: NodeRoleWorker, Count: 1})
+				return newCluster
+			}(),
+			wantError: true,
+		},
+		{
+			name: "don't allow modification of kindConfig",
+			newCluster: func() *KindCluster {
+				newCluster := oldCluster.DeepCopy()
+				newCluster.Spec.KindConfig = &KindConfigSource{
+					Inline: `kind: Cluster
+apiVersion: kind.x-k8s.io/v1alpha4`,
+				}
+				return newCluster
+			}(),
+			wantError: true,
+		},
+		{
+			name: "allow networking to be defaulted",
+			newCluster: func() *KindCluster {
+				newCluster := oldCluster.DeepCopy()
+				newCluster.Spec.Networking.PodSubnet = "10.244.0.0/16"
+				return newCluster
+			}(),
+			wantError: false,
+		},
+		{
+			name: "allow the TTL to be extended",
+			newCluster: func() *KindCluster {
+				newCluster := oldCluster.DeepCopy()
+				newCluster.Spec.TTL = &metav1.Duration{Duration: 8 * time.Hour}
+				return newCluster
+			}(),
+			wantError: false,
+		},
+		{
+			name: "don't allow a zero TTL",
+			newCluster: func() *KindCluster {
+				newCluster := oldCluster.DeepCopy()
+				newCluster.Spec.TTL = &metav1.Duration{}
+				return newCluster
+			}(),
+			wantError: true,
+		},
+		{
+			name: "don't allow modification of waitForReady",
+			newCluster: func() *KindCluster {
+				newCluster := oldCluster.DeepCopy()
+				newCluster.Spec.WaitForReady = &metav1.Duration{Duration: 5 * time.Minute}
+				return newCluster
+			}(),
+			wantError: true,
+		},
+		{
+			name: "don't allow modification of snapshotRef",
+			newCluster: func() *KindCluster {
+				newCluster := oldCluster.DeepCopy()
+				newCluster.Spec.SnapshotRef = &corev1.LocalObjectReference{Name: "test-snapshot"}
+				return newCluster
+			}(),
+			wantError: true,
+		},
+		{
+			name: "allow addons to be added",
+			newCluster: func() *KindCluster {
+				newCluster := oldCluster.DeepCopy()
+				newCluster.Spec.Addons = []KindClusterAddon{
+					{Name: "cni", ConfigMapRef: &corev1.LocalObjectReference{Name: "calico"}},
+				}
+				return newCluster
+			}(),
+			wantError: false,
+		},
+		{
+			name: "don't allow invalid addons",
+			newCluster: func() *KindCluster {
+				newCluster := oldCluster.DeepCopy()
+				newCluster.Spec.Addons = []KindClusterAddon{{Name: "cni"}}
+				return newCluster
+			}(),
+			wantError: true,
+		},
+		{
+			name: "don't allow modification of disableDefaultCNI",
+			newCluster: func() *KindCluster {
+				newCluster := oldCluster.DeepCopy()
+				newCluster.Spec.Networking.DisableDefaultCNI = true
+				return newCluster
+			}(),
+			wantError: true,
+		},
 		{
 			name: "don't allow modification of runtimeConfig",
 			newCluster: func() *KindCluster {
@@ -105,3 +902,53 @@ func TestKindClusterUpdateInvalid(t *testing.T) {
 		})
 	}
 }
+
+func TestKindClusterUpdateIPFamily(t *testing.T) {
+	// existingCluster returns a cluster created before the IP family was defaulted
+	existingCluster := func(networking KindClusterNetworking) *KindCluster {
+		return &KindCluster{
+			Spec: KindClusterSpec{
+				Name:       "default-test-cluster",
+				Replicas:   1,
+				Nodes:      []KindNode{{Role: NodeRoleControlPlane, Count: 1}},
+				Image:      "kindest/node",
+				Version:    "v1.21.2",
+				Networking: networking,
+			},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		oldCluster *KindCluster
+		// update changes the new cluster, which is then defaulted as the mutating webhook also runs on update
+		update    func(newCluster *KindCluster)
+		wantError bool
+	}{
+		{
+			name:       "allow defaulting on existing clusters",
+			oldCluster: existingCluster(KindClusterNetworking{PodSubnet: "fd00:10:244::/56", ServiceSubnet: "fd00:10:96::/112"}),
+			update:     func(newCluster *KindCluster) {},
+			wantError:  false,
+		},
+		{
+			name:       "don't allow modification",
+			oldCluster: existingCluster(KindClusterNetworking{IPFamily: IPFamilyIPv4}),
+			update: func(newCluster *KindCluster) {
+				newCluster.Spec.Networking.IPFamily = IPFamilyIPv6
+			},
+			wantError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newCluster := tt.oldCluster.DeepCopy()
+			tt.update(newCluster)
+			newCluster.Default()
+			err := newCluster.ValidateUpdate(tt.oldCluster)
+			if (err != nil) != tt.wantError {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tt.wantError, err)
+			}
+		})
+	}
+}
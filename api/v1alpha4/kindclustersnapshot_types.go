@@ -0,0 +1,119 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha4
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KindClusterSnapshotPhase indicates the current phase of a snapshot
+type KindClusterSnapshotPhase string
+
+var (
+	// KindClusterSnapshotPhasePending is the phase used while waiting for the KindCluster to be ready
+	KindClusterSnapshotPhasePending KindClusterSnapshotPhase = ""
+	// KindClusterSnapshotPhaseCreating is the phase used while the Kind server takes the snapshot
+	KindClusterSnapshotPhaseCreating KindClusterSnapshotPhase = "Creating"
+	// KindClusterSnapshotPhaseReady is the phase used once the snapshot has been taken and can be restored
+	KindClusterSnapshotPhaseReady KindClusterSnapshotPhase = "Ready"
+	// KindClusterSnapshotPhaseFailed is the phase used when the snapshot couldn't be taken
+	KindClusterSnapshotPhaseFailed KindClusterSnapshotPhase = "Failed"
+)
+
+// KindClusterSnapshotSpec defines the desired state of KindClusterSnapshot
+type KindClusterSnapshotSpec struct {
+	// ClusterName is the name of the KindCluster, in the same namespace, to take the snapshot of
+	//
+	// The snapshot is taken once the KindCluster is ready. Only clusters with a single
+	// control plane node can be snapshotted.
+	// +kubebuilder:validation:MinLength=1
+	ClusterName string `json:"clusterName"`
+}
+
+// KindClusterSnapshotStatus defines the observed state of KindClusterSnapshot
+type KindClusterSnapshotStatus struct {
+	// Ready indicates if the snapshot has been taken and can be restored
+	// +kubebuilder:default=false
+	Ready bool `json:"ready"`
+
+	// Phase contains details on the current phase of the snapshot (e.g. creating, ready)
+	// +optional
+	Phase *KindClusterSnapshotPhase `json:"phase,omitempty"`
+
+	// Host is the name of the KindHost the snapshot is kept on, clusters restored from
+	// the snapshot are placed on the same host
+	// +optional
+	Host *string `json:"host,omitempty"`
+
+	// Provider is the container runtime the snapshotted cluster ran on
+	// +optional
+	Provider *NodeProvider `json:"provider,omitempty"`
+
+	// Image is the node image of the snapshotted cluster, clusters restored from the
+	// snapshot must use the same image
+	// +optional
+	Image *string `json:"image,omitempty"`
+
+	// FailureMessage describes why the snapshot couldn't be taken
+	// +optional
+	FailureMessage *string `json:"failureMessage,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=`.spec.clusterName`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// KindClusterSnapshot is the Schema for the kindclustersnapshots API
+//
+// The Kind server keeps a copy of the etcd data and service account keys of the KindCluster,
+// which new KindClusters can be restored from. Nothing kept on the nodes' filesystems, such as
+// hostPath or local-path volumes and images loaded into the nodes, is kept or restored.
+type KindClusterSnapshot struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KindClusterSnapshotSpec   `json:"spec,omitempty"`
+	Status KindClusterSnapshotStatus `json:"status,omitempty"`
+}
+
+// SnapshotName returns the name of the snapshot on the Kind server
+func (s *KindClusterSnapshot) SnapshotName() string {
+	return snapshotName(s.Namespace, s.Name)
+}
+
+// snapshotName returns the name of a snapshot on the Kind server, unique across namespaces as the separator can't
+// appear in namespaces or names
+func snapshotName(namespace, name string) string {
+	return fmt.Sprintf("%s_%s", namespace, name)
+}
+
+//+kubebuilder:object:root=true
+
+// KindClusterSnapshotList contains a list of KindClusterSnapshot
+type KindClusterSnapshotList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KindClusterSnapshot `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KindClusterSnapshot{}, &KindClusterSnapshotList{})
+}
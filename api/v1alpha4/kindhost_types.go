@@ -0,0 +1,74 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha4
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KindHostSpec defines the desired state of KindHost
+type KindHostSpec struct {
+	// Endpoint is the URL of the Kind server running on the host (e.g. http://10.0.0.5:3000)
+	//
+	// +kubebuilder:validation:MinLength=1
+	Endpoint string `json:"endpoint"`
+
+	// CredentialsSecretRef references a Secret containing a `token` key that is sent
+	// as a bearer token to authenticate with the Kind server
+	// +optional
+	CredentialsSecretRef *corev1.SecretReference `json:"credentialsSecretRef,omitempty"`
+
+	// MaxClusters limits the number of KindClusters that can be placed on the host
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxClusters *int32 `json:"maxClusters,omitempty"`
+
+	// MaxNodes limits the total number of nodes across all KindClusters placed on the host
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxNodes *int32 `json:"maxNodes,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Endpoint",type=string,JSONPath=`.spec.endpoint`
+//+kubebuilder:printcolumn:name="Max Clusters",type=integer,JSONPath=`.spec.maxClusters`
+//+kubebuilder:printcolumn:name="Max Nodes",type=integer,JSONPath=`.spec.maxNodes`
+
+// KindHost is the Schema for the kindhosts API
+//
+// Each KindHost represents a machine running the Kind server that KindClusters can be placed on.
+type KindHost struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec KindHostSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// KindHostList contains a list of KindHost
+type KindHostList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KindHost `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KindHost{}, &KindHostList{})
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// capacityRetryAfter is how long callers are asked to wait when the host is at its cluster or node limit
+	capacityRetryAfter = 60 * time.Second
+	// queueRetryAfter is how long callers are asked to wait when the creation queue is full or they waited too long
+	queueRetryAfter = 30 * time.Second
+	// queueTimeout is the longest a creation request waits in the queue before being rejected
+	queueTimeout = 60 * time.Second
+)
+
+// busyError indicates the host doesn't currently have capacity for a request
+type busyError struct {
+	reason     string
+	retryAfter time.Duration
+}
+
+func (e *busyError) Error() string {
+	return e.reason
+}
+
+// Limits contains the capacity limits of the host
+//
+// A limit of zero means unlimited.
+type Limits struct {
+	// MaxClusters is the maximum number of clusters that can exist on the host
+	MaxClusters int
+	// MaxNodes is the maximum number of nodes, across all clusters, that can exist on the host
+	MaxNodes int
+	// MaxConcurrentCreates is the maximum number of clusters that can be created at the same time
+	MaxConcurrentCreates int
+	// MaxQueuedCreates is the maximum number of creation requests that can wait for a free slot
+	MaxQueuedCreates int
+}
+
+// admission reserves host capacity for new clusters and limits how many are created at once
+type admission struct {
+	limits Limits
+	// usage returns the node count of each cluster that currently exists on the host
+	usage func() (map[string]int, error)
+
+	mu sync.Mutex
+	// reserved contains the node count of each cluster admitted but not yet created
+	reserved map[string]int
+	queued   int
+	slots    chan struct{}
+}
+
+func newAdmission(limits Limits, usage func() (map[string]int, error)) *admission {
+	a := &admission{
+		limits:   limits,
+		usage:    usage,
+		reserved: map[string]int{},
+	}
+	if limits.MaxConcurrentCreates > 0 {
+		a.slots = make(chan struct{}, limits.MaxConcurrentCreates)
+	}
+	return a
+}
+
+// admit reserves capacity for the cluster and waits for a free creation slot
+//
+// The returned function must be called once the creation has finished to release the slot and reservation.
+func (a *admission) admit(clusterName string, nodes int) (func(), error) {
+	if err := a.reserve(clusterName, nodes); err != nil {
+		return nil, err
+	}
+
+	if a.slots != nil {
+		timer := time.NewTimer(queueTimeout)
+		defer timer.Stop()
+
+		select {
+		case a.slots <- struct{}{}:
+		case <-timer.C:
+			a.mu.Lock()
+			a.queued--
+			delete(a.reserved, clusterName)
+			a.mu.Unlock()
+			return nil, &busyError{reason: "timed out waiting for a free creation slot", retryAfter: queueRetryAfter}
+		}
+
+		a.mu.Lock()
+		a.queued--
+		a.mu.Unlock()
+	}
+
+	return func() {
+		if a.slots != nil {
+			<-a.slots
+		}
+		a.mu.Lock()
+		delete(a.reserved, clusterName)
+		a.mu.Unlock()
+	}, nil
+}
+
+// reserve checks the host has capacity for the cluster, and room in the queue, and records the reservation
+func (a *admission) reserve(clusterName string, nodes int) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.reserved[clusterName]; ok {
+		return &busyError{reason: fmt.Sprintf("cluster %s is already being created", clusterName), retryAfter: queueRetryAfter}
+	}
+
+	if a.limits.MaxClusters > 0 || a.limits.MaxNodes > 0 {
+		existing, err := a.usage()
+		if err != nil {
+			return err
+		}
+
+		totalClusters, totalNodes := len(a.reserved), 0
+		for _, reservedNodes := range a.reserved {
+			totalNodes += reservedNodes
+		}
+		for name, existingNodes := range existing {
+			// Clusters still being created may already be listed by Kind
+			if _, ok := a.reserved[name]; ok {
+				continue
+			}
+			totalClusters++
+			totalNodes += existingNodes
+		}
+
+		if a.limits.MaxClusters > 0 && totalClusters+1 > a.limits.MaxClusters {
+			return &busyError{reason: fmt.Sprintf("host is at its limit of %d clusters", a.limits.MaxClusters), retryAfter: capacityRetryAfter}
+		}
+		if a.limits.MaxNodes > 0 && totalNodes+nodes > a.limits.MaxNodes {
+			return &busyError{reason: fmt.Sprintf("host doesn't have capacity for %d more nodes (limit %d)", nodes, a.limits.MaxNodes), retryAfter: capacityRetryAfter}
+		}
+	}
+
+	if a.slots != nil {
+		if len(a.slots) >= a.limits.MaxConcurrentCreates && a.queued >= a.limits.MaxQueuedCreates {
+			return &busyError{reason: "creation queue is full", retryAfter: queueRetryAfter}
+		}
+		a.queued++
+	}
+
+	a.reserved[clusterName] = nodes
+	return nil
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"testing"
+)
+
+func TestAdmissionLimits(t *testing.T) {
+	tests := []struct {
+		name        string
+		limits      Limits
+		existing    map[string]int
+		reserved    map[string]int
+		nodes       int
+		expectedErr bool
+	}{
+		{
+			name:     "Unlimited",
+			existing: map[string]int{"a": 3, "b": 3},
+			nodes:    3,
+		},
+		{
+			name:     "Under cluster limit",
+			limits:   Limits{MaxClusters: 2},
+			existing: map[string]int{"a": 1},
+			nodes:    1,
+		},
+		{
+			name:        "At cluster limit",
+			limits:      Limits{MaxClusters: 2},
+			existing:    map[string]int{"a": 1},
+			reserved:    map[string]int{"b": 1},
+			nodes:       1,
+			expectedErr: true,
+		},
+		{
+			name:     "Reserved cluster already listed by Kind",
+			limits:   Limits{MaxClusters: 2},
+			existing: map[string]int{"b": 1},
+			reserved: map[string]int{"b": 1},
+			nodes:    1,
+		},
+		{
+			name:        "Over node limit",
+			limits:      Limits{MaxNodes: 4},
+			existing:    map[string]int{"a": 2},
+			nodes:       3,
+			expectedErr: true,
+		},
+		{
+			name:     "Within node limit",
+			limits:   Limits{MaxNodes: 4},
+			existing: map[string]int{"a": 2},
+			nodes:    2,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newAdmission(tc.limits, func() (map[string]int, error) {
+				return tc.existing, nil
+			})
+			for name, nodes := range tc.reserved {
+				a.reserved[name] = nodes
+			}
+
+			release, err := a.admit("new", tc.nodes)
+			if tc.expectedErr {
+				if _, ok := err.(*busyError); !ok {
+					t.Errorf("was expecting a busy error, got %+v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error - %+v", err)
+			}
+
+			release()
+			if _, ok := a.reserved["new"]; ok {
+				t.Errorf("was expecting the reservation to be released")
+			}
+		})
+	}
+}
+
+func TestAdmissionQueue(t *testing.T) {
+	a := newAdmission(Limits{MaxConcurrentCreates: 1, MaxQueuedCreates: 0}, nil)
+
+	release, err := a.admit("first", 1)
+	if err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+
+	if _, err := a.admit("second", 1); err == nil {
+		t.Errorf("was expecting the request to be rejected while the queue is full")
+	}
+
+	if _, err := a.admit("first", 1); err == nil {
+		t.Errorf("was expecting a duplicate request to be rejected")
+	}
+
+	release()
+
+	release, err = a.admit("second", 1)
+	if err != nil {
+		t.Fatalf("unexpected error once a slot was freed - %+v", err)
+	}
+	release()
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// conflictRetryAfter is how long callers are asked to wait when another operation is in progress on the cluster
+const conflictRetryAfter = 10 * time.Second
+
+// conflictError indicates another operation is already in progress on the cluster
+type conflictError struct {
+	clusterName string
+	operation   string
+}
+
+func (e *conflictError) Error() string {
+	return fmt.Sprintf("cluster %s is currently being %s", e.clusterName, e.operation)
+}
+
+// clusterLocks serialises the operations that modify a cluster so, for example, a cluster can't be deleted
+// while Kind is still creating it
+type clusterLocks struct {
+	mu sync.Mutex
+	// inFlight contains the operation currently in progress for each cluster
+	inFlight map[string]string
+}
+
+func newClusterLocks() *clusterLocks {
+	return &clusterLocks{inFlight: map[string]string{}}
+}
+
+// tryLock locks the cluster for the given operation, failing if another operation is already in progress
+//
+// The returned function must be called once the operation has finished to release the lock.
+func (l *clusterLocks) tryLock(clusterName, operation string) (func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if inFlight, ok := l.inFlight[clusterName]; ok {
+		return nil, &conflictError{clusterName: clusterName, operation: inFlight}
+	}
+	l.inFlight[clusterName] = operation
+
+	return func() {
+		l.mu.Lock()
+		delete(l.inFlight, clusterName)
+		l.mu.Unlock()
+	}, nil
+}
@@ -1,40 +1,164 @@
 package server
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/subtle"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/go-logr/logr"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	"github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+	kindClient "github.com/AverageMarcus/cluster-api-provider-kind/internal/client"
 	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/kind"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/kind/types"
 )
 
 var port = "3000"
 
+// operationLogWait is how long a request for an operation's log waits for the operation to start
+const operationLogWait = 30 * time.Second
+
+// Options contains the configuration of the Kind API server
+type Options struct {
+	// NodeProvider is the container runtime used for clusters that don't request one
+	NodeProvider v1alpha4.NodeProvider
+	// Token is required as a bearer token on all requests, if set
+	Token string
+	// Limits contains the capacity limits of the host
+	Limits Limits
+	// Kind manages the clusters, defaults to using Kind on the host
+	Kind kind.KindProvider
+	// KindVerbosity is the highest level of Kind's info messages that are logged
+	KindVerbosity int
+	// StateDir is where the owners of the clusters are recorded and snapshots and log archives are kept, owners aren't
+	// recorded and snapshots and log archives can't be made if empty
+	StateDir string
+	// KubeConfig controls where the kubeconfig of each cluster is written on the host, they're only available from
+	// the server if not set
+	KubeConfig kind.KubeConfigOptions
+	// Logger is used by the server and Kind, defaults to a new zap logger
+	Logger logr.Logger
+}
+
 // Start starts the Kind API server
-func Start() error {
+func Start(opts Options) error {
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.New()
+	}
+	if err := kind.ValidateNodeProvider(opts.NodeProvider); err != nil {
+		return err
+	}
+	if opts.Kind == nil {
+		opts.Kind = kind.New(logger.WithName("kind"), opts.NodeProvider, opts.KindVerbosity, opts.StateDir, opts.KubeConfig)
+	}
+	app := newApp(opts, logger)
+	return app.Listen(fmt.Sprintf(":%s", port))
+}
+
+// newApp builds the Kind API server handlers
+func newApp(opts Options, logger logr.Logger) *fiber.App {
 	app := fiber.New(fiber.Config{DisableStartupMessage: true})
-	logger := zap.New()
-	kind := kind.New(logger)
+	kind := opts.Kind
+	admission := newAdmission(opts.Limits, kind.ClusterNodeCounts)
+	locks := newClusterLocks()
+	logs := newOperationLogs()
+
+	// Metrics don't include cluster details so are served without the token for simpler scraping
+	app.Get("/metrics", metricsHandler)
+
+	app.Use(traceRequests)
+
+	if opts.Token != "" {
+		app.Use(func(c *fiber.Ctx) error {
+			expected := []byte(fmt.Sprintf("Bearer %s", opts.Token))
+			if subtle.ConstantTimeCompare([]byte(c.Get(fiber.HeaderAuthorization)), expected) != 1 {
+				return fiber.ErrUnauthorized
+			}
+			return c.Next()
+		})
+	}
+
+	app.Post("/", func(c *fiber.Ctx) (err error) {
+		done := trackOperation(operationCreate)
+		defer func() { done(err) }()
 
-	app.Post("/", func(c *fiber.Ctx) error {
 		kindCluster := v1alpha4.KindCluster{}
 		if err := c.BodyParser(&kindCluster); err != nil {
 			logger.Error(err, "failed to parse incoming request")
 			return err
 		}
 
-		if err := kind.CreateCluster(&kindCluster); err != nil {
+		if snapshotName := kindCluster.SnapshotName(); snapshotName != "" {
+			if err := checkSnapshotOwner(c, kind, snapshotName); err != nil {
+				return err
+			}
+		}
+
+		unlock, err := locks.tryLock(kindCluster.KindName(), "created")
+		if err != nil {
+			return rejectConflict(c, logger, err)
+		}
+		defer unlock()
+
+		release, err := admission.admit(kindCluster.KindName(), int(kindCluster.NodeCount()))
+		if busy, ok := err.(*busyError); ok {
+			logger.Info("rejected cluster creation", "cluster", kindCluster.KindName(), "reason", busy.reason)
+			setRetryAfter(c, busy.retryAfter)
+			return fiber.NewError(fiber.StatusTooManyRequests, busy.reason)
+		} else if err != nil {
+			logger.Error(err, "failed to check host capacity")
+			return err
+		}
+		defer release()
+
+		// The log outlives the request so mustn't reference fiber's reused buffers
+		log := logs.start(kindCluster.KindName(), utils.CopyString(c.Query("operation")))
+		endSpan := traceKind(c, "CreateCluster", kindCluster.KindName())
+		nodeProvider, err := kind.CreateCluster(&kindCluster, ownerLabels(c, &kindCluster), log.append)
+		endSpan(err)
+		logs.finish(kindCluster.KindName(), log, err)
+		if err != nil {
 			logger.Error(err, "failed to create Kind cluster")
 			return err
 		}
 
-		return nil
+		return c.JSON(nodeProvider)
+	})
+
+	app.Get("/", func(c *fiber.Ctx) (err error) {
+		done := trackOperation(operationList)
+		defer func() { done(err) }()
+
+		endSpan := traceKind(c, "ListClusters", "")
+		clusters, err := kind.ListClusters()
+		endSpan(err)
+		if err != nil {
+			logger.Error(err, "failed to list clusters")
+			return err
+		}
+
+		return c.JSON(filterClusters(c, clusters))
 	})
 
-	app.Get("/:clusterName", func(c *fiber.Ctx) error {
-		isReady, err := kind.IsReady(c.Params("clusterName"))
+	app.Get("/:clusterName", func(c *fiber.Ctx) (err error) {
+		done := trackOperation(operationReady)
+		defer func() { done(err) }()
+
+		if err := checkOwner(c, kind, c.Params("clusterName")); err != nil {
+			return err
+		}
+
+		endSpan := traceKind(c, "IsReady", c.Params("clusterName"))
+		isReady, err := kind.IsReady(c.Params("clusterName"), nodeProvider(c))
+		endSpan(err)
 		if err != nil {
 			logger.Error(err, "failed to check request status")
 			return err
@@ -43,8 +167,17 @@ func Start() error {
 		return c.JSON(isReady)
 	})
 
-	app.Get("/:clusterName/kubeconfig", func(c *fiber.Ctx) error {
-		kubeconfig, err := kind.GetKubeConfig(c.Params("clusterName"))
+	app.Get("/:clusterName/kubeconfig", func(c *fiber.Ctx) (err error) {
+		done := trackOperation(operationKubeConfig)
+		defer func() { done(err) }()
+
+		if err := checkOwner(c, kind, c.Params("clusterName")); err != nil {
+			return err
+		}
+
+		endSpan := traceKind(c, "GetKubeConfig", c.Params("clusterName"))
+		kubeconfig, err := kind.GetKubeConfig(c.Params("clusterName"), nodeProvider(c))
+		endSpan(err)
 		if err != nil {
 			logger.Error(err, "failed to get kubeconfig")
 			return err
@@ -52,8 +185,215 @@ func Start() error {
 		return c.JSON(kubeconfig)
 	})
 
-	app.Delete("/:clusterName", func(c *fiber.Ctx) error {
-		if err := kind.DeleteCluster(c.Params("clusterName")); err != nil {
+	app.Get("/:clusterName/logs", func(c *fiber.Ctx) error {
+		if err := checkOwner(c, kind, c.Params("clusterName")); err != nil {
+			return err
+		}
+
+		log, err := waitForOperationLog(logs, c.Params("clusterName"), c.Query("operation"))
+		if err != nil {
+			return err
+		}
+
+		index := 0
+		if lastEventID, err := strconv.Atoi(c.Get("Last-Event-ID")); err == nil {
+			index = lastEventID + 1
+		}
+
+		c.Set(fiber.HeaderContentType, "text/event-stream")
+		c.Set(fiber.HeaderCacheControl, "no-cache")
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			streamOperationLog(w, log, index)
+		})
+		return nil
+	})
+
+	app.Get("/log-archives/:archiveName", func(c *fiber.Ctx) (err error) {
+		done := trackOperation(operationGetLogArchive)
+		defer func() { done(err) }()
+
+		info, contents, err := kind.OpenLogArchive(c.Params("archiveName"))
+		if err != nil {
+			logger.Error(err, "failed to open log archive")
+			return err
+		}
+		if info == nil {
+			return fiber.NewError(fiber.StatusNotFound, fmt.Sprintf("no log archive found named %s", c.Params("archiveName")))
+		}
+		if err := checkLogArchiveOwner(c, info); err != nil {
+			contents.Close()
+			return err
+		}
+
+		setTarballHeaders(c, info.Name)
+		// The contents are closed once sent
+		return c.SendStream(contents)
+	})
+
+	app.Get("/:clusterName/export-logs", func(c *fiber.Ctx) (err error) {
+		done := trackOperation(operationExportLogs)
+		defer func() { done(err) }()
+
+		if err := checkOwner(c, kind, c.Params("clusterName")); err != nil {
+			return err
+		}
+
+		// The tarball is built before responding so a failure to collect the logs can still be reported
+		tarball := &bytes.Buffer{}
+		endSpan := traceKind(c, "ExportLogs", c.Params("clusterName"))
+		err = kind.ExportLogs(c.Params("clusterName"), nodeProvider(c), tarball)
+		endSpan(err)
+		if err != nil {
+			logger.Error(err, "failed to export logs")
+			return err
+		}
+
+		setTarballHeaders(c, c.Params("clusterName"))
+		return c.Send(tarball.Bytes())
+	})
+
+	app.Post("/:clusterName/export-logs", func(c *fiber.Ctx) (err error) {
+		done := trackOperation(operationArchiveLogs)
+		defer func() { done(err) }()
+
+		if err := checkOwner(c, kind, c.Params("clusterName")); err != nil {
+			return err
+		}
+
+		// The name is copied as it's kept with the archive, after fiber reuses the request's memory
+		clusterName := utils.CopyString(c.Params("clusterName"))
+		endSpan := traceKind(c, "ArchiveLogs", clusterName)
+		archive, err := kind.ArchiveLogs(clusterName, nodeProvider(c))
+		endSpan(err)
+		if err != nil {
+			logger.Error(err, "failed to archive logs")
+			return err
+		}
+
+		return c.JSON(archive)
+	})
+
+	app.Post("/:clusterName/suspend", func(c *fiber.Ctx) (err error) {
+		done := trackOperation(operationSuspend)
+		defer func() { done(err) }()
+
+		if err := checkOwner(c, kind, c.Params("clusterName")); err != nil {
+			return err
+		}
+
+		unlock, err := locks.tryLock(c.Params("clusterName"), "suspended")
+		if err != nil {
+			return rejectConflict(c, logger, err)
+		}
+		defer unlock()
+
+		endSpan := traceKind(c, "SuspendCluster", c.Params("clusterName"))
+		err = kind.SuspendCluster(c.Params("clusterName"), nodeProvider(c))
+		endSpan(err)
+		if err != nil {
+			logger.Error(err, "failed to suspend cluster")
+			return err
+		}
+
+		return nil
+	})
+
+	app.Post("/:clusterName/resume", func(c *fiber.Ctx) (err error) {
+		done := trackOperation(operationResume)
+		defer func() { done(err) }()
+
+		if err := checkOwner(c, kind, c.Params("clusterName")); err != nil {
+			return err
+		}
+
+		unlock, err := locks.tryLock(c.Params("clusterName"), "resumed")
+		if err != nil {
+			return rejectConflict(c, logger, err)
+		}
+		defer unlock()
+
+		endSpan := traceKind(c, "ResumeCluster", c.Params("clusterName"))
+		err = kind.ResumeCluster(c.Params("clusterName"), nodeProvider(c))
+		endSpan(err)
+		if err != nil {
+			logger.Error(err, "failed to resume cluster")
+			return err
+		}
+
+		return nil
+	})
+
+	app.Post("/:clusterName/snapshots/:snapshotName", func(c *fiber.Ctx) (err error) {
+		done := trackOperation(operationSnapshot)
+		defer func() { done(err) }()
+
+		if err := checkSnapshotName(c.Params("snapshotName")); err != nil {
+			return err
+		}
+		if err := checkOwner(c, kind, c.Params("clusterName")); err != nil {
+			return err
+		}
+
+		unlock, err := locks.tryLock(c.Params("clusterName"), "snapshotted")
+		if err != nil {
+			return rejectConflict(c, logger, err)
+		}
+		defer unlock()
+
+		// The names are copied as they are kept with the snapshot, after fiber reuses the request's memory
+		clusterName, snapshotName := utils.CopyString(c.Params("clusterName")), utils.CopyString(c.Params("snapshotName"))
+		endSpan := traceKind(c, "CreateSnapshot", clusterName)
+		snapshot, err := kind.CreateSnapshot(clusterName, snapshotName, nodeProvider(c))
+		endSpan(err)
+		if err != nil {
+			logger.Error(err, "failed to snapshot cluster")
+			return err
+		}
+
+		return c.JSON(snapshot)
+	})
+
+	app.Delete("/snapshots/:snapshotName", func(c *fiber.Ctx) (err error) {
+		done := trackOperation(operationDeleteSnapshot)
+		defer func() { done(err) }()
+
+		if err := checkSnapshotOwner(c, kind, c.Params("snapshotName")); err != nil {
+			return err
+		}
+
+		endSpan := traceKind(c, "DeleteSnapshot", "")
+		err = kind.DeleteSnapshot(c.Params("snapshotName"))
+		endSpan(err)
+		if err != nil {
+			logger.Error(err, "failed to delete snapshot")
+			return err
+		}
+
+		return nil
+	})
+
+	app.Delete("/:clusterName", func(c *fiber.Ctx) (err error) {
+		done := trackOperation(operationDelete)
+		defer func() { done(err) }()
+
+		// The log outlives the request so mustn't reference fiber's reused buffers
+		clusterName := utils.CopyString(c.Params("clusterName"))
+		if err := checkOwner(c, kind, clusterName); err != nil {
+			return err
+		}
+
+		unlock, err := locks.tryLock(clusterName, "deleted")
+		if err != nil {
+			return rejectConflict(c, logger, err)
+		}
+		defer unlock()
+
+		log := logs.start(clusterName, utils.CopyString(c.Query("operation")))
+		endSpan := traceKind(c, "DeleteCluster", clusterName)
+		err = kind.DeleteCluster(clusterName, nodeProvider(c), log.append)
+		endSpan(err)
+		logs.finish(clusterName, log, err)
+		if err != nil {
 			logger.Error(err, "failed to delete cluster")
 			return err
 		}
@@ -61,5 +401,145 @@ func Start() error {
 		return nil
 	})
 
-	return app.Listen(fmt.Sprintf(":%s", port))
+	return app
+}
+
+// waitForOperationLog returns the log of the cluster's operation with the given ID, waiting a short time for the
+// operation to start as the log may be requested before the operation itself
+func waitForOperationLog(logs *operationLogs, clusterName, id string) (*operationLog, error) {
+	timeout := time.NewTimer(operationLogWait)
+	defer timeout.Stop()
+
+	for {
+		log, started := logs.get(clusterName, id)
+		if log != nil {
+			return log, nil
+		}
+
+		select {
+		case <-started:
+		case <-timeout.C:
+			return nil, fiber.NewError(fiber.StatusNotFound, fmt.Sprintf("no operation found for cluster %s", clusterName))
+		}
+	}
+}
+
+// streamOperationLog writes the log as server-sent events, starting from the given line, until the operation is done
+//
+// Each line is sent as a message with its index as the ID, the end of the operation is sent as a `done` event with
+// any error as its data.
+func streamOperationLog(w *bufio.Writer, log *operationLog, index int) {
+	for {
+		lines, next, done, errMessage, changed := log.since(index)
+		for i, line := range lines {
+			writeEvent(w, strconv.Itoa(next-len(lines)+i), "", line)
+		}
+		index = next
+
+		if done {
+			writeEvent(w, "", "done", errMessage)
+		}
+		if err := w.Flush(); err != nil || done {
+			// The caller has gone away or there is nothing more to send
+			return
+		}
+
+		<-changed
+	}
+}
+
+// writeEvent writes a single server-sent event
+func writeEvent(w *bufio.Writer, id, event, data string) {
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	if event != "" {
+		fmt.Fprintf(w, "event: %s\n", event)
+	}
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(w, "data: %s\n", line)
+	}
+	fmt.Fprint(w, "\n")
+}
+
+// setTarballHeaders marks the response as a gzipped tarball to be saved with the given name
+func setTarballHeaders(c *fiber.Ctx, name string) {
+	c.Set(fiber.HeaderContentType, "application/gzip")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.tar.gz"`, name))
+}
+
+// nodeProvider returns the node provider requested by the caller, if any
+func nodeProvider(c *fiber.Ctx) v1alpha4.NodeProvider {
+	return v1alpha4.NodeProvider(c.Query("provider"))
+}
+
+// ownerLabels returns the labels recorded against a new cluster, identifying the management cluster making the
+// request if it sent its ID
+//
+// The header is copied as fiber reuses the request's memory once the handler returns.
+func ownerLabels(c *fiber.Ctx, kindCluster *v1alpha4.KindCluster) map[string]string {
+	return kind.OwnerLabels(kindCluster, utils.CopyString(c.Get(kindClient.ManagementClusterHeader)))
+}
+
+// filterClusters limits the clusters to those of the management cluster making the request, if it sent its ID
+func filterClusters(c *fiber.Ctx, clusters []types.ClusterInfo) []types.ClusterInfo {
+	return kind.FilterByManagementCluster(clusters, c.Get(kindClient.ManagementClusterHeader))
+}
+
+// checkOwner responds with 403 Forbidden when the cluster was created by a different management cluster to the one
+// making the request
+func checkOwner(c *fiber.Ctx, provider kind.KindProvider, clusterName string) error {
+	labels, err := provider.ClusterLabels(clusterName)
+	if err != nil {
+		return err
+	}
+	if !kind.OwnedBy(labels, c.Get(kindClient.ManagementClusterHeader)) {
+		return fiber.NewError(fiber.StatusForbidden, fmt.Sprintf("cluster %s belongs to another management cluster", clusterName))
+	}
+	return nil
+}
+
+// checkSnapshotOwner responds with 403 Forbidden when the snapshot was taken of a cluster created by a different
+// management cluster to the one making the request, or 400 Bad Request when the snapshot name isn't valid
+func checkSnapshotOwner(c *fiber.Ctx, provider kind.KindProvider, snapshotName string) error {
+	if err := checkSnapshotName(snapshotName); err != nil {
+		return err
+	}
+	snapshot, err := provider.GetSnapshot(snapshotName)
+	if err != nil {
+		return err
+	}
+	if snapshot != nil && !kind.OwnedBy(snapshot.Labels, c.Get(kindClient.ManagementClusterHeader)) {
+		return fiber.NewError(fiber.StatusForbidden, fmt.Sprintf("snapshot %s belongs to another management cluster", snapshotName))
+	}
+	return nil
+}
+
+// checkSnapshotName responds with 400 Bad Request when the snapshot name could reach outside the snapshot directory
+func checkSnapshotName(snapshotName string) error {
+	if err := kind.ValidateSnapshotName(snapshotName); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return nil
+}
+
+// checkLogArchiveOwner responds with 403 Forbidden when the logs were collected from a cluster created by a
+// different management cluster to the one making the request
+func checkLogArchiveOwner(c *fiber.Ctx, archive *types.LogArchiveInfo) error {
+	if !kind.OwnedBy(archive.Labels, c.Get(kindClient.ManagementClusterHeader)) {
+		return fiber.NewError(fiber.StatusForbidden, fmt.Sprintf("log archive %s belongs to another management cluster", archive.Name))
+	}
+	return nil
+}
+
+// rejectConflict responds with 409 Conflict when another operation is in progress on the cluster
+func rejectConflict(c *fiber.Ctx, logger logr.Logger, err error) error {
+	logger.Info("rejected conflicting operation", "reason", err.Error())
+	setRetryAfter(c, conflictRetryAfter)
+	return fiber.NewError(fiber.StatusConflict, err.Error())
+}
+
+// setRetryAfter tells the caller how long to wait before retrying the request
+func setRetryAfter(c *fiber.Ctx, retryAfter time.Duration) {
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(retryAfter.Seconds())))
 }
@@ -0,0 +1,428 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+	kindClient "github.com/AverageMarcus/cluster-api-provider-kind/internal/client"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/kind"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/kind/fake"
+)
+
+// blockingKind wraps the in-memory fake so creations block until released
+type blockingKind struct {
+	*fake.Kind
+
+	mu sync.Mutex
+	// creating is signalled when a creation starts
+	creating chan string
+	// release unblocks in-flight creations
+	release chan struct{}
+	// deletedWhileCreating records clusters deleted while their creation was still in progress
+	deletedWhileCreating []string
+	inFlight             map[string]bool
+}
+
+func newBlockingKind() *blockingKind {
+	return &blockingKind{
+		Kind:     fake.New(),
+		creating: make(chan string, 10),
+		release:  make(chan struct{}),
+		inFlight: map[string]bool{},
+	}
+}
+
+func (k *blockingKind) CreateCluster(kindCluster *v1alpha4.KindCluster, labels map[string]string, progress kind.Progress) (v1alpha4.NodeProvider, error) {
+	k.mu.Lock()
+	k.inFlight[kindCluster.Spec.Name] = true
+	k.mu.Unlock()
+
+	k.creating <- kindCluster.Spec.Name
+	<-k.release
+
+	k.mu.Lock()
+	delete(k.inFlight, kindCluster.Spec.Name)
+	k.mu.Unlock()
+	return k.Kind.CreateCluster(kindCluster, labels, progress)
+}
+
+func (k *blockingKind) DeleteCluster(clusterName string, nodeProvider v1alpha4.NodeProvider, progress kind.Progress) error {
+	k.mu.Lock()
+	if k.inFlight[clusterName] {
+		k.deletedWhileCreating = append(k.deletedWhileCreating, clusterName)
+	}
+	k.mu.Unlock()
+	return k.Kind.DeleteCluster(clusterName, nodeProvider, progress)
+}
+
+func createRequest(t *testing.T, clusterName string) *http.Request {
+	return createOperationRequest(t, clusterName, "")
+}
+
+func createOperationRequest(t *testing.T, clusterName, operationID string) *http.Request {
+	payload, err := json.Marshal(v1alpha4.KindCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterName},
+		Spec: v1alpha4.KindClusterSpec{
+			Name:  clusterName,
+			Nodes: []v1alpha4.KindNode{{Role: v1alpha4.NodeRoleControlPlane, Count: 1}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to build request - %+v", err)
+	}
+	req := httptest.NewRequest("POST", "/?operation="+operationID, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func TestStartUnsupportedNodeProvider(t *testing.T) {
+	err := Start(Options{NodeProvider: v1alpha4.NodeProviderNerdctl, Kind: fake.New(), Logger: logr.Discard()})
+	if err == nil {
+		t.Errorf("was expecting an error")
+	}
+}
+
+func TestConcurrentCreateAndDelete(t *testing.T) {
+	kindFake := newBlockingKind()
+	app := newApp(Options{Kind: kindFake}, logr.Discard())
+
+	createStatus := make(chan int)
+	go func() {
+		resp, err := app.Test(createRequest(t, "test-cluster"), -1)
+		if err != nil {
+			t.Errorf("unexpected error - %+v", err)
+			createStatus <- 0
+			return
+		}
+		createStatus <- resp.StatusCode
+	}()
+	<-kindFake.creating
+
+	resp, err := app.Test(httptest.NewRequest("DELETE", "/test-cluster", nil), -1)
+	if err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("unexpected result - wanted %+v, got %+v", http.StatusConflict, resp.StatusCode)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Errorf("was expecting a Retry-After header")
+	}
+
+	resp, err = app.Test(createRequest(t, "test-cluster"), -1)
+	if err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("unexpected result - wanted %+v, got %+v", http.StatusConflict, resp.StatusCode)
+	}
+
+	// Operations on other clusters aren't blocked
+	resp, err = app.Test(httptest.NewRequest("DELETE", "/other-cluster", nil), -1)
+	if err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("unexpected result - wanted %+v, got %+v", http.StatusOK, resp.StatusCode)
+	}
+
+	close(kindFake.release)
+	if status := <-createStatus; status != http.StatusOK {
+		t.Errorf("unexpected result - wanted %+v, got %+v", http.StatusOK, status)
+	}
+
+	resp, err = app.Test(httptest.NewRequest("DELETE", "/test-cluster", nil), -1)
+	if err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("unexpected result - wanted %+v, got %+v", http.StatusOK, resp.StatusCode)
+	}
+
+	if len(kindFake.deletedWhileCreating) != 0 {
+		t.Errorf("clusters were deleted while being created - %+v", kindFake.deletedWhileCreating)
+	}
+}
+
+func TestConcurrentCreates(t *testing.T) {
+	kindFake := newBlockingKind()
+	app := newApp(Options{Kind: kindFake}, logr.Discard())
+
+	clusterNames := []string{"cluster-a", "cluster-b", "cluster-c"}
+	statuses := make(chan int, len(clusterNames))
+	for _, clusterName := range clusterNames {
+		go func(clusterName string) {
+			resp, err := app.Test(createRequest(t, clusterName), -1)
+			if err != nil {
+				t.Errorf("unexpected error - %+v", err)
+				statuses <- 0
+				return
+			}
+			statuses <- resp.StatusCode
+		}(clusterName)
+	}
+	for range clusterNames {
+		<-kindFake.creating
+	}
+	close(kindFake.release)
+
+	for range clusterNames {
+		if status := <-statuses; status != http.StatusOK {
+			t.Errorf("unexpected result - wanted %+v, got %+v", http.StatusOK, status)
+		}
+	}
+
+	counts, _ := kindFake.ClusterNodeCounts()
+	if len(counts) != len(clusterNames) {
+		t.Errorf("unexpected result - wanted %+v, got %+v", len(clusterNames), len(counts))
+	}
+}
+
+func TestClusterLifecycle(t *testing.T) {
+	kindFake := fake.New()
+	app := newApp(Options{Kind: kindFake}, logr.Discard())
+
+	tests := []struct {
+		name     string
+		req      *http.Request
+		status   int
+		expected string
+	}{
+		{name: "Create", req: createRequest(t, "test-cluster"), status: http.StatusOK, expected: `"docker"`},
+		{name: "Create duplicate", req: createRequest(t, "test-cluster"), status: http.StatusInternalServerError},
+		{name: "Ready", req: httptest.NewRequest("GET", "/test-cluster", nil), status: http.StatusOK, expected: "true"},
+		{
+			name:   "List",
+			req:    httptest.NewRequest("GET", "/", nil),
+			status: http.StatusOK,
+			expected: `[{"name":"test-cluster","nodeProvider":"docker","nodes":1,"labels":{` +
+				`"kindcluster.cluster.x-k8s.io/capk-version":"dev","kindcluster.cluster.x-k8s.io/name":"test-cluster",` +
+				`"kindcluster.cluster.x-k8s.io/namespace":"","kindcluster.cluster.x-k8s.io/uid":""}}]`,
+		},
+		{name: "Suspend", req: httptest.NewRequest("POST", "/test-cluster/suspend", nil), status: http.StatusOK},
+		{name: "Ready while suspended", req: httptest.NewRequest("GET", "/test-cluster", nil), status: http.StatusOK, expected: "false"},
+		{name: "Resume", req: httptest.NewRequest("POST", "/test-cluster/resume", nil), status: http.StatusOK},
+		{name: "Ready after resume", req: httptest.NewRequest("GET", "/test-cluster", nil), status: http.StatusOK, expected: "true"},
+		{name: "Export logs", req: httptest.NewRequest("GET", "/test-cluster/export-logs", nil), status: http.StatusOK, expected: "logs of test-cluster"},
+		{name: "Archive logs", req: httptest.NewRequest("POST", "/test-cluster/export-logs", nil), status: http.StatusOK},
+		{name: "Log archive", req: httptest.NewRequest("GET", "/log-archives/test-cluster-0", nil), status: http.StatusOK, expected: "logs of test-cluster"},
+		{name: "Unknown log archive", req: httptest.NewRequest("GET", "/log-archives/other-cluster-0", nil), status: http.StatusNotFound},
+		{name: "Snapshot", req: httptest.NewRequest("POST", "/test-cluster/snapshots/test-snapshot", nil), status: http.StatusOK},
+		{name: "Snapshot duplicate", req: httptest.NewRequest("POST", "/test-cluster/snapshots/test-snapshot", nil), status: http.StatusInternalServerError},
+		{name: "Delete snapshot", req: httptest.NewRequest("DELETE", "/snapshots/test-snapshot", nil), status: http.StatusOK},
+		{name: "Snapshot invalid name", req: httptest.NewRequest("POST", "/test-cluster/snapshots/.test-snapshot", nil), status: http.StatusBadRequest},
+		{name: "Delete snapshot invalid name", req: httptest.NewRequest("DELETE", "/snapshots/..", nil), status: http.StatusBadRequest},
+		{name: "Delete", req: httptest.NewRequest("DELETE", "/test-cluster", nil), status: http.StatusOK},
+		{name: "Snapshot after delete", req: httptest.NewRequest("POST", "/test-cluster/snapshots/test-snapshot", nil), status: http.StatusInternalServerError},
+		{name: "Export logs after delete", req: httptest.NewRequest("GET", "/test-cluster/export-logs", nil), status: http.StatusInternalServerError},
+		{name: "Log archive after delete", req: httptest.NewRequest("GET", "/log-archives/test-cluster-0", nil), status: http.StatusOK, expected: "logs of test-cluster"},
+		{name: "Suspend after delete", req: httptest.NewRequest("POST", "/test-cluster/suspend", nil), status: http.StatusInternalServerError},
+		{name: "Ready after delete", req: httptest.NewRequest("GET", "/test-cluster", nil), status: http.StatusInternalServerError},
+		{name: "List after delete", req: httptest.NewRequest("GET", "/", nil), status: http.StatusOK, expected: "[]"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := app.Test(tc.req, -1)
+			if err != nil {
+				t.Fatalf("unexpected error - %+v", err)
+			}
+			if resp.StatusCode != tc.status {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tc.status, resp.StatusCode)
+			}
+			if tc.expected != "" {
+				body, _ := ioutil.ReadAll(resp.Body)
+				if string(body) != tc.expected {
+					t.Errorf("unexpected result - wanted %+v, got %+v", tc.expected, string(body))
+				}
+			}
+		})
+	}
+}
+
+func TestManagementClusterOwnership(t *testing.T) {
+	kindFake := fake.New()
+	app := newApp(Options{Kind: kindFake}, logr.Discard())
+
+	create := createRequest(t, "test-cluster")
+	create.Header.Set(kindClient.ManagementClusterHeader, "mc-1")
+	if resp, err := app.Test(create, -1); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("failed to create cluster - %+v %+v", resp, err)
+	}
+	kindFake.Add("legacy-cluster", fake.Cluster{NodeProvider: v1alpha4.NodeProviderDocker, Nodes: 1})
+
+	withManagementCluster := func(req *http.Request, managementClusterID string) *http.Request {
+		if managementClusterID != "" {
+			req.Header.Set(kindClient.ManagementClusterHeader, managementClusterID)
+		}
+		return req
+	}
+
+	tests := []struct {
+		name                string
+		method              string
+		path                string
+		managementClusterID string
+		status              int
+		expected            string
+	}{
+		{name: "Ready by owner", method: "GET", path: "/test-cluster", managementClusterID: "mc-1", status: http.StatusOK},
+		{name: "Ready by other", method: "GET", path: "/test-cluster", managementClusterID: "mc-2", status: http.StatusForbidden},
+		{name: "Ready without ID", method: "GET", path: "/test-cluster", status: http.StatusOK},
+		{name: "Ready of legacy cluster", method: "GET", path: "/legacy-cluster", managementClusterID: "mc-2", status: http.StatusOK},
+		{name: "KubeConfig by other", method: "GET", path: "/test-cluster/kubeconfig", managementClusterID: "mc-2", status: http.StatusForbidden},
+		{name: "Operation logs by other", method: "GET", path: "/test-cluster/logs", managementClusterID: "mc-2", status: http.StatusForbidden},
+		{name: "Operation logs by owner", method: "GET", path: "/test-cluster/logs", managementClusterID: "mc-1", status: http.StatusOK},
+		{name: "List by owner", method: "GET", path: "/", managementClusterID: "mc-1", status: http.StatusOK, expected: "test-cluster"},
+		{name: "List by other", method: "GET", path: "/", managementClusterID: "mc-2", status: http.StatusOK, expected: "[]"},
+		{name: "Archive logs by other", method: "POST", path: "/test-cluster/export-logs", managementClusterID: "mc-2", status: http.StatusForbidden},
+		{name: "Archive logs by owner", method: "POST", path: "/test-cluster/export-logs", managementClusterID: "mc-1", status: http.StatusOK},
+		{name: "Log archive by other", method: "GET", path: "/log-archives/test-cluster-0", managementClusterID: "mc-2", status: http.StatusForbidden},
+		{name: "Log archive by owner", method: "GET", path: "/log-archives/test-cluster-0", managementClusterID: "mc-1", status: http.StatusOK},
+		{name: "Snapshot by other", method: "POST", path: "/test-cluster/snapshots/test-snapshot", managementClusterID: "mc-2", status: http.StatusForbidden},
+		{name: "Snapshot by owner", method: "POST", path: "/test-cluster/snapshots/test-snapshot", managementClusterID: "mc-1", status: http.StatusOK},
+		{name: "Delete snapshot by other", method: "DELETE", path: "/snapshots/test-snapshot", managementClusterID: "mc-2", status: http.StatusForbidden},
+		{name: "Delete snapshot by owner", method: "DELETE", path: "/snapshots/test-snapshot", managementClusterID: "mc-1", status: http.StatusOK},
+		{name: "Delete by other", method: "DELETE", path: "/test-cluster", managementClusterID: "mc-2", status: http.StatusForbidden},
+		{name: "Delete by owner", method: "DELETE", path: "/test-cluster", managementClusterID: "mc-1", status: http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := app.Test(withManagementCluster(httptest.NewRequest(tc.method, tc.path, nil), tc.managementClusterID), -1)
+			if err != nil {
+				t.Fatalf("unexpected error - %+v", err)
+			}
+			if resp.StatusCode != tc.status {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tc.status, resp.StatusCode)
+			}
+			if tc.expected != "" {
+				body, _ := ioutil.ReadAll(resp.Body)
+				if !strings.Contains(string(body), tc.expected) {
+					t.Errorf("unexpected result - wanted %+v, got %+v", tc.expected, string(body))
+				}
+			}
+		})
+	}
+}
+
+func TestOperationLogs(t *testing.T) {
+	app := newApp(Options{Kind: fake.New()}, logr.Discard())
+
+	resp, err := app.Test(createOperationRequest(t, "test-cluster", "op-1"), -1)
+	if err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected result - wanted %+v, got %+v", http.StatusOK, resp.StatusCode)
+	}
+
+	tests := []struct {
+		name        string
+		query       string
+		lastEventID string
+		status      int
+		expected    string
+	}{
+		{
+			name:     "Matching operation",
+			query:    "?operation=op-1",
+			status:   http.StatusOK,
+			expected: "id: 0\ndata: Ensuring node image\n\nid: 1\ndata: Preparing nodes\n\nid: 2\ndata: Starting control-plane\n\nevent: done\ndata: \n\n",
+		},
+		{
+			name:        "Resume after last event",
+			query:       "?operation=op-1",
+			lastEventID: "1",
+			status:      http.StatusOK,
+			expected:    "id: 2\ndata: Starting control-plane\n\nevent: done\ndata: \n\n",
+		},
+		{
+			name:     "Latest operation",
+			status:   http.StatusOK,
+			expected: "id: 0\ndata: Ensuring node image\n\nid: 1\ndata: Preparing nodes\n\nid: 2\ndata: Starting control-plane\n\nevent: done\ndata: \n\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/test-cluster/logs"+tc.query, nil)
+			if tc.lastEventID != "" {
+				req.Header.Set("Last-Event-ID", tc.lastEventID)
+			}
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatalf("unexpected error - %+v", err)
+			}
+			if resp.StatusCode != tc.status {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tc.status, resp.StatusCode)
+			}
+			body, _ := ioutil.ReadAll(resp.Body)
+			if string(body) != tc.expected {
+				t.Errorf("unexpected result - wanted %q, got %q", tc.expected, string(body))
+			}
+		})
+	}
+}
+
+func TestOperationLogRetention(t *testing.T) {
+	previous := operationLogRetention
+	operationLogRetention = 10 * time.Millisecond
+	defer func() { operationLogRetention = previous }()
+
+	logs := newOperationLogs()
+	finished := logs.start("finished-cluster", "op-1")
+	logs.finish("finished-cluster", finished, nil)
+	replaced := logs.start("replaced-cluster", "op-1")
+	logs.finish("replaced-cluster", replaced, nil)
+	latest := logs.start("replaced-cluster", "op-2")
+
+	// Finished logs are kept for followers that connect late
+	if log, _ := logs.get("finished-cluster", "op-1"); log != finished {
+		t.Errorf("was expecting the finished log to be kept - %+v", log)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if log, _ := logs.get("finished-cluster", ""); log != nil {
+		t.Errorf("was expecting the finished log to be removed - %+v", log)
+	}
+	if log, _ := logs.get("replaced-cluster", ""); log != latest {
+		t.Errorf("was expecting the latest operation's log to be kept - %+v", log)
+	}
+}
+
+func TestOperationLogFollow(t *testing.T) {
+	log := newOperationLogs().start("test-cluster", "op-1")
+	log.append("Ensuring node image")
+
+	var buf bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		w := bufio.NewWriter(&buf)
+		streamOperationLog(w, log, 0)
+		close(done)
+	}()
+
+	log.append("Preparing nodes")
+	log.finish(errors.New("failed to start\ncontrol-plane"))
+	<-done
+
+	for _, expected := range []string{"data: Ensuring node image\n", "data: Preparing nodes\n", "event: done\ndata: failed to start\ndata: control-plane\n\n"} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("was expecting %q in the stream, got %q", expected, buf.String())
+		}
+	}
+}
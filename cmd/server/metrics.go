@@ -0,0 +1,89 @@
+package server
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+const (
+	operationCreate         = "create"
+	operationDelete         = "delete"
+	operationReady          = "ready"
+	operationKubeConfig     = "kubeconfig"
+	operationList           = "list"
+	operationSuspend        = "suspend"
+	operationResume         = "resume"
+	operationSnapshot       = "snapshot"
+	operationDeleteSnapshot = "delete-snapshot"
+	operationExportLogs     = "export-logs"
+	operationArchiveLogs    = "archive-logs"
+	operationGetLogArchive  = "get-log-archive"
+)
+
+var (
+	// registry contains the metrics of the Kind server
+	registry = prometheus.NewRegistry()
+
+	operationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "capk",
+		Subsystem: "server",
+		Name:      "operation_duration_seconds",
+		Help:      "Time taken by the Kind server to handle each operation, including any time queued.",
+		Buckets:   []float64{0.1, 0.5, 1, 5, 10, 30, 60, 90, 120, 180, 300, 600},
+	}, []string{"operation", "result"})
+
+	operationsInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "capk",
+		Subsystem: "server",
+		Name:      "operations_in_flight",
+		Help:      "Number of operations the Kind server is currently handling.",
+	}, []string{"operation"})
+)
+
+func init() {
+	registry.MustRegister(
+		operationDuration,
+		operationsInFlight,
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+}
+
+// trackOperation counts the operation as in flight until the returned func is called with its result
+func trackOperation(operation string) func(err error) {
+	start := time.Now()
+	operationsInFlight.WithLabelValues(operation).Inc()
+
+	return func(err error) {
+		operationsInFlight.WithLabelValues(operation).Dec()
+
+		result := "success"
+		if err != nil {
+			result = "failure"
+		}
+		operationDuration.WithLabelValues(operation, result).Observe(time.Since(start).Seconds())
+	}
+}
+
+// metricsHandler serves the Kind server metrics in the Prometheus text format
+func metricsHandler(c *fiber.Ctx) error {
+	metricFamilies, err := registry.Gather()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	encoder := expfmt.NewEncoder(&buf, expfmt.FmtText)
+	for _, metricFamily := range metricFamilies {
+		if err := encoder.Encode(metricFamily); err != nil {
+			return err
+		}
+	}
+
+	c.Set(fiber.HeaderContentType, string(expfmt.FmtText))
+	return c.Send(buf.Bytes())
+}
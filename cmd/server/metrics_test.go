@@ -0,0 +1,65 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/kind/fake"
+)
+
+func TestMetrics(t *testing.T) {
+	app := newApp(Options{Kind: fake.New(), Token: "secret"}, logr.Discard())
+
+	authorized := func(req *http.Request) *http.Request {
+		req.Header.Set("Authorization", "Bearer secret")
+		return req
+	}
+	requests := []*http.Request{
+		authorized(createRequest(t, "test-cluster")),
+		authorized(httptest.NewRequest("GET", "/test-cluster", nil)),
+		authorized(httptest.NewRequest("GET", "/missing-cluster/kubeconfig", nil)),
+		authorized(httptest.NewRequest("DELETE", "/test-cluster", nil)),
+	}
+	for _, req := range requests {
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("unexpected error - %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	// Metrics are served without the token
+	resp, err := app.Test(httptest.NewRequest("GET", "/metrics", nil), -1)
+	if err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected result - wanted %+v, got %+v", http.StatusOK, resp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+
+	tests := []string{
+		`capk_server_operation_duration_seconds_count{operation="create",result="success"}`,
+		`capk_server_operation_duration_seconds_count{operation="ready",result="success"}`,
+		`capk_server_operation_duration_seconds_count{operation="kubeconfig",result="failure"}`,
+		`capk_server_operation_duration_seconds_count{operation="delete",result="success"}`,
+		`capk_server_operations_in_flight{operation="create"} 0`,
+		`capk_server_operations_in_flight{operation="delete"} 0`,
+	}
+	for _, want := range tests {
+		t.Run(want, func(t *testing.T) {
+			if !strings.Contains(string(body), want) {
+				t.Errorf("unexpected result - wanted %+v in:\n%s", want, body)
+			}
+		})
+	}
+}
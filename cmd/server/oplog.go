@@ -0,0 +1,125 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// maxOperationLogLines is the most lines kept for each operation, older lines are dropped
+const maxOperationLogLines = 500
+
+// operationLogRetention is how long the log of a finished operation is kept for followers that connect late
+var operationLogRetention = time.Minute
+
+// operationLog buffers the progress messages Kind reports during a single create or delete
+type operationLog struct {
+	id string
+
+	mu sync.Mutex
+	// dropped is the number of lines dropped from the start of the buffer
+	dropped int
+	lines   []string
+	done    bool
+	err     string
+	// changed is closed, and replaced, whenever the log is updated
+	changed chan struct{}
+}
+
+// append adds a line to the log and wakes any followers
+func (l *operationLog) append(line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.lines = append(l.lines, line)
+	if len(l.lines) > maxOperationLogLines {
+		l.lines = l.lines[1:]
+		l.dropped++
+	}
+	l.notify()
+}
+
+// finish marks the operation as done and wakes any followers
+func (l *operationLog) finish(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.done = true
+	if err != nil {
+		l.err = err.Error()
+	}
+	l.notify()
+}
+
+// notify wakes followers, must be called with the lock held
+func (l *operationLog) notify() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// since returns the lines from the given index onwards, the index of the next line, whether the operation
+// is done and a channel closed on the next update
+func (l *operationLog) since(index int) ([]string, int, bool, string, <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if index < l.dropped {
+		index = l.dropped
+	}
+	lines := append([]string{}, l.lines[index-l.dropped:]...)
+	return lines, l.dropped + len(l.lines), l.done, l.err, l.changed
+}
+
+// operationLogs holds the log of the latest operation on each cluster
+type operationLogs struct {
+	mu        sync.Mutex
+	byCluster map[string]*operationLog
+	// started is closed, and replaced, whenever a new operation starts
+	started chan struct{}
+}
+
+func newOperationLogs() *operationLogs {
+	return &operationLogs{
+		byCluster: map[string]*operationLog{},
+		started:   make(chan struct{}),
+	}
+}
+
+// start begins a new log for the cluster, replacing the log of any previous operation
+func (o *operationLogs) start(clusterName, id string) *operationLog {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	log := &operationLog{id: id, changed: make(chan struct{})}
+	o.byCluster[clusterName] = log
+
+	close(o.started)
+	o.started = make(chan struct{})
+	return log
+}
+
+// finish marks the cluster's operation as done, removing its log once the retention period has passed unless
+// another operation on the cluster has replaced it
+func (o *operationLogs) finish(clusterName string, log *operationLog, err error) {
+	log.finish(err)
+	time.AfterFunc(operationLogRetention, func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+
+		if o.byCluster[clusterName] == log {
+			delete(o.byCluster, clusterName)
+		}
+	})
+}
+
+// get returns the log of the cluster's latest operation if it matches the ID, or any latest operation if the
+// ID is empty, along with a channel closed when the next operation starts
+func (o *operationLogs) get(clusterName, id string) (*operationLog, <-chan struct{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	log, ok := o.byCluster[clusterName]
+	if !ok || (id != "" && log.id != id) {
+		return nil, o.started
+	}
+	return log, o.started
+}
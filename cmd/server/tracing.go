@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/tracing"
+)
+
+// instrumentation names the spans created by the Kind server
+const instrumentation = "github.com/AverageMarcus/cluster-api-provider-kind/cmd/server"
+
+// headerCarrier lets the trace context propagator read the request headers
+type headerCarrier struct {
+	c *fiber.Ctx
+}
+
+func (h headerCarrier) Get(key string) string {
+	return h.c.Get(key)
+}
+
+func (h headerCarrier) Set(key, value string) {
+	h.c.Request().Header.Set(key, value)
+}
+
+func (h headerCarrier) Keys() []string {
+	keys := []string{}
+	h.c.Request().Header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
+
+// traceRequests continues the caller's trace, if any, with a span around the whole request
+//
+// The span's context is set as the request's user context so handlers can add their own spans to it.
+func traceRequests(c *fiber.Ctx) (err error) {
+	// Spans are exported after the request so their attributes mustn't reference fiber's reused buffers
+	method, target := utils.CopyString(c.Method()), utils.CopyString(c.OriginalURL())
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), headerCarrier{c})
+	ctx, endSpan := tracing.StartSpan(ctx, instrumentation, "HTTP "+method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.HTTPMethodKey.String(method), semconv.HTTPTargetKey.String(target)),
+	)
+	defer func() { endSpan(err) }()
+	c.SetUserContext(ctx)
+
+	err = c.Next()
+
+	// Errors are only turned into responses once the middleware returns
+	status := c.Response().StatusCode()
+	if fiberErr, ok := err.(*fiber.Error); ok {
+		status = fiberErr.Code
+	} else if err != nil {
+		status = fiber.StatusInternalServerError
+	}
+
+	span := trace.SpanFromContext(ctx)
+	span.SetName(method + " " + c.Route().Path)
+	span.SetAttributes(semconv.HTTPRouteKey.String(c.Route().Path), semconv.HTTPStatusCodeKey.Int(status))
+	return err
+}
+
+// traceKind starts a span around a call to Kind, for the named cluster if not empty
+func traceKind(c *fiber.Ctx, operation, clusterName string) func(err error) {
+	opts := []trace.SpanStartOption{}
+	if clusterName != "" {
+		opts = append(opts, trace.WithAttributes(tracing.ClusterNameKey.String(utils.CopyString(clusterName))))
+	}
+	_, endSpan := tracing.StartSpan(c.UserContext(), instrumentation, "kind."+operation, opts...)
+	return endSpan
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/kind/fake"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	kindFake := fake.New()
+	app := newApp(Options{Kind: kindFake}, logr.Discard())
+	if _, err := kindFake.CreateCluster(&v1alpha4.KindCluster{Spec: v1alpha4.KindClusterSpec{Name: "test-cluster"}}, nil, nil); err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	callerSpanID := "00f067aa0ba902b7"
+	req := httptest.NewRequest("GET", "/test-cluster", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-"+callerSpanID+"-01")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+	resp.Body.Close()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("unexpected result - wanted %+v, got %+v", 2, len(spans))
+	}
+	kindSpan, requestSpan := spans[0], spans[1]
+
+	tests := []struct {
+		name   string
+		wanted string
+		got    string
+	}{
+		{name: "request span name", wanted: "GET /:clusterName", got: requestSpan.Name()},
+		{name: "request span trace", wanted: traceID, got: requestSpan.SpanContext().TraceID().String()},
+		{name: "request span parent", wanted: callerSpanID, got: requestSpan.Parent().SpanID().String()},
+		{name: "kind span name", wanted: "kind.IsReady", got: kindSpan.Name()},
+		{name: "kind span parent", wanted: requestSpan.SpanContext().SpanID().String(), got: kindSpan.Parent().SpanID().String()},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.got != tc.wanted {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tc.wanted, tc.got)
+			}
+		})
+	}
+}
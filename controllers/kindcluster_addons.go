@@ -0,0 +1,242 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrastructurev1alpha4 "github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+)
+
+const (
+	// EventReasonAddonApplied is the reason of the event recording that an addon's manifests were applied
+	EventReasonAddonApplied = "AddonApplied"
+	// EventReasonAddonFailed is the reason of the event recording that an addon's manifests couldn't be applied
+	EventReasonAddonFailed = "AddonFailed"
+
+	// addonFieldManager is the field manager the addon resources are applied as, taking ownership of their fields
+	addonFieldManager = "cluster-api-provider-kind"
+
+	// addonRequeueDelay is how long to wait before trying again to apply addons that failed
+	addonRequeueDelay = 30 * time.Second
+)
+
+// applyAddons applies any of the KindCluster's addons whose manifests haven't been applied to the workload cluster
+// yet, recording the result of each in the status
+//
+// Failing to apply an addon doesn't fail the cluster, false is returned so it can be retried later.
+func (r *KindClusterReconciler) applyAddons(ctx context.Context, kindCluster *infrastructurev1alpha4.KindCluster) bool {
+	log := log.FromContext(ctx)
+
+	previous := map[string]infrastructurev1alpha4.AddonStatus{}
+	for _, status := range kindCluster.Status.Addons {
+		previous[status.Name] = status
+	}
+
+	var workloadClient client.Client
+	allApplied := true
+	statuses := []infrastructurev1alpha4.AddonStatus{}
+	for _, addon := range kindCluster.Spec.Addons {
+		status := previous[addon.Name]
+		status.Name = addon.Name
+
+		objs, hash, err := r.addonManifests(ctx, kindCluster.Namespace, addon)
+		if err == nil && (!status.Applied || status.Hash != hash) {
+			if workloadClient == nil {
+				workloadClient, err = r.workloadClient(kindCluster)
+			}
+			if err == nil {
+				stepCtx, endStep := traceStep(ctx, "applyAddon", kindCluster)
+				err = applyManifests(stepCtx, workloadClient, objs)
+				endStep(err)
+			}
+			if err == nil {
+				log.Info("Applied addon", "addon", addon.Name)
+				r.recordEvent(kindCluster, corev1.EventTypeNormal, EventReasonAddonApplied, fmt.Sprintf("Applied addon %s", addon.Name))
+				status.Hash = hash
+				status.LastAppliedTime = &metav1.Time{Time: time.Now()}
+			}
+		}
+
+		if err != nil {
+			log.Error(err, "failed to apply addon", "addon", addon.Name)
+			// Only report the failure once, it's retried until it succeeds
+			if status.Message != err.Error() {
+				r.recordEvent(kindCluster, corev1.EventTypeWarning, EventReasonAddonFailed, fmt.Sprintf("Failed to apply addon %s: %s", addon.Name, err))
+			}
+			status.Applied = false
+			status.Message = err.Error()
+			allApplied = false
+		} else {
+			status.Applied = true
+			status.Message = ""
+		}
+		statuses = append(statuses, status)
+	}
+
+	if len(statuses) == 0 {
+		statuses = nil
+	}
+	kindCluster.Status.Addons = statuses
+	return allApplied
+}
+
+// addonManifests returns the objects within the addon's ConfigMap or Secret along with a hash of its contents, so
+// changes can be detected
+func (r *KindClusterReconciler) addonManifests(ctx context.Context, namespace string, addon infrastructurev1alpha4.KindClusterAddon) ([]*unstructured.Unstructured, string, error) {
+	data := map[string][]byte{}
+	switch {
+	case addon.ConfigMapRef != nil:
+		configMap := &corev1.ConfigMap{}
+		if err := r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: addon.ConfigMapRef.Name}, configMap); err != nil {
+			return nil, "", err
+		}
+		for key, value := range configMap.Data {
+			data[key] = []byte(value)
+		}
+	case addon.SecretRef != nil:
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: addon.SecretRef.Name}, secret); err != nil {
+			return nil, "", err
+		}
+		data = secret.Data
+	default:
+		return nil, "", fmt.Errorf("one of configMapRef or secretRef must be set")
+	}
+
+	keys := []string{}
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	hash := sha256.New()
+	objs := []*unstructured.Unstructured{}
+	for _, key := range keys {
+		fmt.Fprintf(hash, "%s\x00%d\x00", key, len(data[key]))
+		hash.Write(data[key])
+
+		keyObjs, err := decodeManifests(data[key])
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to decode %s: %w", key, err)
+		}
+		objs = append(objs, keyObjs...)
+	}
+
+	return objs, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// decodeManifests decodes each of the YAML or JSON documents in the manifest, skipping any that are empty
+func decodeManifests(manifest []byte) ([]*unstructured.Unstructured, error) {
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(manifest), 4096)
+
+	objs := []*unstructured.Unstructured{}
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err == io.EOF {
+			return objs, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+		if obj.GetAPIVersion() == "" || obj.GetKind() == "" {
+			return nil, fmt.Errorf("apiVersion and kind must be set on every object")
+		}
+		objs = append(objs, obj)
+	}
+}
+
+// applyManifests server-side applies each of the objects in order, as `kubectl apply --server-side` would, placing
+// namespaced objects without a namespace in the default namespace
+func applyManifests(ctx context.Context, c client.Client, objs []*unstructured.Unstructured) error {
+	for _, obj := range objs {
+		if obj.GetNamespace() == "" {
+			mapping, err := c.RESTMapper().RESTMapping(obj.GroupVersionKind().GroupKind(), obj.GroupVersionKind().Version)
+			if err != nil {
+				return fmt.Errorf("failed to apply %s %s: %w", obj.GetKind(), obj.GetName(), err)
+			}
+			if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+				obj.SetNamespace(metav1.NamespaceDefault)
+			}
+		}
+
+		if err := c.Patch(ctx, obj, client.Apply, client.FieldOwner(addonFieldManager), client.ForceOwnership); err != nil {
+			return fmt.Errorf("failed to apply %s %s: %w", obj.GetKind(), obj.GetName(), err)
+		}
+	}
+	return nil
+}
+
+// workloadClient returns a client of the Kind cluster itself, using the kubeconfig in the status
+func (r *KindClusterReconciler) workloadClient(kindCluster *infrastructurev1alpha4.KindCluster) (client.Client, error) {
+	if kindCluster.Status.KubeConfig == nil {
+		return nil, fmt.Errorf("kubeconfig of cluster isn't available yet")
+	}
+	if r.NewWorkloadClient != nil {
+		return r.NewWorkloadClient(*kindCluster.Status.KubeConfig)
+	}
+
+	config, err := clientcmd.RESTConfigFromKubeConfig([]byte(*kindCluster.Status.KubeConfig))
+	if err != nil {
+		return nil, err
+	}
+	return client.New(config, client.Options{})
+}
+
+// kindClustersForAddonSource returns a request for each KindCluster in the same namespace with an addon referencing
+// the ConfigMap or Secret, so changes to the manifests are applied
+func (r *KindClusterReconciler) kindClustersForAddonSource(obj client.Object) []reconcile.Request {
+	kindClusters := &infrastructurev1alpha4.KindClusterList{}
+	if err := r.List(context.TODO(), kindClusters, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+
+	_, isSecret := obj.(*corev1.Secret)
+	requests := []reconcile.Request{}
+	for _, kindCluster := range kindClusters.Items {
+		for _, addon := range kindCluster.Spec.Addons {
+			ref := addon.ConfigMapRef
+			if isSecret {
+				ref = addon.SecretRef
+			}
+			if ref != nil && ref.Name == obj.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&kindCluster)})
+				break
+			}
+		}
+	}
+	return requests
+}
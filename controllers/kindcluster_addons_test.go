@@ -0,0 +1,282 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrastructurev1alpha4 "github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+	kindFake "github.com/AverageMarcus/cluster-api-provider-kind/internal/client/fake"
+)
+
+// applyClient handles server-side apply patches, which the fake client doesn't support, by creating or updating
+// the object, and maps the core types it's used with
+type applyClient struct {
+	client.Client
+}
+
+func (c applyClient) RESTMapper() meta.RESTMapper {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("Namespace"), meta.RESTScopeRoot)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("ConfigMap"), meta.RESTScopeNamespace)
+	return mapper
+}
+
+func (c applyClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch != client.Apply {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+	if err := c.Get(ctx, client.ObjectKeyFromObject(obj), existing); apierrors.IsNotFound(err) {
+		return c.Create(ctx, obj)
+	} else if err != nil {
+		return err
+	}
+	obj.SetResourceVersion(existing.GetResourceVersion())
+	return c.Update(ctx, obj)
+}
+
+func TestReconcileAddons(t *testing.T) {
+	ctx := context.Background()
+	kindCluster, cluster := newOwnedKindCluster()
+	kindCluster.Spec.Addons = []infrastructurev1alpha4.KindClusterAddon{
+		{Name: "cni", ConfigMapRef: &corev1.LocalObjectReference{Name: "cni"}},
+		{Name: "certs", SecretRef: &corev1.LocalObjectReference{Name: "certs"}},
+	}
+	cni := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "cni", Namespace: "default"},
+		Data: map[string]string{
+			"cni.yaml": `apiVersion: v1
+kind: Namespace
+metadata:
+  name: cni-system
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cni-config
+  namespace: cni-system
+data:
+  mtu: "1440"
+`,
+		},
+	}
+	certs := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "certs", Namespace: "default"},
+		Data: map[string][]byte{
+			"ca.json": []byte(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "ca"}, "data": {"ca.crt": "test"}}`),
+		},
+	}
+
+	r := newTestReconciler(t, kindFake.New(), kindCluster, cluster, newKindHost("host-a", nil, nil, nil), cni, certs)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: kindCluster.Namespace, Name: kindCluster.Name}}
+
+	result, err := r.Reconcile(ctx, req)
+	if err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if result.RequeueAfter != 0 {
+		t.Errorf("unexpected result - wanted %+v, got %+v", 0, result.RequeueAfter)
+	}
+
+	actual := &infrastructurev1alpha4.KindCluster{}
+	if err := r.Get(ctx, req.NamespacedName, actual); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	workload, err := r.NewWorkloadClient(*actual.Status.KubeConfig)
+	if err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if len(actual.Status.Addons) != 2 {
+		t.Fatalf("unexpected result - wanted %+v, got %+v", 2, actual.Status.Addons)
+	}
+	for _, status := range actual.Status.Addons {
+		if !status.Applied || status.Hash == "" || status.LastAppliedTime == nil || status.Message != "" {
+			t.Errorf("was expecting addon %s to be applied - %+v", status.Name, status)
+		}
+	}
+	expectEvent(t, r, corev1.EventTypeNormal, EventReasonAddonApplied, "Applied addon cni")
+	expectEvent(t, r, corev1.EventTypeNormal, EventReasonAddonApplied, "Applied addon certs")
+
+	config := &corev1.ConfigMap{}
+	if err := workload.Get(ctx, client.ObjectKey{Namespace: "cni-system", Name: "cni-config"}, config); err != nil {
+		t.Errorf("unexpected error - %+v", err)
+	} else if config.Data["mtu"] != "1440" {
+		t.Errorf("unexpected result - wanted %+v, got %+v", "1440", config.Data["mtu"])
+	}
+	if err := workload.Get(ctx, client.ObjectKey{Name: "cni-system"}, &corev1.Namespace{}); err != nil {
+		t.Errorf("unexpected error - %+v", err)
+	}
+	// Objects without a namespace are applied to the default namespace
+	if err := workload.Get(ctx, client.ObjectKey{Namespace: "default", Name: "ca"}, &corev1.ConfigMap{}); err != nil {
+		t.Errorf("unexpected error - %+v", err)
+	}
+
+	// Changed manifests are applied again
+	previousHash := actual.Status.Addons[0].Hash
+	cni.Data["cni.yaml"] = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cni-config
+  namespace: cni-system
+data:
+  mtu: "1500"
+`
+	if err := r.Update(ctx, cni); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+
+	actual = &infrastructurev1alpha4.KindCluster{}
+	if err := r.Get(ctx, req.NamespacedName, actual); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if actual.Status.Addons[0].Hash == previousHash {
+		t.Errorf("was expecting the hash to change - %+v", actual.Status.Addons[0])
+	}
+	config = &corev1.ConfigMap{}
+	if err := workload.Get(ctx, client.ObjectKey{Namespace: "cni-system", Name: "cni-config"}, config); err != nil {
+		t.Errorf("unexpected error - %+v", err)
+	} else if config.Data["mtu"] != "1500" {
+		t.Errorf("unexpected result - wanted %+v, got %+v", "1500", config.Data["mtu"])
+	}
+}
+
+func TestReconcileAddonsFailed(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		message  string
+	}{
+		{
+			name:    "missing ConfigMap",
+			message: `configmaps "cni" not found`,
+		},
+		{
+			name:     "malformed manifest",
+			manifest: "metadata:\n  name: test\n",
+			message:  "failed to decode cni.yaml: apiVersion and kind must be set on every object",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			kindCluster, cluster := newOwnedKindCluster()
+			kindCluster.Spec.Addons = []infrastructurev1alpha4.KindClusterAddon{
+				{Name: "cni", ConfigMapRef: &corev1.LocalObjectReference{Name: "cni"}},
+			}
+			objects := []client.Object{kindCluster, cluster, newKindHost("host-a", nil, nil, nil)}
+			if tt.manifest != "" {
+				objects = append(objects, &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: "cni", Namespace: "default"},
+					Data:       map[string]string{"cni.yaml": tt.manifest},
+				})
+			}
+
+			r := newTestReconciler(t, kindFake.New(), objects...)
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: kindCluster.Namespace, Name: kindCluster.Name}}
+
+			result, err := r.Reconcile(ctx, req)
+			if err != nil {
+				t.Fatalf("unexpected error - %+v", err)
+			}
+			if result.RequeueAfter != addonRequeueDelay {
+				t.Errorf("unexpected result - wanted %+v, got %+v", addonRequeueDelay, result.RequeueAfter)
+			}
+
+			actual := &infrastructurev1alpha4.KindCluster{}
+			if err := r.Get(ctx, req.NamespacedName, actual); err != nil {
+				t.Fatalf("unexpected error - %+v", err)
+			}
+			expected := []infrastructurev1alpha4.AddonStatus{{Name: "cni", Applied: false, Message: tt.message}}
+			if !reflect.DeepEqual(actual.Status.Addons, expected) {
+				t.Errorf("unexpected result - wanted %+v, got %+v", expected, actual.Status.Addons)
+			}
+			// Addon failures don't fail the cluster
+			if !actual.Status.Ready || actual.Status.FailureReason != nil {
+				t.Errorf("was expecting the cluster to be ready - %+v", actual.Status)
+			}
+			expectEvent(t, r, corev1.EventTypeWarning, EventReasonAddonFailed, "Failed to apply addon cni: "+tt.message)
+		})
+	}
+}
+
+func TestKindClustersForAddonSource(t *testing.T) {
+	withAddons := func(name string, addons ...infrastructurev1alpha4.KindClusterAddon) *infrastructurev1alpha4.KindCluster {
+		return &infrastructurev1alpha4.KindCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       infrastructurev1alpha4.KindClusterSpec{Addons: addons},
+		}
+	}
+	r := newTestReconciler(t, kindFake.New(),
+		withAddons("uses-configmap", infrastructurev1alpha4.KindClusterAddon{Name: "cni", ConfigMapRef: &corev1.LocalObjectReference{Name: "manifests"}}),
+		withAddons("uses-secret", infrastructurev1alpha4.KindClusterAddon{Name: "cni", SecretRef: &corev1.LocalObjectReference{Name: "manifests"}}),
+		withAddons("no-addons"),
+	)
+
+	tests := []struct {
+		name     string
+		obj      client.Object
+		expected []reconcile.Request
+	}{
+		{
+			name: "ConfigMap",
+			obj:  &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "manifests", Namespace: "default"}},
+			expected: []reconcile.Request{
+				{NamespacedName: types.NamespacedName{Namespace: "default", Name: "uses-configmap"}},
+			},
+		},
+		{
+			name: "Secret",
+			obj:  &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "manifests", Namespace: "default"}},
+			expected: []reconcile.Request{
+				{NamespacedName: types.NamespacedName{Namespace: "default", Name: "uses-secret"}},
+			},
+		},
+		{
+			name:     "different namespace",
+			obj:      &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "manifests", Namespace: "other"}},
+			expected: []reconcile.Request{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := r.kindClustersForAddonSource(tt.obj)
+			if !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tt.expected, actual)
+			}
+		})
+	}
+}
@@ -18,9 +18,14 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
 	"sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/annotations"
@@ -28,13 +33,18 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
 	infrastructurev1alpha4 "github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
 	kindClient "github.com/AverageMarcus/cluster-api-provider-kind/internal/client"
 	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/kubeconfig"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/nodeimage"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/tracing"
 	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/utils"
 )
 
@@ -42,23 +52,47 @@ import (
 type KindClusterReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	// KindServer is the client configuration of the default Kind server, used for clusters not placed on a KindHost
+	KindServer *kindClient.Options
+	// Recorder emits Kind's progress while creating and deleting clusters as events, if set
+	Recorder record.EventRecorder
+	// NewKindClient creates the client used to talk to a Kind server, defaults to calling the server over HTTP
+	NewKindClient func(opts kindClient.Options) (kindClient.Interface, error)
+	// ManagementClusterID identifies this management cluster to the Kind servers so clusters created by other
+	// management clusters sharing a server are left alone, if set
+	ManagementClusterID string
+	// NewWorkloadClient creates the client used to apply addons to a Kind cluster from its kubeconfig, defaults to
+	// calling the cluster's API server
+	NewWorkloadClient func(kubeconfig string) (client.Client, error)
 }
 
-const finalizerName = "kindcluster.cluster.x-k8s.io/finalizer"
+const (
+	finalizerName = "kindcluster.cluster.x-k8s.io/finalizer"
+
+	// hostCapacityRequeueDelay is how long to wait before trying to place a cluster again when no KindHost has capacity
+	hostCapacityRequeueDelay = 30 * time.Second
+)
 
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=kindclusters,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=kindclusters/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=kindclusters/finalizers,verbs=update
 //+kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters;clusters/status,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=kindclustersnapshots,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.8.3/pkg/reconcile
-func (r *KindClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *KindClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, err error) {
 	log := log.FromContext(ctx).WithValues("kindcluster", req.NamespacedName)
 
+	ctx, endSpan := tracing.StartSpan(ctx, instrumentation, "KindCluster.Reconcile",
+		trace.WithAttributes(kindClusterKey.String(req.NamespacedName.String())),
+	)
+	defer func() { endSpan(err) }()
+
 	// Fetch the KindCluster instance
 	kindCluster := &infrastructurev1alpha4.KindCluster{}
 	if err := r.Get(ctx, req.NamespacedName, kindCluster); err != nil {
@@ -111,6 +145,26 @@ func (r *KindClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		if controllerutil.ContainsFinalizer(kindCluster, finalizerName) {
 			log.Info("Deleting cluster")
 
+			// Nothing has been created in Kind if the cluster never left the pending phase
+			if kindCluster.Status.Phase == nil || *kindCluster.Status.Phase == infrastructurev1alpha4.KindClusterPhasePending {
+				controllerutil.RemoveFinalizer(kindCluster, finalizerName)
+				log.Info("Removed finalizer")
+				return ctrl.Result{}, nil
+			}
+
+			kind, err := r.kindClient(ctx, kindCluster)
+			if err != nil {
+				log.Error(err, "failed to get Kind server for cluster")
+				return ctrl.Result{}, err
+			}
+
+			// Keep the node logs of a failed cluster so the failure can be investigated once it's gone, only on
+			// the first attempt to delete it
+			if kindCluster.Status.FailureReason != nil && *kindCluster.Status.Phase != infrastructurev1alpha4.KindClusterPhaseDeleting {
+				log.Info("Collecting node logs of failed cluster")
+				r.archiveLogs(ctx, kind, kindCluster)
+			}
+
 			kindCluster.Status.Phase = &infrastructurev1alpha4.KindClusterPhaseDeleting
 			kindCluster.Status.Ready = false
 			if err := helper.Patch(ctx, kindCluster); err != nil {
@@ -118,10 +172,21 @@ func (r *KindClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 				return ctrl.Result{}, err
 			}
 
-			if err := kindClient.DeleteCluster(kindCluster.NamespacedName()); err != nil {
+			stepCtx, endStep := traceStep(ctx, "DeleteCluster", kindCluster)
+			deleteCtx, stopFollowing := r.followOperation(stepCtx, kind, kindCluster, EventReasonDeleting)
+			start := time.Now()
+			err = kind.DeleteCluster(deleteCtx, kindCluster.KindName(), nodeProvider(kindCluster))
+			stopFollowing()
+			endStep(err)
+			var busyErr *kindClient.ServerBusyError
+			if errors.As(err, &busyErr) {
+				log.Info("Kind server is busy, will retry deletion", "reason", busyErr.Reason, "retryAfter", busyErr.RetryAfter)
+				return ctrl.Result{RequeueAfter: busyErr.RetryAfter}, nil
+			}
+			observeDuration(deleteDuration, start, err)
+			if err != nil {
 				log.Error(err, "failed to delete cluster")
-				kindCluster.Status.FailureReason = &v1alpha4.FailureReasonDeleteFailed
-				kindCluster.Status.FailureMessage = utils.StringPtr(err.Error())
+				setFailure(kindCluster, v1alpha4.FailureReasonDeleteFailed, err)
 				return ctrl.Result{}, err
 			}
 
@@ -139,7 +204,69 @@ func (r *KindClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, err
 	}
 
+	// Delete the owner Cluster once the TTL has elapsed, otherwise keep the time remaining up to date
+	now := time.Now()
+	ttlRefresh := updateExpiry(kindCluster, now)
+	if isExpired(kindCluster, now) {
+		log.Info("TTL has elapsed, deleting Cluster", "ttl", kindCluster.Spec.TTL.Duration.String())
+		if err := r.expire(ctx, kindCluster, cluster); err != nil {
+			log.Error(err, "failed to delete expired Cluster")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
 	if kindCluster.Status.Phase == nil || *kindCluster.Status.Phase == infrastructurev1alpha4.KindClusterPhasePending {
+		if kindCluster.Spec.SnapshotRef != nil {
+			// The snapshot is only available on the host it was taken on
+			stepCtx, endStep := traceStep(ctx, "getSnapshot", kindCluster)
+			snapshot, err := r.getSnapshot(stepCtx, kindCluster)
+			endStep(err)
+			if err != nil {
+				log.Error(err, "failed to get snapshot to restore")
+				setFailure(kindCluster, v1alpha4.FailureReasonSnapshot, err)
+				return ctrl.Result{}, err
+			}
+			if snapshot == nil {
+				log.Info("Waiting for snapshot to be ready", "snapshot", kindCluster.Spec.SnapshotRef.Name)
+				return ctrl.Result{RequeueAfter: snapshotRequeueDelay}, nil
+			}
+			kindCluster.Status.Host = snapshot.Status.Host
+		} else {
+			stepCtx, endStep := traceStep(ctx, "scheduleHost", kindCluster)
+			host, err := r.scheduleHost(stepCtx, kindCluster)
+			endStep(err)
+			if err == errNoHostCapacity {
+				log.Info("No KindHost currently has capacity for the cluster, will retry")
+				return ctrl.Result{RequeueAfter: hostCapacityRequeueDelay}, nil
+			} else if err != nil {
+				log.Error(err, "failed to place cluster on a KindHost")
+				return ctrl.Result{}, err
+			}
+			if host != nil {
+				log.Info("Placed cluster on KindHost", "host", host.Name)
+				kindCluster.Status.Host = &host.Name
+			}
+		}
+
+		// The cluster stays pending if the raw Kind config or its Kind server can't be resolved so creating it is
+		// retried
+		kindCluster.Status.Image = utils.StringPtr(nodeimage.Resolve(kindCluster.Spec.Image, kindCluster.Spec.Version))
+		stepCtx, endStep := traceStep(ctx, "resolveKindConfig", kindCluster)
+		resolvedCluster, err := r.resolveKindConfig(stepCtx, kindCluster)
+		endStep(err)
+		if err != nil {
+			log.Error(err, "failed to resolve raw kind config")
+			setFailure(kindCluster, v1alpha4.FailureReasonKindConfig, err)
+			return ctrl.Result{}, err
+		}
+
+		kind, err := r.kindClient(ctx, kindCluster)
+		if err != nil {
+			log.Error(err, "failed to get Kind server for cluster")
+			return ctrl.Result{}, err
+		}
+
 		log.Info("Creating new cluster in Kind")
 
 		kindCluster.Status.Phase = &infrastructurev1alpha4.KindClusterPhaseCreating
@@ -148,15 +275,46 @@ func (r *KindClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 			return ctrl.Result{}, err
 		}
 
-		if err := kindClient.CreateCluster(kindCluster); err != nil {
+		stepCtx, endStep = traceStep(ctx, "CreateCluster", kindCluster)
+		createCtx, stopFollowing := r.followOperation(stepCtx, kind, kindCluster, EventReasonCreating)
+		start := time.Now()
+		provider, err := kind.CreateCluster(createCtx, resolvedCluster)
+		stopFollowing()
+		endStep(err)
+		var busyErr *kindClient.ServerBusyError
+		if errors.As(err, &busyErr) {
+			if busyErr.InProgress {
+				// An earlier request to create the cluster, that timed out, is still running on the host so the cluster
+				// is kept there and its readiness checked once the server is free
+				log.Info("Cluster is already being created, will check it's ready", "reason", busyErr.Reason, "retryAfter", busyErr.RetryAfter)
+				return ctrl.Result{RequeueAfter: busyErr.RetryAfter}, nil
+			}
+
+			// The Kind server doesn't have capacity right now so put the cluster back in the queue and try again later,
+			// possibly on a different host
+			log.Info("Kind server is busy, will retry", "reason", busyErr.Reason, "retryAfter", busyErr.RetryAfter)
+			// The helper only patches changes from when it was made, before the cluster was placed on the host, so a new
+			// one is needed for the host to be cleared
+			if helper, err = patch.NewHelper(kindCluster, r.Client); err != nil {
+				return reconcile.Result{}, errors.Wrap(err, "failed to init patch helper")
+			}
+			kindCluster.Status.Phase = &infrastructurev1alpha4.KindClusterPhasePending
+			kindCluster.Status.Host = nil
+			if err := helper.Patch(ctx, kindCluster); err != nil {
+				log.Error(err, "failed to update KindCluster status")
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{RequeueAfter: busyErr.RetryAfter}, nil
+		}
+		observeDuration(createDuration, start, err)
+		if err != nil {
 			log.Error(err, "failed to create cluster in kind")
-			kindCluster.Status.FailureReason = &v1alpha4.FailureReasonCreateFailed
-			kindCluster.Status.FailureMessage = utils.StringPtr(err.Error())
+			setFailure(kindCluster, v1alpha4.FailureReasonCreateFailed, err)
 			return ctrl.Result{}, err
 		}
+		kindCluster.Status.Provider = &provider
 
-		kindCluster.Status.Ready = true
-		kindCluster.Status.Phase = &infrastructurev1alpha4.KindClusterPhaseReady
+		// The cluster isn't ready until its nodes are, which is checked below
 		if err := helper.Patch(ctx, kindCluster); err != nil {
 			log.Error(err, "failed to update KindCluster status")
 			return ctrl.Result{}, err
@@ -165,37 +323,84 @@ func (r *KindClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		log.Info("Cluster created")
 	}
 
-	// Ensure ready status is up-to-date
-	isReady, err := kindClient.IsReady(kindCluster.NamespacedName())
+	kind, err := r.kindClient(ctx, kindCluster)
 	if err != nil {
-		log.Error(err, "failed to check status of cluster")
-		kindCluster.Status.FailureReason = &v1alpha4.FailureReasonClusterNotFound
-		kindCluster.Status.FailureMessage = utils.StringPtr(err.Error())
+		log.Error(err, "failed to get Kind server for cluster")
 		return ctrl.Result{}, err
 	}
-	kindCluster.Status.Ready = isReady
-	if isReady {
-		kindCluster.Status.Phase = &infrastructurev1alpha4.KindClusterPhaseReady
-	} else {
-		kindCluster.Status.Phase = &infrastructurev1alpha4.KindClusterPhaseCreating
+
+	// Stop the nodes while the cluster is suspended, its readiness isn't checked as the nodes aren't running
+	if kindCluster.Spec.Suspended {
+		if kindCluster.Status.Phase == nil || *kindCluster.Status.Phase != infrastructurev1alpha4.KindClusterPhaseSuspended {
+			log.Info("Suspending cluster")
+			stepCtx, endStep := traceStep(ctx, "SuspendCluster", kindCluster)
+			err := kind.SuspendCluster(stepCtx, kindCluster.KindName(), nodeProvider(kindCluster))
+			endStep(err)
+			var busyErr *kindClient.ServerBusyError
+			if errors.As(err, &busyErr) {
+				log.Info("Kind server is busy, will retry suspending", "reason", busyErr.Reason, "retryAfter", busyErr.RetryAfter)
+				return ctrl.Result{RequeueAfter: busyErr.RetryAfter}, nil
+			} else if err != nil {
+				log.Error(err, "failed to suspend cluster")
+				setFailure(kindCluster, v1alpha4.FailureReasonSuspendFailed, err)
+				return ctrl.Result{}, err
+			}
+			log.Info("Cluster suspended")
+		}
+
+		kindCluster.Status.Ready = false
+		kindCluster.Status.Phase = &infrastructurev1alpha4.KindClusterPhaseSuspended
+		return ctrl.Result{RequeueAfter: ttlRefresh}, nil
+	}
+
+	if kindCluster.Status.Phase != nil && *kindCluster.Status.Phase == infrastructurev1alpha4.KindClusterPhaseSuspended {
+		log.Info("Resuming cluster")
+		stepCtx, endStep := traceStep(ctx, "ResumeCluster", kindCluster)
+		err := kind.ResumeCluster(stepCtx, kindCluster.KindName(), nodeProvider(kindCluster))
+		endStep(err)
+		var busyErr *kindClient.ServerBusyError
+		if errors.As(err, &busyErr) {
+			log.Info("Kind server is busy, will retry resuming", "reason", busyErr.Reason, "retryAfter", busyErr.RetryAfter)
+			return ctrl.Result{RequeueAfter: busyErr.RetryAfter}, nil
+		} else if err != nil {
+			log.Error(err, "failed to resume cluster")
+			setFailure(kindCluster, v1alpha4.FailureReasonResumeFailed, err)
+			return ctrl.Result{}, err
+		}
+		log.Info("Cluster resumed")
 	}
 
+	if wantsLogs(kindCluster) {
+		log.Info("Collecting node logs")
+		r.archiveLogs(ctx, kind, kindCluster)
+		delete(kindCluster.Annotations, infrastructurev1alpha4.CollectLogsAnnotation)
+	}
+
+	// Ensure the cluster is running in Kind, its readiness is then checked against its nodes
+	stepCtx, endStep := traceStep(ctx, "IsReady", kindCluster)
+	isReady, err := kind.IsReady(stepCtx, kindCluster.KindName(), nodeProvider(kindCluster))
+	endStep(err)
+	if err != nil {
+		log.Error(err, "failed to check status of cluster")
+		setFailure(kindCluster, v1alpha4.FailureReasonClusterNotFound, err)
+		return ctrl.Result{}, err
+	}
 	// Ensure kubeconfig is up-to-date
-	kc, err := kindClient.GetKubeConfig(kindCluster.NamespacedName())
+	stepCtx, endStep = traceStep(ctx, "GetKubeConfig", kindCluster)
+	kc, err := kind.GetKubeConfig(stepCtx, kindCluster.KindName(), nodeProvider(kindCluster))
+	endStep(err)
 	if err != nil {
 		log.Error(err, "failed to check status of cluster")
-		kindCluster.Status.FailureReason = &v1alpha4.FailureReasonKubeConfig
-		kindCluster.Status.FailureMessage = utils.StringPtr(err.Error())
+		setFailure(kindCluster, v1alpha4.FailureReasonKubeConfig, err)
 		return ctrl.Result{}, err
 	}
 	kindCluster.Status.KubeConfig = &kc
 
 	// Populate the server endpoint details
-	endpoint, err := kubeconfig.ExtractEndpoint(kc, kindCluster.NamespacedName())
+	endpoint, err := kubeconfig.ExtractEndpoint(kc, kindCluster.KindName())
 	if err != nil {
 		log.Error(err, "failed to get control plane endpoint")
-		kindCluster.Status.FailureReason = &v1alpha4.FailureReasonEndpoint
-		kindCluster.Status.FailureMessage = utils.StringPtr(err.Error())
+		setFailure(kindCluster, v1alpha4.FailureReasonEndpoint, err)
 		return ctrl.Result{}, err
 	}
 	kindCluster.Spec.ControlPlaneEndpoint = clusterv1.APIEndpoint{
@@ -203,17 +408,98 @@ func (r *KindClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		Port: endpoint.Port,
 	}
 
+	// Ensure the addons are applied, retrying any that failed, before checking the nodes as they may include the
+	// CNI the nodes need to become ready
+	result := ctrl.Result{RequeueAfter: ttlRefresh}
+	if isReady && !r.applyAddons(ctx, kindCluster) {
+		result.RequeueAfter = requeueWithin(result.RequeueAfter, addonRequeueDelay)
+	}
+
+	// The cluster is only ready once all of its nodes are
+	if isReady {
+		stepCtx, endStep = traceStep(ctx, "nodesReady", kindCluster)
+		var reason string
+		isReady, reason, err = r.nodesReady(stepCtx, kindCluster)
+		endStep(err)
+		if err != nil {
+			log.Error(err, "failed to check nodes of cluster")
+		} else if !isReady {
+			log.Info("Waiting for nodes to be ready", "reason", reason)
+		}
+		if !isReady {
+			result.RequeueAfter = requeueWithin(result.RequeueAfter, nodesRequeueDelay)
+		}
+	}
+	kindCluster.Status.Ready = isReady
+	if isReady {
+		kindCluster.Status.Phase = &infrastructurev1alpha4.KindClusterPhaseReady
+		// Any earlier failure has been recovered from, so the cluster's logs aren't archived when it's deleted
+		clearFailure(kindCluster)
+	} else {
+		kindCluster.Status.Phase = &infrastructurev1alpha4.KindClusterPhaseCreating
+	}
+
 	if err := helper.Patch(ctx, kindCluster); err != nil {
 		log.Error(err, "failed to update KindCluster status")
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
+	return result, nil
+}
+
+// setFailure records the reason the KindCluster failed to reconcile in its status and metrics
+func setFailure(kindCluster *infrastructurev1alpha4.KindCluster, reason v1alpha4.FailureReason, err error) {
+	kindCluster.Status.FailureReason = &reason
+	kindCluster.Status.FailureMessage = utils.StringPtr(err.Error())
+	failures.WithLabelValues(string(reason)).Inc()
+}
+
+// clearFailure removes the reason the KindCluster last failed to reconcile from its status
+func clearFailure(kindCluster *infrastructurev1alpha4.KindCluster) {
+	kindCluster.Status.FailureReason = nil
+	kindCluster.Status.FailureMessage = nil
+}
+
+// nodeProvider returns the node provider the cluster was created with, or the requested one if not yet created
+func nodeProvider(kindCluster *infrastructurev1alpha4.KindCluster) infrastructurev1alpha4.NodeProvider {
+	if kindCluster.Status.Provider != nil {
+		return *kindCluster.Status.Provider
+	}
+	return kindCluster.Spec.Provider
+}
+
+// resolveKindConfig returns a copy of the KindCluster with any raw Kind config referenced from a ConfigMap inlined
+// so the Kind server doesn't need access to the management cluster
+func (r *KindClusterReconciler) resolveKindConfig(ctx context.Context, kindCluster *infrastructurev1alpha4.KindCluster) (*infrastructurev1alpha4.KindCluster, error) {
+	if kindCluster.Spec.KindConfig == nil || kindCluster.Spec.KindConfig.ConfigMapKeyRef == nil {
+		return kindCluster, nil
+	}
+
+	ref := kindCluster.Spec.KindConfig.ConfigMapKeyRef
+	configMap := &corev1.ConfigMap{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: kindCluster.Namespace, Name: ref.Name}, configMap); err != nil {
+		return nil, err
+	}
+
+	raw, ok := configMap.Data[ref.Key]
+	if !ok {
+		return nil, fmt.Errorf("key %s not found in ConfigMap %s", ref.Key, ref.Name)
+	}
+
+	resolvedCluster := kindCluster.DeepCopy()
+	resolvedCluster.Spec.KindConfig = &infrastructurev1alpha4.KindConfigSource{Inline: raw}
+	return resolvedCluster, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *KindClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := metrics.Registry.Register(phaseCollector{client: mgr.GetClient()}); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&infrastructurev1alpha4.KindCluster{}).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(r.kindClustersForAddonSource)).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.kindClustersForAddonSource)).
 		Complete(r)
 }
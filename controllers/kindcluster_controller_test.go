@@ -0,0 +1,437 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrastructurev1alpha4 "github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+	kindClient "github.com/AverageMarcus/cluster-api-provider-kind/internal/client"
+	kindFake "github.com/AverageMarcus/cluster-api-provider-kind/internal/client/fake"
+	pkgKindFake "github.com/AverageMarcus/cluster-api-provider-kind/pkg/kind/fake"
+)
+
+// newTestReconciler builds a reconciler backed by a fake API server and an in-memory Kind
+func newTestReconciler(t *testing.T, kind *kindFake.Client, objects ...client.Object) *KindClusterReconciler {
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build scheme - %+v", err)
+	}
+	if err := clusterv1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build scheme - %+v", err)
+	}
+	if err := infrastructurev1alpha4.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build scheme - %+v", err)
+	}
+
+	return &KindClusterReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(),
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(100),
+		NewKindClient: func(opts kindClient.Options) (kindClient.Interface, error) {
+			return kind, nil
+		},
+		NewWorkloadClient: workloadClients(kind),
+	}
+}
+
+// workloadClients returns a func creating fake clients of the clusters in the in-memory Kind, each with a ready
+// node for every node of the cluster
+//
+// The same client is returned for each cluster so the state of the workload cluster can be inspected and changed.
+func workloadClients(kind *kindFake.Client) func(kubeconfig string) (client.Client, error) {
+	var mu sync.Mutex
+	clients := map[string]client.Client{}
+
+	return func(kubeconfig string) (client.Client, error) {
+		config, err := clientcmd.Load([]byte(kubeconfig))
+		if err != nil {
+			return nil, err
+		}
+		clusterName := strings.TrimPrefix(config.CurrentContext, "kind-")
+
+		mu.Lock()
+		defer mu.Unlock()
+		if c, ok := clients[clusterName]; ok {
+			return c, nil
+		}
+
+		cluster, err := kind.Get(clusterName)
+		if err != nil {
+			return nil, err
+		}
+		builder := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme)
+		for i := 0; i < cluster.Nodes; i++ {
+			builder.WithObjects(&corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("%s-node-%d", clusterName, i)},
+				Status: corev1.NodeStatus{
+					Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+				},
+			})
+		}
+		clients[clusterName] = applyClient{builder.Build()}
+		return clients[clusterName], nil
+	}
+}
+
+// newOwnedKindCluster returns a KindCluster, and its owner Cluster, ready to be reconciled
+func newOwnedKindCluster() (*infrastructurev1alpha4.KindCluster, *clusterv1.Cluster) {
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"},
+	}
+	kindCluster := &infrastructurev1alpha4.KindCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: clusterv1.GroupVersion.String(),
+				Kind:       "Cluster",
+				Name:       "test-cluster",
+			}},
+		},
+		Spec: infrastructurev1alpha4.KindClusterSpec{
+			Name:     "default-test-cluster",
+			Replicas: 1,
+			Nodes:    []infrastructurev1alpha4.KindNode{{Role: infrastructurev1alpha4.NodeRoleControlPlane, Count: 1}},
+		},
+	}
+	return kindCluster, cluster
+}
+
+// expectEvents checks the next events recorded match Kind's progress messages
+func expectEvents(t *testing.T, r *KindClusterReconciler, reason string, messages []string) {
+	events := r.Recorder.(*record.FakeRecorder).Events
+	for _, message := range messages {
+		expected := fmt.Sprintf("%s %s %s", corev1.EventTypeNormal, reason, message)
+		select {
+		case event := <-events:
+			if event != expected {
+				t.Errorf("unexpected result - wanted %+v, got %+v", expected, event)
+			}
+		default:
+			t.Errorf("was expecting event %q", expected)
+		}
+	}
+}
+
+func TestReconcileLifecycle(t *testing.T) {
+	ctx := context.Background()
+	kind := kindFake.New()
+	kindCluster, cluster := newOwnedKindCluster()
+	host := newKindHost("host-a", nil, nil, nil)
+	r := newTestReconciler(t, kind, kindCluster, cluster, host)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: kindCluster.Namespace, Name: kindCluster.Name}}
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+
+	actual := &infrastructurev1alpha4.KindCluster{}
+	if err := r.Get(ctx, req.NamespacedName, actual); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if !actual.Status.Ready || *actual.Status.Phase != infrastructurev1alpha4.KindClusterPhaseReady {
+		t.Errorf("was expecting the cluster to be ready - %+v", actual.Status)
+	}
+	if actual.Status.Host == nil || *actual.Status.Host != "host-a" {
+		t.Errorf("was expecting the cluster to be placed on host-a - %+v", actual.Status.Host)
+	}
+	if actual.Status.Provider == nil || *actual.Status.Provider != infrastructurev1alpha4.NodeProviderDocker {
+		t.Errorf("was expecting the node provider to be recorded - %+v", actual.Status.Provider)
+	}
+	if actual.Spec.ControlPlaneEndpoint.Host != "127.0.0.1" || actual.Spec.ControlPlaneEndpoint.Port != 40000 {
+		t.Errorf("unexpected control plane endpoint - %+v", actual.Spec.ControlPlaneEndpoint)
+	}
+	if _, err := kind.Get("default-test-cluster"); err != nil {
+		t.Errorf("was expecting the cluster to exist in Kind - %+v", err)
+	}
+	expectEvents(t, r, EventReasonCreating, pkgKindFake.CreateMessages)
+
+	if err := r.Delete(ctx, actual); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+
+	if _, err := kind.Get("default-test-cluster"); err == nil {
+		t.Errorf("was expecting the cluster to be removed from Kind")
+	}
+	expectEvents(t, r, EventReasonDeleting, pkgKindFake.DeleteMessages)
+	if err := r.Get(ctx, req.NamespacedName, actual); client.IgnoreNotFound(err) != nil || err == nil {
+		t.Errorf("was expecting the KindCluster to be removed - %+v", err)
+	}
+}
+
+func TestReconcileWithoutDefaultedName(t *testing.T) {
+	ctx := context.Background()
+	kind := kindFake.New()
+	// KindClusters created before the name was defaulted don't have one
+	kindCluster, cluster := newOwnedKindCluster()
+	kindCluster.Spec.Name = ""
+	r := newTestReconciler(t, kind, kindCluster, cluster, newKindHost("host-a", nil, nil, nil))
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: kindCluster.Namespace, Name: kindCluster.Name}}
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+
+	actual := &infrastructurev1alpha4.KindCluster{}
+	if err := r.Get(ctx, req.NamespacedName, actual); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if !actual.Status.Ready {
+		t.Errorf("was expecting the cluster to be ready - %+v", actual.Status)
+	}
+	if _, err := kind.Get("default-test-cluster"); err != nil {
+		t.Errorf("was expecting the cluster to exist in Kind - %+v", err)
+	}
+}
+
+func TestReconcileCreateFailed(t *testing.T) {
+	ctx := context.Background()
+	kind := kindFake.New()
+	kind.CreateErr = errors.New("failed to create cluster")
+	kindCluster, cluster := newOwnedKindCluster()
+	r := newTestReconciler(t, kind, kindCluster, cluster, newKindHost("host-a", nil, nil, nil))
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: kindCluster.Namespace, Name: kindCluster.Name}}
+	failuresBefore := testutil.ToFloat64(failures.WithLabelValues(string(infrastructurev1alpha4.FailureReasonCreateFailed)))
+
+	if _, err := r.Reconcile(ctx, req); err == nil {
+		t.Errorf("was expecting an error")
+	}
+
+	failuresAfter := testutil.ToFloat64(failures.WithLabelValues(string(infrastructurev1alpha4.FailureReasonCreateFailed)))
+	if failuresAfter != failuresBefore+1 {
+		t.Errorf("unexpected result - wanted %+v, got %+v", failuresBefore+1, failuresAfter)
+	}
+
+	actual := &infrastructurev1alpha4.KindCluster{}
+	if err := r.Get(ctx, req.NamespacedName, actual); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if actual.Status.FailureReason == nil || *actual.Status.FailureReason != infrastructurev1alpha4.FailureReasonCreateFailed {
+		t.Errorf("unexpected result - wanted %+v, got %+v", infrastructurev1alpha4.FailureReasonCreateFailed, actual.Status.FailureReason)
+	}
+}
+
+func TestReconcileKindConfigNotFound(t *testing.T) {
+	ctx := context.Background()
+	kind := kindFake.New()
+	kindCluster, cluster := newOwnedKindCluster()
+	kindCluster.Spec.KindConfig = &infrastructurev1alpha4.KindConfigSource{
+		ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "kind-config"},
+			Key:                  "config.yaml",
+		},
+	}
+	r := newTestReconciler(t, kind, kindCluster, cluster, newKindHost("host-a", nil, nil, nil))
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: kindCluster.Namespace, Name: kindCluster.Name}}
+
+	if _, err := r.Reconcile(ctx, req); err == nil {
+		t.Errorf("was expecting an error")
+	}
+
+	actual := &infrastructurev1alpha4.KindCluster{}
+	if err := r.Get(ctx, req.NamespacedName, actual); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if actual.Status.Phase != nil && *actual.Status.Phase != infrastructurev1alpha4.KindClusterPhasePending {
+		t.Errorf("was expecting the cluster to still be pending - %+v", actual.Status.Phase)
+	}
+	if actual.Status.FailureReason == nil || *actual.Status.FailureReason != infrastructurev1alpha4.FailureReasonKindConfig {
+		t.Errorf("unexpected result - wanted %+v, got %+v", infrastructurev1alpha4.FailureReasonKindConfig, actual.Status.FailureReason)
+	}
+
+	// Creating the cluster is retried once the ConfigMap exists
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "kind-config", Namespace: kindCluster.Namespace},
+		Data:       map[string]string{"config.yaml": "kind: Cluster\napiVersion: kind.x-k8s.io/v1alpha4\n"},
+	}
+	if err := r.Create(ctx, configMap); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if _, err := kind.Get("default-test-cluster"); err != nil {
+		t.Errorf("was expecting the cluster to exist in Kind - %+v", err)
+	}
+}
+
+func TestReconcileKindHostCredentialsNotFound(t *testing.T) {
+	ctx := context.Background()
+	kind := kindFake.New()
+	kindCluster, cluster := newOwnedKindCluster()
+	host := newKindHost("host-a", nil, nil, nil)
+	host.Spec.CredentialsSecretRef = &corev1.SecretReference{Namespace: "default", Name: "host-a-credentials"}
+	r := newTestReconciler(t, kind, kindCluster, cluster, host)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: kindCluster.Namespace, Name: kindCluster.Name}}
+
+	if _, err := r.Reconcile(ctx, req); err == nil {
+		t.Errorf("was expecting an error")
+	}
+
+	actual := &infrastructurev1alpha4.KindCluster{}
+	if err := r.Get(ctx, req.NamespacedName, actual); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if actual.Status.Phase != nil && *actual.Status.Phase != infrastructurev1alpha4.KindClusterPhasePending {
+		t.Errorf("was expecting the cluster to still be pending - %+v", actual.Status.Phase)
+	}
+
+	// Creating the cluster is retried once the credentials exist
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "host-a-credentials", Namespace: "default"},
+		Data:       map[string][]byte{"token": []byte("secret")},
+	}
+	if err := r.Create(ctx, secret); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if _, err := kind.Get("default-test-cluster"); err != nil {
+		t.Errorf("was expecting the cluster to exist in Kind - %+v", err)
+	}
+}
+
+func TestReconcileServerBusy(t *testing.T) {
+	ctx := context.Background()
+	kind := kindFake.New()
+	kind.CreateErr = &kindClient.ServerBusyError{Reason: "creation queue is full", RetryAfter: hostCapacityRequeueDelay}
+	kindCluster, cluster := newOwnedKindCluster()
+	r := newTestReconciler(t, kind, kindCluster, cluster, newKindHost("host-a", nil, nil, nil))
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: kindCluster.Namespace, Name: kindCluster.Name}}
+
+	result, err := r.Reconcile(ctx, req)
+	if err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if result.RequeueAfter != hostCapacityRequeueDelay {
+		t.Errorf("unexpected result - wanted %+v, got %+v", hostCapacityRequeueDelay, result.RequeueAfter)
+	}
+
+	actual := &infrastructurev1alpha4.KindCluster{}
+	if err := r.Get(ctx, req.NamespacedName, actual); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if *actual.Status.Phase != infrastructurev1alpha4.KindClusterPhasePending || actual.Status.FailureReason != nil {
+		t.Errorf("was expecting the cluster to be pending - %+v", actual.Status)
+	}
+	if actual.Status.Host != nil {
+		t.Errorf("was expecting the cluster to be rescheduled - %+v", *actual.Status.Host)
+	}
+}
+
+func TestReconcileCreateInProgress(t *testing.T) {
+	ctx := context.Background()
+	kind := kindFake.New()
+	busyErr := &kindClient.ServerBusyError{Reason: "cluster is already being created", RetryAfter: 5 * time.Second, InProgress: true}
+	kind.CreateErr = fmt.Errorf("failed to create cluster: %w", busyErr)
+	kindCluster, cluster := newOwnedKindCluster()
+	r := newTestReconciler(t, kind, kindCluster, cluster, newKindHost("host-a", nil, nil, nil))
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: kindCluster.Namespace, Name: kindCluster.Name}}
+
+	result, err := r.Reconcile(ctx, req)
+	if err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if result.RequeueAfter != busyErr.RetryAfter {
+		t.Errorf("unexpected result - wanted %+v, got %+v", busyErr.RetryAfter, result.RequeueAfter)
+	}
+
+	// The earlier request is still creating the cluster on the host so it isn't rescheduled
+	actual := &infrastructurev1alpha4.KindCluster{}
+	if err := r.Get(ctx, req.NamespacedName, actual); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if *actual.Status.Phase != infrastructurev1alpha4.KindClusterPhaseCreating || actual.Status.FailureReason != nil {
+		t.Errorf("was expecting the cluster to be creating - %+v", actual.Status)
+	}
+	if actual.Status.Host == nil || *actual.Status.Host != "host-a" {
+		t.Errorf("was expecting the cluster to stay on its host - %+v", actual.Status.Host)
+	}
+}
+
+func TestReconcileSuspend(t *testing.T) {
+	ctx := context.Background()
+	kind := kindFake.New()
+	kindCluster, cluster := newOwnedKindCluster()
+	r := newTestReconciler(t, kind, kindCluster, cluster, newKindHost("host-a", nil, nil, nil))
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: kindCluster.Namespace, Name: kindCluster.Name}}
+
+	setSuspended := func(suspended bool) *infrastructurev1alpha4.KindCluster {
+		actual := &infrastructurev1alpha4.KindCluster{}
+		if err := r.Get(ctx, req.NamespacedName, actual); err != nil {
+			t.Fatalf("unexpected error - %+v", err)
+		}
+		actual.Spec.Suspended = suspended
+		if err := r.Update(ctx, actual); err != nil {
+			t.Fatalf("unexpected error - %+v", err)
+		}
+		if _, err := r.Reconcile(ctx, req); err != nil {
+			t.Fatalf("unexpected error - %+v", err)
+		}
+		if err := r.Get(ctx, req.NamespacedName, actual); err != nil {
+			t.Fatalf("unexpected error - %+v", err)
+		}
+		return actual
+	}
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+
+	actual := setSuspended(true)
+	if actual.Status.Ready || *actual.Status.Phase != infrastructurev1alpha4.KindClusterPhaseSuspended {
+		t.Errorf("was expecting the cluster to be suspended - %+v", actual.Status)
+	}
+	if actual.Status.FailureReason != nil {
+		t.Errorf("was expecting no failure while suspended - %+v", *actual.Status.FailureReason)
+	}
+	if kindState, _ := kind.Get("default-test-cluster"); !kindState.Suspended {
+		t.Errorf("was expecting the nodes to be stopped in Kind")
+	}
+
+	actual = setSuspended(false)
+	if !actual.Status.Ready || *actual.Status.Phase != infrastructurev1alpha4.KindClusterPhaseReady {
+		t.Errorf("was expecting the cluster to be ready - %+v", actual.Status)
+	}
+	if kindState, _ := kind.Get("default-test-cluster"); kindState.Suspended {
+		t.Errorf("was expecting the nodes to be restarted in Kind")
+	}
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrastructurev1alpha4 "github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+	kindClient "github.com/AverageMarcus/cluster-api-provider-kind/internal/client"
+)
+
+const (
+	// EventReasonCreating is the reason of the events containing Kind's progress while creating a cluster
+	EventReasonCreating = "Creating"
+	// EventReasonDeleting is the reason of the events containing Kind's progress while deleting a cluster
+	EventReasonDeleting = "Deleting"
+
+	// logDrainTimeout is how long to wait for the remaining progress messages once an operation has returned
+	logDrainTimeout = 5 * time.Second
+)
+
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// followOperation streams Kind's progress messages for the next create or delete made with the returned context,
+// emitting each as an event on the KindCluster
+//
+// The returned func must be called once the operation has returned, it waits briefly for any remaining messages.
+func (r *KindClusterReconciler) followOperation(ctx context.Context, kind kindClient.Interface, kindCluster *infrastructurev1alpha4.KindCluster, reason string) (context.Context, func()) {
+	if r.Recorder == nil {
+		return ctx, func() {}
+	}
+
+	log := log.FromContext(ctx)
+	operationID := string(uuid.NewUUID())
+	// The KindCluster is patched while the operation runs so the events use their own copy
+	eventObject := kindCluster.DeepCopy()
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err := kind.StreamLogs(streamCtx, eventObject.KindName(), operationID, func(message string) {
+			r.Recorder.Event(eventObject, corev1.EventTypeNormal, reason, message)
+		})
+		if err != nil && streamCtx.Err() == nil {
+			log.Info("failed to stream Kind progress", "error", err.Error())
+		}
+	}()
+
+	return kindClient.WithOperationID(ctx, operationID), func() {
+		select {
+		case <-done:
+		case <-time.After(logDrainTimeout):
+		}
+		cancel()
+		<-done
+	}
+}
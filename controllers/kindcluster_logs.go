@@ -0,0 +1,68 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrastructurev1alpha4 "github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+	kindClient "github.com/AverageMarcus/cluster-api-provider-kind/internal/client"
+)
+
+const (
+	// EventReasonLogsCollected is the reason of the event recording where a cluster's node logs were archived
+	EventReasonLogsCollected = "LogsCollected"
+	// EventReasonLogsFailed is the reason of the event recording that a cluster's node logs couldn't be collected
+	EventReasonLogsFailed = "LogsCollectionFailed"
+)
+
+// wantsLogs checks if the KindCluster has been annotated to request its node logs be collected
+func wantsLogs(kindCluster *infrastructurev1alpha4.KindCluster) bool {
+	_, ok := kindCluster.Annotations[infrastructurev1alpha4.CollectLogsAnnotation]
+	return ok
+}
+
+// archiveLogs collects the cluster's node logs into an archive on its Kind server, recording the URL of the archive
+// in the status and as an event
+//
+// Failing to collect the logs doesn't stop the reconcile, it's reported as a warning event instead.
+func (r *KindClusterReconciler) archiveLogs(ctx context.Context, kind kindClient.Interface, kindCluster *infrastructurev1alpha4.KindCluster) {
+	log := log.FromContext(ctx)
+
+	stepCtx, endStep := traceStep(ctx, "ArchiveLogs", kindCluster)
+	archiveURL, err := kind.ArchiveLogs(stepCtx, kindCluster.KindName(), nodeProvider(kindCluster))
+	endStep(err)
+	if err != nil {
+		log.Error(err, "failed to collect node logs")
+		r.recordEvent(kindCluster, corev1.EventTypeWarning, EventReasonLogsFailed, "Failed to collect node logs: "+err.Error())
+		return
+	}
+
+	log.Info("Collected node logs", "archive", archiveURL)
+	kindCluster.Status.LogsArchive = &archiveURL
+	r.recordEvent(kindCluster, corev1.EventTypeNormal, EventReasonLogsCollected, "Node logs archived at "+archiveURL)
+}
+
+// recordEvent emits an event on the KindCluster, if a recorder is set
+func (r *KindClusterReconciler) recordEvent(kindCluster *infrastructurev1alpha4.KindCluster, eventType, reason, message string) {
+	if r.Recorder != nil {
+		r.Recorder.Event(kindCluster, eventType, reason, message)
+	}
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	infrastructurev1alpha4 "github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+	kindFake "github.com/AverageMarcus/cluster-api-provider-kind/internal/client/fake"
+)
+
+// expectEvent checks an event with the reason and message was recorded, ignoring any others
+func expectEvent(t *testing.T, r *KindClusterReconciler, eventType, reason, message string) {
+	expected := fmt.Sprintf("%s %s %s", eventType, reason, message)
+	events := r.Recorder.(*record.FakeRecorder).Events
+	for {
+		select {
+		case event := <-events:
+			if event == expected {
+				return
+			}
+		default:
+			t.Errorf("was expecting event %q", expected)
+			return
+		}
+	}
+}
+
+func TestReconcileCollectLogs(t *testing.T) {
+	ctx := context.Background()
+	kind := kindFake.New()
+	kindCluster, cluster := newOwnedKindCluster()
+	r := newTestReconciler(t, kind, kindCluster, cluster, newKindHost("host-a", nil, nil, nil))
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: kindCluster.Namespace, Name: kindCluster.Name}}
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+
+	actual := &infrastructurev1alpha4.KindCluster{}
+	if err := r.Get(ctx, req.NamespacedName, actual); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	actual.Annotations = map[string]string{infrastructurev1alpha4.CollectLogsAnnotation: ""}
+	if err := r.Update(ctx, actual); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+
+	actual = &infrastructurev1alpha4.KindCluster{}
+	if err := r.Get(ctx, req.NamespacedName, actual); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	expected := kindFake.LogArchiveURL + "default-test-cluster-0"
+	if actual.Status.LogsArchive == nil || *actual.Status.LogsArchive != expected {
+		t.Errorf("unexpected result - wanted %+v, got %+v", expected, actual.Status.LogsArchive)
+	}
+	if wantsLogs(actual) {
+		t.Errorf("was expecting the annotation to be removed - %+v", actual.Annotations)
+	}
+	expectEvent(t, r, corev1.EventTypeNormal, EventReasonLogsCollected, "Node logs archived at "+expected)
+}
+
+func TestReconcileDeleteFailedCollectsLogs(t *testing.T) {
+	tests := []struct {
+		name      string
+		exportErr error
+		eventType string
+		reason    string
+		message   string
+	}{
+		{
+			name:      "logs archived",
+			eventType: corev1.EventTypeNormal,
+			reason:    EventReasonLogsCollected,
+			message:   "Node logs archived at " + kindFake.LogArchiveURL + "default-test-cluster-0",
+		},
+		{
+			name:      "deleted even if the logs can't be collected",
+			exportErr: errors.New("no space left on device"),
+			eventType: corev1.EventTypeWarning,
+			reason:    EventReasonLogsFailed,
+			message:   "Failed to collect node logs: no space left on device",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			kind := kindFake.New()
+			kind.ExportLogsErr = tt.exportErr
+			kindCluster, cluster := newOwnedKindCluster()
+			r := newTestReconciler(t, kind, kindCluster, cluster, newKindHost("host-a", nil, nil, nil))
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: kindCluster.Namespace, Name: kindCluster.Name}}
+
+			if _, err := r.Reconcile(ctx, req); err != nil {
+				t.Fatalf("unexpected error - %+v", err)
+			}
+
+			actual := &infrastructurev1alpha4.KindCluster{}
+			if err := r.Get(ctx, req.NamespacedName, actual); err != nil {
+				t.Fatalf("unexpected error - %+v", err)
+			}
+			setFailure(actual, infrastructurev1alpha4.FailureReasonClusterNotFound, errors.New("cluster not found"))
+			if err := r.Update(ctx, actual); err != nil {
+				t.Fatalf("unexpected error - %+v", err)
+			}
+			if err := r.Delete(ctx, actual); err != nil {
+				t.Fatalf("unexpected error - %+v", err)
+			}
+			if _, err := r.Reconcile(ctx, req); err != nil {
+				t.Fatalf("unexpected error - %+v", err)
+			}
+
+			expectEvent(t, r, tt.eventType, tt.reason, tt.message)
+			if _, err := kind.Get("default-test-cluster"); err == nil {
+				t.Errorf("was expecting the cluster to be removed from Kind")
+			}
+		})
+	}
+}
+
+func TestReconcileDeleteRecoveredClusterKeepsNoLogs(t *testing.T) {
+	ctx := context.Background()
+	kind := kindFake.New()
+	kindCluster, cluster := newOwnedKindCluster()
+	r := newTestReconciler(t, kind, kindCluster, cluster, newKindHost("host-a", nil, nil, nil))
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: kindCluster.Namespace, Name: kindCluster.Name}}
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+
+	// A transient failure is cleared once the cluster is ready again
+	actual := &infrastructurev1alpha4.KindCluster{}
+	if err := r.Get(ctx, req.NamespacedName, actual); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	setFailure(actual, infrastructurev1alpha4.FailureReasonKubeConfig, errors.New("connection refused"))
+	if err := r.Update(ctx, actual); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if err := r.Get(ctx, req.NamespacedName, actual); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if actual.Status.FailureReason != nil || actual.Status.FailureMessage != nil {
+		t.Errorf("was expecting the failure to be cleared - %+v", actual.Status)
+	}
+
+	if err := r.Delete(ctx, actual); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if info, _, _ := kind.OpenLogArchive("default-test-cluster-0"); info != nil {
+		t.Errorf("was expecting the logs of a recovered cluster not to be archived - %+v", info)
+	}
+}
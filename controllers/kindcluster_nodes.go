@@ -0,0 +1,81 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	infrastructurev1alpha4 "github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+)
+
+// nodesRequeueDelay is how long to wait before checking the nodes of a cluster that isn't ready yet again
+const nodesRequeueDelay = 10 * time.Second
+
+// nodesReady checks all of the cluster's nodes have registered with its API server and are ready, returning why
+// if not
+//
+// The number of nodes is only checked once the spec's nodes have been populated by the defaulting webhook.
+func (r *KindClusterReconciler) nodesReady(ctx context.Context, kindCluster *infrastructurev1alpha4.KindCluster) (bool, string, error) {
+	workloadClient, err := r.workloadClient(kindCluster)
+	if err != nil {
+		return false, "", err
+	}
+
+	nodes := &corev1.NodeList{}
+	if err := workloadClient.List(ctx, nodes); err != nil {
+		return false, "", err
+	}
+
+	if expected := kindCluster.NodeCount(); expected > 0 && int32(len(nodes.Items)) != expected {
+		return false, fmt.Sprintf("%d of %d nodes registered", len(nodes.Items), expected), nil
+	}
+
+	notReady := []string{}
+	for _, node := range nodes.Items {
+		if !isNodeReady(node) {
+			notReady = append(notReady, node.Name)
+		}
+	}
+	if len(notReady) > 0 {
+		return false, fmt.Sprintf("nodes not ready: %s", strings.Join(notReady, ", ")), nil
+	}
+	return true, "", nil
+}
+
+// isNodeReady checks the node's Ready condition is true
+func isNodeReady(node corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// requeueWithin returns the earlier of the requeue delay and the given delay, where a zero requeue delay means the
+// KindCluster wasn't going to be requeued
+func requeueWithin(requeueAfter, delay time.Duration) time.Duration {
+	if requeueAfter == 0 || requeueAfter > delay {
+		return delay
+	}
+	return requeueAfter
+}
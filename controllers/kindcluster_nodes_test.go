@@ -0,0 +1,141 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1alpha4 "github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+	kindFake "github.com/AverageMarcus/cluster-api-provider-kind/internal/client/fake"
+)
+
+func TestReconcileWaitsForNodes(t *testing.T) {
+	tests := []struct {
+		name string
+		// update changes the nodes of the workload cluster before it's reconciled again
+		update    func(ctx context.Context, workload client.Client) error
+		wantReady bool
+	}{
+		{
+			name:      "all nodes ready",
+			update:    func(ctx context.Context, workload client.Client) error { return nil },
+			wantReady: true,
+		},
+		{
+			name: "node not ready",
+			update: func(ctx context.Context, workload client.Client) error {
+				node := &corev1.Node{}
+				if err := workload.Get(ctx, client.ObjectKey{Name: "default-test-cluster-node-1"}, node); err != nil {
+					return err
+				}
+				node.Status.Conditions[0].Status = corev1.ConditionFalse
+				return workload.Update(ctx, node)
+			},
+		},
+		{
+			name: "node not registered",
+			update: func(ctx context.Context, workload client.Client) error {
+				return workload.Delete(ctx, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "default-test-cluster-node-1"}})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			kindCluster, cluster := newOwnedKindCluster()
+			kindCluster.Spec.Nodes = append(kindCluster.Spec.Nodes, infrastructurev1alpha4.KindNode{Role: infrastructurev1alpha4.NodeRoleWorker, Count: 1})
+			r := newTestReconciler(t, kindFake.New(), kindCluster, cluster, newKindHost("host-a", nil, nil, nil))
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: kindCluster.Namespace, Name: kindCluster.Name}}
+
+			if _, err := r.Reconcile(ctx, req); err != nil {
+				t.Fatalf("unexpected error - %+v", err)
+			}
+
+			actual := &infrastructurev1alpha4.KindCluster{}
+			if err := r.Get(ctx, req.NamespacedName, actual); err != nil {
+				t.Fatalf("unexpected error - %+v", err)
+			}
+			workload, err := r.NewWorkloadClient(*actual.Status.KubeConfig)
+			if err != nil {
+				t.Fatalf("unexpected error - %+v", err)
+			}
+			if err := tt.update(ctx, workload); err != nil {
+				t.Fatalf("unexpected error - %+v", err)
+			}
+
+			result, err := r.Reconcile(ctx, req)
+			if err != nil {
+				t.Fatalf("unexpected error - %+v", err)
+			}
+
+			actual = &infrastructurev1alpha4.KindCluster{}
+			if err := r.Get(ctx, req.NamespacedName, actual); err != nil {
+				t.Fatalf("unexpected error - %+v", err)
+			}
+			if actual.Status.Ready != tt.wantReady {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tt.wantReady, actual.Status.Ready)
+			}
+			expectedPhase, expectedRequeue := infrastructurev1alpha4.KindClusterPhaseReady, time.Duration(0)
+			if !tt.wantReady {
+				expectedPhase, expectedRequeue = infrastructurev1alpha4.KindClusterPhaseCreating, nodesRequeueDelay
+			}
+			if actual.Status.Phase == nil || *actual.Status.Phase != expectedPhase {
+				t.Errorf("unexpected result - wanted %+v, got %+v", expectedPhase, actual.Status.Phase)
+			}
+			if result.RequeueAfter != expectedRequeue {
+				t.Errorf("unexpected result - wanted %+v, got %+v", expectedRequeue, result.RequeueAfter)
+			}
+		})
+	}
+}
+
+func TestReconcileWorkloadClusterUnreachable(t *testing.T) {
+	ctx := context.Background()
+	kindCluster, cluster := newOwnedKindCluster()
+	r := newTestReconciler(t, kindFake.New(), kindCluster, cluster, newKindHost("host-a", nil, nil, nil))
+	r.NewWorkloadClient = func(kubeconfig string) (client.Client, error) {
+		return nil, errors.New("connection refused")
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: kindCluster.Namespace, Name: kindCluster.Name}}
+
+	result, err := r.Reconcile(ctx, req)
+	if err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if result.RequeueAfter != nodesRequeueDelay {
+		t.Errorf("unexpected result - wanted %+v, got %+v", nodesRequeueDelay, result.RequeueAfter)
+	}
+
+	actual := &infrastructurev1alpha4.KindCluster{}
+	if err := r.Get(ctx, req.NamespacedName, actual); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	// The cluster isn't failed, it's checked again until its API server can be reached
+	if actual.Status.Ready || actual.Status.FailureReason != nil {
+		t.Errorf("was expecting the cluster to not be ready - %+v", actual.Status)
+	}
+}
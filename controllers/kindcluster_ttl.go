@@ -0,0 +1,85 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1alpha4 "github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/utils"
+)
+
+const (
+	// EventReasonExpired is the reason of the event emitted when a cluster is deleted as its TTL has elapsed
+	EventReasonExpired = "Expired"
+
+	// ttlRefreshInterval is the longest time between updates of the time remaining shown in the status
+	ttlRefreshInterval = time.Minute
+)
+
+//+kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters,verbs=delete
+
+// updateExpiry records when the cluster expires, and how long is left, in its status, returning the time until the
+// status should next be refreshed
+//
+// Zero is returned if the cluster has no TTL or has already expired.
+func updateExpiry(kindCluster *infrastructurev1alpha4.KindCluster, now time.Time) time.Duration {
+	if kindCluster.Spec.TTL == nil {
+		kindCluster.Status.ExpiresAt = nil
+		kindCluster.Status.TTLRemaining = nil
+		return 0
+	}
+
+	expiresAt := kindCluster.CreationTimestamp.Add(kindCluster.Spec.TTL.Duration)
+	remaining := expiresAt.Sub(now)
+	if remaining < 0 {
+		remaining = 0
+	}
+	kindCluster.Status.ExpiresAt = &metav1.Time{Time: expiresAt}
+	kindCluster.Status.TTLRemaining = utils.StringPtr(duration.HumanDuration(remaining))
+
+	if remaining > ttlRefreshInterval {
+		return ttlRefreshInterval
+	}
+	return remaining
+}
+
+// isExpired checks if the cluster's TTL has elapsed
+func isExpired(kindCluster *infrastructurev1alpha4.KindCluster, now time.Time) bool {
+	return kindCluster.Status.ExpiresAt != nil && !now.Before(kindCluster.Status.ExpiresAt.Time)
+}
+
+// expire deletes the owner Cluster of a KindCluster whose TTL has elapsed, which in turn deletes the KindCluster
+func (r *KindClusterReconciler) expire(ctx context.Context, kindCluster *infrastructurev1alpha4.KindCluster, cluster *clusterv1.Cluster) error {
+	if !cluster.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	if r.Recorder != nil {
+		r.Recorder.Event(kindCluster, corev1.EventTypeNormal, EventReasonExpired,
+			fmt.Sprintf("TTL of %s has elapsed, deleting Cluster %s", kindCluster.Spec.TTL.Duration, cluster.Name))
+	}
+	return client.IgnoreNotFound(r.Delete(ctx, cluster))
+}
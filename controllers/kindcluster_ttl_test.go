@@ -0,0 +1,135 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1alpha4 "github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+	kindFake "github.com/AverageMarcus/cluster-api-provider-kind/internal/client/fake"
+)
+
+func TestUpdateExpiry(t *testing.T) {
+	now := time.Date(2021, 7, 1, 12, 0, 0, 0, time.UTC)
+	created := metav1.NewTime(now.Add(-time.Hour))
+
+	tests := []struct {
+		name              string
+		ttl               *metav1.Duration
+		expectedRefresh   time.Duration
+		expectedRemaining string
+		expectedExpired   bool
+	}{
+		{
+			name:            "no TTL",
+			expectedRefresh: 0,
+		},
+		{
+			name:              "time remaining",
+			ttl:               &metav1.Duration{Duration: 3 * time.Hour},
+			expectedRefresh:   ttlRefreshInterval,
+			expectedRemaining: "120m",
+		},
+		{
+			name:              "less than refresh interval remaining",
+			ttl:               &metav1.Duration{Duration: time.Hour + 30*time.Second},
+			expectedRefresh:   30 * time.Second,
+			expectedRemaining: "30s",
+		},
+		{
+			name:              "expired",
+			ttl:               &metav1.Duration{Duration: 30 * time.Minute},
+			expectedRefresh:   0,
+			expectedRemaining: "0s",
+			expectedExpired:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kindCluster := &infrastructurev1alpha4.KindCluster{
+				ObjectMeta: metav1.ObjectMeta{CreationTimestamp: created},
+				Spec:       infrastructurev1alpha4.KindClusterSpec{TTL: tt.ttl},
+			}
+
+			if refresh := updateExpiry(kindCluster, now); refresh != tt.expectedRefresh {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tt.expectedRefresh, refresh)
+			}
+			remaining := ""
+			if kindCluster.Status.TTLRemaining != nil {
+				remaining = *kindCluster.Status.TTLRemaining
+			}
+			if remaining != tt.expectedRemaining {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tt.expectedRemaining, remaining)
+			}
+			if expired := isExpired(kindCluster, now); expired != tt.expectedExpired {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tt.expectedExpired, expired)
+			}
+		})
+	}
+}
+
+func TestReconcileTTL(t *testing.T) {
+	tests := []struct {
+		name            string
+		ttl             time.Duration
+		expectedDeleted bool
+	}{
+		{name: "not expired", ttl: 2 * time.Hour},
+		{name: "expired", ttl: 30 * time.Minute, expectedDeleted: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			kindCluster, cluster := newOwnedKindCluster()
+			kindCluster.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+			kindCluster.Spec.TTL = &metav1.Duration{Duration: tt.ttl}
+			r := newTestReconciler(t, kindFake.New(), kindCluster, cluster, newKindHost("host-a", nil, nil, nil))
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: kindCluster.Namespace, Name: kindCluster.Name}}
+
+			result, err := r.Reconcile(ctx, req)
+			if err != nil {
+				t.Fatalf("unexpected error - %+v", err)
+			}
+
+			err = r.Get(ctx, client.ObjectKeyFromObject(cluster), &clusterv1.Cluster{})
+			if deleted := client.IgnoreNotFound(err) == nil && err != nil; deleted != tt.expectedDeleted {
+				t.Errorf("unexpected result - wanted deleted %+v, got %+v", tt.expectedDeleted, deleted)
+			}
+			if !tt.expectedDeleted && result.RequeueAfter != ttlRefreshInterval {
+				t.Errorf("unexpected result - wanted %+v, got %+v", ttlRefreshInterval, result.RequeueAfter)
+			}
+
+			actual := &infrastructurev1alpha4.KindCluster{}
+			if err := r.Get(ctx, req.NamespacedName, actual); err != nil {
+				t.Fatalf("unexpected error - %+v", err)
+			}
+			if actual.Status.ExpiresAt == nil || actual.Status.TTLRemaining == nil {
+				t.Errorf("was expecting the expiry to be recorded - %+v", actual.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"sigs.k8s.io/cluster-api/util/patch"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrastructurev1alpha4 "github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+	kindClient "github.com/AverageMarcus/cluster-api-provider-kind/internal/client"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/nodeimage"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/tracing"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/utils"
+)
+
+const (
+	snapshotFinalizerName = "kindclustersnapshot.cluster.x-k8s.io/finalizer"
+
+	// snapshotRequeueDelay is how long to wait before checking again for a KindCluster or snapshot that isn't ready
+	snapshotRequeueDelay = 10 * time.Second
+)
+
+// kindClusterSnapshotKey is the attribute holding the namespace and name of the KindClusterSnapshot a span relates to
+const kindClusterSnapshotKey = attribute.Key("capk.kindclustersnapshot")
+
+// KindClusterSnapshotReconciler reconciles a KindClusterSnapshot object
+type KindClusterSnapshotReconciler struct {
+	client.Client
+	// Clusters provides the clients of the Kind servers the KindClusters have been placed on
+	Clusters *KindClusterReconciler
+}
+
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=kindclustersnapshots,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=kindclustersnapshots/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=kindclustersnapshots/finalizers,verbs=update
+
+// Reconcile takes the snapshot on the Kind server once the KindCluster is ready, and removes it from the Kind server
+// when the KindClusterSnapshot is deleted
+//
+// Snapshots are taken once, a failed snapshot must be deleted and created again to retry.
+func (r *KindClusterSnapshotReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, err error) {
+	log := log.FromContext(ctx).WithValues("kindclustersnapshot", req.NamespacedName)
+
+	ctx, endSpan := tracing.StartSpan(ctx, instrumentation, "KindClusterSnapshot.Reconcile",
+		trace.WithAttributes(kindClusterSnapshotKey.String(req.NamespacedName.String())),
+	)
+	defer func() { endSpan(err) }()
+
+	snapshot := &infrastructurev1alpha4.KindClusterSnapshot{}
+	if err := r.Get(ctx, req.NamespacedName, snapshot); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			log.Error(err, "unable to fetch KindClusterSnapshot")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	helper, err := patch.NewHelper(snapshot, r.Client)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to init patch helper")
+	}
+	defer func() {
+		helper.Patch(context.TODO(), snapshot)
+	}()
+
+	if !snapshot.DeletionTimestamp.IsZero() {
+		if !controllerutil.ContainsFinalizer(snapshot, snapshotFinalizerName) {
+			return ctrl.Result{}, nil
+		}
+
+		// Nothing is kept on the Kind server until the snapshot has started
+		if snapshot.Status.Phase != nil && *snapshot.Status.Phase != infrastructurev1alpha4.KindClusterSnapshotPhasePending {
+			log.Info("Deleting snapshot")
+			kind, err := r.Clusters.hostKindClient(ctx, snapshot.Status.Host)
+			if err != nil {
+				log.Error(err, "failed to get Kind server for snapshot")
+				return ctrl.Result{}, err
+			}
+			err = kind.DeleteSnapshot(ctx, snapshot.SnapshotName())
+			var busyErr *kindClient.ServerBusyError
+			if errors.As(err, &busyErr) {
+				log.Info("Kind server is busy, will retry deleting snapshot", "reason", busyErr.Reason, "retryAfter", busyErr.RetryAfter)
+				return ctrl.Result{RequeueAfter: busyErr.RetryAfter}, nil
+			} else if err != nil {
+				log.Error(err, "failed to delete snapshot")
+				return ctrl.Result{}, err
+			}
+		}
+
+		controllerutil.RemoveFinalizer(snapshot, snapshotFinalizerName)
+		log.Info("Removed finalizer")
+		return ctrl.Result{}, nil
+	}
+
+	controllerutil.AddFinalizer(snapshot, snapshotFinalizerName)
+	if err := helper.Patch(ctx, snapshot); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if snapshot.Status.Phase != nil && (*snapshot.Status.Phase == infrastructurev1alpha4.KindClusterSnapshotPhaseReady ||
+		*snapshot.Status.Phase == infrastructurev1alpha4.KindClusterSnapshotPhaseFailed) {
+		return ctrl.Result{}, nil
+	}
+
+	kindCluster := &infrastructurev1alpha4.KindCluster{}
+	key := client.ObjectKey{Namespace: snapshot.Namespace, Name: snapshot.Spec.ClusterName}
+	if err := r.Get(ctx, key, kindCluster); client.IgnoreNotFound(err) != nil {
+		log.Error(err, "failed to get KindCluster")
+		return ctrl.Result{}, err
+	} else if err != nil || !kindCluster.Status.Ready {
+		log.Info("Waiting for KindCluster to be ready", "kindcluster", snapshot.Spec.ClusterName)
+		return ctrl.Result{RequeueAfter: snapshotRequeueDelay}, nil
+	}
+
+	log.Info("Taking snapshot", "cluster", kindCluster.KindName())
+	snapshot.Status.Phase = &infrastructurev1alpha4.KindClusterSnapshotPhaseCreating
+	snapshot.Status.Host = kindCluster.Status.Host
+	snapshot.Status.Provider = kindCluster.Status.Provider
+	if err := helper.Patch(ctx, snapshot); err != nil {
+		log.Error(err, "failed to update KindClusterSnapshot status")
+		return ctrl.Result{}, err
+	}
+
+	kind, err := r.Clusters.kindClient(ctx, kindCluster)
+	if err != nil {
+		log.Error(err, "failed to get Kind server for cluster")
+		return ctrl.Result{}, err
+	}
+
+	stepCtx, endStep := traceStep(ctx, "CreateSnapshot", kindCluster)
+	info, err := kind.CreateSnapshot(stepCtx, kindCluster.KindName(), snapshot.SnapshotName(), nodeProvider(kindCluster))
+	endStep(err)
+	var busyErr *kindClient.ServerBusyError
+	if errors.As(err, &busyErr) {
+		log.Info("Kind server is busy, will retry snapshot", "reason", busyErr.Reason, "retryAfter", busyErr.RetryAfter)
+		return ctrl.Result{RequeueAfter: busyErr.RetryAfter}, nil
+	} else if err != nil {
+		log.Error(err, "failed to take snapshot")
+		snapshot.Status.Phase = &infrastructurev1alpha4.KindClusterSnapshotPhaseFailed
+		snapshot.Status.FailureMessage = utils.StringPtr(err.Error())
+		return ctrl.Result{}, nil
+	}
+
+	snapshot.Status.Image = &info.Image
+	snapshot.Status.Ready = true
+	snapshot.Status.Phase = &infrastructurev1alpha4.KindClusterSnapshotPhaseReady
+	log.Info("Snapshot taken")
+
+	return ctrl.Result{}, nil
+}
+
+// getSnapshot returns the snapshot the KindCluster is restored from, or nil if it doesn't exist or isn't ready yet
+//
+// An error is returned if the snapshot failed, as the cluster can never be restored from it.
+func (r *KindClusterReconciler) getSnapshot(ctx context.Context, kindCluster *infrastructurev1alpha4.KindCluster) (*infrastructurev1alpha4.KindClusterSnapshot, error) {
+	snapshot := &infrastructurev1alpha4.KindClusterSnapshot{}
+	key := client.ObjectKey{Namespace: kindCluster.Namespace, Name: kindCluster.Spec.SnapshotRef.Name}
+	if err := r.Get(ctx, key, snapshot); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+
+	if snapshot.Status.Phase != nil && *snapshot.Status.Phase == infrastructurev1alpha4.KindClusterSnapshotPhaseFailed {
+		if snapshot.Status.FailureMessage != nil {
+			return nil, fmt.Errorf("snapshot %s failed: %s", snapshot.Name, *snapshot.Status.FailureMessage)
+		}
+		return nil, fmt.Errorf("snapshot %s failed", snapshot.Name)
+	}
+	if !snapshot.Status.Ready {
+		return nil, nil
+	}
+
+	// Only the snapshot's etcd data is restored, which must be used by the same Kubernetes version it was taken from
+	image := nodeimage.Resolve(kindCluster.Spec.Image, kindCluster.Spec.Version)
+	if snapshot.Status.Image != nil && *snapshot.Status.Image != image {
+		return nil, fmt.Errorf("snapshot %s was taken of a cluster using node image %s, not %s", snapshot.Name, *snapshot.Status.Image, image)
+	}
+	return snapshot, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *KindClusterSnapshotReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&infrastructurev1alpha4.KindClusterSnapshot{}).
+		Complete(r)
+}
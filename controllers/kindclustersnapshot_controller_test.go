@@ -0,0 +1,213 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1alpha4 "github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+	kindFake "github.com/AverageMarcus/cluster-api-provider-kind/internal/client/fake"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/utils"
+)
+
+func newKindClusterSnapshot(name, clusterName string) *infrastructurev1alpha4.KindClusterSnapshot {
+	return &infrastructurev1alpha4.KindClusterSnapshot{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       infrastructurev1alpha4.KindClusterSnapshotSpec{ClusterName: clusterName},
+	}
+}
+
+func TestReconcileSnapshot(t *testing.T) {
+	ctx := context.Background()
+	kind := kindFake.New()
+	kindCluster, cluster := newOwnedKindCluster()
+	snapshot := newKindClusterSnapshot("test-snapshot", kindCluster.Name)
+	r := newTestReconciler(t, kind, kindCluster, cluster, snapshot, newKindHost("host-a", nil, nil, nil))
+	sr := &KindClusterSnapshotReconciler{Client: r.Client, Clusters: r}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: snapshot.Namespace, Name: snapshot.Name}}
+
+	// The snapshot waits for the KindCluster to be ready
+	result, err := sr.Reconcile(ctx, req)
+	if err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if result.RequeueAfter != snapshotRequeueDelay {
+		t.Errorf("unexpected result - wanted %+v, got %+v", snapshotRequeueDelay, result.RequeueAfter)
+	}
+
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: kindCluster.Namespace, Name: kindCluster.Name}}); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if _, err := sr.Reconcile(ctx, req); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+
+	actual := &infrastructurev1alpha4.KindClusterSnapshot{}
+	if err := r.Get(ctx, req.NamespacedName, actual); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if !actual.Status.Ready || *actual.Status.Phase != infrastructurev1alpha4.KindClusterSnapshotPhaseReady {
+		t.Errorf("was expecting the snapshot to be ready - %+v", actual.Status)
+	}
+	if actual.Status.Host == nil || *actual.Status.Host != "host-a" {
+		t.Errorf("was expecting the snapshot to be recorded on host-a - %+v", actual.Status.Host)
+	}
+	if info, _ := kind.GetSnapshot("default_test-snapshot"); info == nil {
+		t.Errorf("was expecting the snapshot to exist in Kind")
+	}
+
+	if err := r.Delete(ctx, actual); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if _, err := sr.Reconcile(ctx, req); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if info, _ := kind.GetSnapshot("default_test-snapshot"); info != nil {
+		t.Errorf("was expecting the snapshot to be removed from Kind")
+	}
+	if err := r.Get(ctx, req.NamespacedName, actual); client.IgnoreNotFound(err) != nil || err == nil {
+		t.Errorf("was expecting the KindClusterSnapshot to be removed - %+v", err)
+	}
+}
+
+func TestReconcileRestore(t *testing.T) {
+	ctx := context.Background()
+	kind := kindFake.New()
+	source, sourceCluster := newOwnedKindCluster()
+	snapshot := newKindClusterSnapshot("test-snapshot", source.Name)
+
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "restored", Namespace: "default"},
+	}
+	restored := source.DeepCopy()
+	restored.Name = "restored"
+	restored.OwnerReferences[0].Name = "restored"
+	restored.Spec.Name = "default-restored"
+	restored.Spec.SnapshotRef = &corev1.LocalObjectReference{Name: snapshot.Name}
+
+	r := newTestReconciler(t, kind, source, sourceCluster, restored, cluster, snapshot,
+		newKindHost("host-a", nil, nil, nil), newKindHost("host-b", nil, nil, nil))
+	sr := &KindClusterSnapshotReconciler{Client: r.Client, Clusters: r}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: restored.Namespace, Name: restored.Name}}
+
+	// The restored cluster waits for the snapshot to be ready
+	result, err := r.Reconcile(ctx, req)
+	if err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if result.RequeueAfter != snapshotRequeueDelay {
+		t.Errorf("unexpected result - wanted %+v, got %+v", snapshotRequeueDelay, result.RequeueAfter)
+	}
+	if _, err := kind.Get("default-restored"); err == nil {
+		t.Errorf("was expecting the cluster not to be created before the snapshot is ready")
+	}
+
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: source.Namespace, Name: source.Name}}); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if _, err := sr.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: snapshot.Namespace, Name: snapshot.Name}}); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	snapshotted := &infrastructurev1alpha4.KindClusterSnapshot{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: snapshot.Namespace, Name: snapshot.Name}, snapshotted); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+
+	actual := &infrastructurev1alpha4.KindCluster{}
+	if err := r.Get(ctx, req.NamespacedName, actual); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if !actual.Status.Ready {
+		t.Errorf("was expecting the cluster to be ready - %+v", actual.Status)
+	}
+	if actual.Status.Host == nil || *actual.Status.Host != *snapshotted.Status.Host {
+		t.Errorf("was expecting the cluster to be placed on the snapshot's host %s - %+v", *snapshotted.Status.Host, actual.Status.Host)
+	}
+	kindState, err := kind.Get("default-restored")
+	if err != nil {
+		t.Fatalf("was expecting the cluster to exist in Kind - %+v", err)
+	}
+	if kindState.RestoredFrom != "default_test-snapshot" {
+		t.Errorf("unexpected result - wanted %+v, got %+v", "default_test-snapshot", kindState.RestoredFrom)
+	}
+}
+
+func TestReconcileRestoreFailedSnapshot(t *testing.T) {
+	ctx := context.Background()
+	kind := kindFake.New()
+	kindCluster, cluster := newOwnedKindCluster()
+	kindCluster.Spec.SnapshotRef = &corev1.LocalObjectReference{Name: "test-snapshot"}
+	snapshot := newKindClusterSnapshot("test-snapshot", "other")
+	snapshot.Status.Phase = &infrastructurev1alpha4.KindClusterSnapshotPhaseFailed
+	message := "no nodes found"
+	snapshot.Status.FailureMessage = &message
+	r := newTestReconciler(t, kind, kindCluster, cluster, snapshot, newKindHost("host-a", nil, nil, nil))
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: kindCluster.Namespace, Name: kindCluster.Name}}
+
+	if _, err := r.Reconcile(ctx, req); err == nil {
+		t.Fatalf("was expecting an error")
+	}
+
+	actual := &infrastructurev1alpha4.KindCluster{}
+	if err := r.Get(ctx, req.NamespacedName, actual); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if actual.Status.FailureReason == nil || *actual.Status.FailureReason != infrastructurev1alpha4.FailureReasonSnapshot {
+		t.Errorf("unexpected result - wanted %+v, got %+v", infrastructurev1alpha4.FailureReasonSnapshot, actual.Status.FailureReason)
+	}
+}
+
+func TestReconcileRestoreDifferentImage(t *testing.T) {
+	ctx := context.Background()
+	kind := kindFake.New()
+	kindCluster, cluster := newOwnedKindCluster()
+	kindCluster.Spec.SnapshotRef = &corev1.LocalObjectReference{Name: "test-snapshot"}
+	snapshot := newKindClusterSnapshot("test-snapshot", "other")
+	snapshot.Status.Ready = true
+	snapshot.Status.Phase = &infrastructurev1alpha4.KindClusterSnapshotPhaseReady
+	snapshot.Status.Host = utils.StringPtr("host-a")
+	snapshot.Status.Image = utils.StringPtr("kindest/node:v1.20.7")
+	r := newTestReconciler(t, kind, kindCluster, cluster, snapshot, newKindHost("host-a", nil, nil, nil))
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: kindCluster.Namespace, Name: kindCluster.Name}}
+
+	if _, err := r.Reconcile(ctx, req); err == nil {
+		t.Fatalf("was expecting an error")
+	}
+
+	actual := &infrastructurev1alpha4.KindCluster{}
+	if err := r.Get(ctx, req.NamespacedName, actual); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if actual.Status.FailureReason == nil || *actual.Status.FailureReason != infrastructurev1alpha4.FailureReasonSnapshot {
+		t.Errorf("unexpected result - wanted %+v, got %+v", infrastructurev1alpha4.FailureReasonSnapshot, actual.Status.FailureReason)
+	}
+	if _, err := kind.Get(kindCluster.KindName()); err == nil {
+		t.Errorf("was expecting the cluster not to be created in Kind")
+	}
+}
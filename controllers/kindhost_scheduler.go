@@ -0,0 +1,179 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrastructurev1alpha4 "github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+	kindClient "github.com/AverageMarcus/cluster-api-provider-kind/internal/client"
+)
+
+// errNoHostCapacity indicates none of the matching KindHosts currently have capacity for the cluster
+var errNoHostCapacity = errors.New("no KindHost has capacity for the cluster")
+
+// hostLoad contains the resources used on a KindHost by the clusters placed on it
+type hostLoad struct {
+	clusters int32
+	nodes    int32
+}
+
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=kindhosts,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+
+// scheduleHost picks the least loaded KindHost matching the cluster's host selector that has capacity for the cluster
+//
+// If no KindHosts exist and no selector is set nil is returned so the default Kind server is used.
+func (r *KindClusterReconciler) scheduleHost(ctx context.Context, kindCluster *infrastructurev1alpha4.KindCluster) (*infrastructurev1alpha4.KindHost, error) {
+	listOpts := []client.ListOption{}
+	if kindCluster.Spec.HostSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(kindCluster.Spec.HostSelector)
+		if err != nil {
+			return nil, err
+		}
+		listOpts = append(listOpts, client.MatchingLabelsSelector{Selector: selector})
+	}
+
+	hosts := &infrastructurev1alpha4.KindHostList{}
+	if err := r.List(ctx, hosts, listOpts...); err != nil {
+		return nil, err
+	}
+
+	if len(hosts.Items) == 0 {
+		if kindCluster.Spec.HostSelector != nil {
+			return nil, fmt.Errorf("no KindHost matches the hostSelector")
+		}
+		return nil, nil
+	}
+
+	kindClusters := &infrastructurev1alpha4.KindClusterList{}
+	if err := r.List(ctx, kindClusters); err != nil {
+		return nil, err
+	}
+
+	loads := map[string]hostLoad{}
+	for _, placedCluster := range kindClusters.Items {
+		if placedCluster.Status.Host == nil || placedCluster.UID == kindCluster.UID {
+			continue
+		}
+		load := loads[*placedCluster.Status.Host]
+		load.clusters++
+		load.nodes += placedCluster.NodeCount()
+		loads[*placedCluster.Status.Host] = load
+	}
+
+	sort.Slice(hosts.Items, func(i, j int) bool {
+		return hosts.Items[i].Name < hosts.Items[j].Name
+	})
+
+	var chosenHost *infrastructurev1alpha4.KindHost
+	var chosenLoad hostLoad
+	for i := range hosts.Items {
+		host := &hosts.Items[i]
+		load := loads[host.Name]
+
+		if host.Spec.MaxClusters != nil && load.clusters+1 > *host.Spec.MaxClusters {
+			continue
+		}
+		if host.Spec.MaxNodes != nil && load.nodes+kindCluster.NodeCount() > *host.Spec.MaxNodes {
+			continue
+		}
+
+		if chosenHost == nil || load.clusters < chosenLoad.clusters ||
+			(load.clusters == chosenLoad.clusters && load.nodes < chosenLoad.nodes) {
+			chosenHost = host
+			chosenLoad = load
+		}
+	}
+
+	if chosenHost == nil {
+		return nil, errNoHostCapacity
+	}
+
+	return chosenHost, nil
+}
+
+// kindClient returns a client for the Kind server the cluster has been placed on
+func (r *KindClusterReconciler) kindClient(ctx context.Context, kindCluster *infrastructurev1alpha4.KindCluster) (kindClient.Interface, error) {
+	return r.hostKindClient(ctx, kindCluster.Status.Host)
+}
+
+// hostKindClient returns a client for the Kind server of the named KindHost, or the default Kind server if nil
+func (r *KindClusterReconciler) hostKindClient(ctx context.Context, hostName *string) (kindClient.Interface, error) {
+	opts, err := r.kindServer(ctx, hostName)
+	if err != nil {
+		return nil, err
+	}
+	return r.newKindClient(ctx, opts)
+}
+
+// newKindClient returns a client for the Kind server with the given options
+func (r *KindClusterReconciler) newKindClient(ctx context.Context, opts kindClient.Options) (kindClient.Interface, error) {
+	opts.Logger = log.FromContext(ctx).WithName("kind-client")
+	opts.ManagementClusterID = r.ManagementClusterID
+
+	if r.NewKindClient != nil {
+		return r.NewKindClient(opts)
+	}
+	return kindClient.New(opts)
+}
+
+// kindServer returns the client options for the Kind server of the named KindHost, or the default Kind server if nil
+func (r *KindClusterReconciler) kindServer(ctx context.Context, hostName *string) (kindClient.Options, error) {
+	if hostName == nil {
+		if r.KindServer == nil {
+			return kindClient.Options{}, fmt.Errorf("cluster has not been placed on a KindHost and no default Kind server is configured")
+		}
+		return *r.KindServer, nil
+	}
+
+	host := &infrastructurev1alpha4.KindHost{}
+	if err := r.Get(ctx, client.ObjectKey{Name: *hostName}, host); err != nil {
+		return kindClient.Options{}, errors.Wrapf(err, "failed to get KindHost %s", *hostName)
+	}
+	return r.hostServer(ctx, host)
+}
+
+// hostServer returns the client options for the KindHost's Kind server
+//
+// KindHosts share the timeout, retry, TLS and user agent settings of the default Kind server.
+func (r *KindClusterReconciler) hostServer(ctx context.Context, host *infrastructurev1alpha4.KindHost) (kindClient.Options, error) {
+	opts := kindClient.Options{}
+	if r.KindServer != nil {
+		opts = *r.KindServer
+	}
+
+	opts.BaseURL = host.Spec.Endpoint
+	opts.Token = ""
+	if ref := host.Spec.CredentialsSecretRef; ref != nil {
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, client.ObjectKey{Namespace: ref.Namespace, Name: ref.Name}, secret); err != nil {
+			return kindClient.Options{}, errors.Wrapf(err, "failed to get credentials for KindHost %s", host.Name)
+		}
+		opts.Token = string(secret.Data["token"])
+	}
+
+	return opts, nil
+}
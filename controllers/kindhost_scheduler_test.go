@@ -0,0 +1,174 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrastructurev1alpha4 "github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+)
+
+func newKindHost(name string, labels map[string]string, maxClusters, maxNodes *int32) *infrastructurev1alpha4.KindHost {
+	return &infrastructurev1alpha4.KindHost{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec: infrastructurev1alpha4.KindHostSpec{
+			Endpoint:    "http://" + name + ":3000",
+			MaxClusters: maxClusters,
+			MaxNodes:    maxNodes,
+		},
+	}
+}
+
+func newPlacedKindCluster(name, host string, replicas int32) *infrastructurev1alpha4.KindCluster {
+	return &infrastructurev1alpha4.KindCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name)},
+		Spec: infrastructurev1alpha4.KindClusterSpec{
+			Nodes: []infrastructurev1alpha4.KindNode{{Role: infrastructurev1alpha4.NodeRoleControlPlane, Count: replicas}},
+		},
+		Status: infrastructurev1alpha4.KindClusterStatus{Host: pointer.String(host)},
+	}
+}
+
+// newUndefaultedKindCluster returns a placed KindCluster created before its nodes were defaulted by the webhook
+func newUndefaultedKindCluster(name, host string, replicas int32) *infrastructurev1alpha4.KindCluster {
+	kindCluster := newPlacedKindCluster(name, host, replicas)
+	kindCluster.Spec.Nodes = nil
+	kindCluster.Spec.Replicas = replicas
+	return kindCluster
+}
+
+func TestScheduleHost(t *testing.T) {
+	tests := []struct {
+		name         string
+		objects      []client.Object
+		hostSelector *metav1.LabelSelector
+		expected     string
+		expectedErr  bool
+	}{
+		{
+			name:     "No hosts",
+			expected: "",
+		},
+		{
+			name:         "No hosts matching selector",
+			objects:      []client.Object{newKindHost("a", nil, nil, nil)},
+			hostSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"zone": "b"}},
+			expectedErr:  true,
+		},
+		{
+			name:     "Single host",
+			objects:  []client.Object{newKindHost("a", nil, nil, nil)},
+			expected: "a",
+		},
+		{
+			name: "Least clusters",
+			objects: []client.Object{
+				newKindHost("a", nil, nil, nil),
+				newKindHost("b", nil, nil, nil),
+				newPlacedKindCluster("existing", "a", 1),
+			},
+			expected: "b",
+		},
+		{
+			name: "Fewest nodes on tie",
+			objects: []client.Object{
+				newKindHost("a", nil, nil, nil),
+				newKindHost("b", nil, nil, nil),
+				newPlacedKindCluster("existing-a", "a", 3),
+				newPlacedKindCluster("existing-b", "b", 1),
+			},
+			expected: "b",
+		},
+		{
+			name: "Matching selector",
+			objects: []client.Object{
+				newKindHost("a", map[string]string{"zone": "a"}, nil, nil),
+				newKindHost("b", map[string]string{"zone": "b"}, nil, nil),
+				newPlacedKindCluster("existing", "b", 1),
+			},
+			hostSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"zone": "b"}},
+			expected:     "b",
+		},
+		{
+			name: "Skip host at max clusters",
+			objects: []client.Object{
+				newKindHost("a", nil, pointer.Int32(1), nil),
+				newKindHost("b", nil, nil, nil),
+				newPlacedKindCluster("existing-a", "b", 1),
+				newPlacedKindCluster("existing-b", "a", 1),
+			},
+			expected: "b",
+		},
+		{
+			name: "Skip host at max nodes",
+			objects: []client.Object{
+				newKindHost("a", nil, nil, pointer.Int32(2)),
+			},
+			expected:    "",
+			expectedErr: true,
+		},
+		{
+			name: "Count nodes of clusters without defaulted nodes",
+			objects: []client.Object{
+				newKindHost("a", nil, nil, pointer.Int32(4)),
+				newKindHost("b", nil, nil, nil),
+				newUndefaultedKindCluster("existing-a", "a", 3),
+				newPlacedKindCluster("existing-b1", "b", 1),
+				newPlacedKindCluster("existing-b2", "b", 1),
+			},
+			expected: "b",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			if err := infrastructurev1alpha4.AddToScheme(scheme); err != nil {
+				t.Fatalf("failed to build scheme - %+v", err)
+			}
+
+			r := &KindClusterReconciler{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(tc.objects...).Build(),
+			}
+
+			kindCluster := newPlacedKindCluster("new", "", 3)
+			kindCluster.Status.Host = nil
+			kindCluster.Spec.HostSelector = tc.hostSelector
+
+			host, err := r.scheduleHost(context.Background(), kindCluster)
+			if tc.expectedErr != (err != nil) {
+				t.Fatalf("unexpected error - %+v", err)
+			}
+
+			actual := ""
+			if host != nil {
+				actual = host.Name
+			}
+			if actual != tc.expected {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tc.expected, actual)
+			}
+		})
+	}
+}
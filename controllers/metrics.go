@@ -0,0 +1,141 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	infrastructurev1alpha4 "github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+)
+
+const (
+	metricsNamespace = "capk"
+
+	resultSuccess = "success"
+	resultFailure = "failure"
+
+	// phaseCollectTimeout is the longest listing KindClusters can take when metrics are scraped
+	phaseCollectTimeout = 10 * time.Second
+)
+
+var (
+	// operationBuckets cover Kind operations taking from a few seconds up to 10 minutes
+	operationBuckets = []float64{5, 10, 20, 30, 45, 60, 90, 120, 180, 300, 600}
+
+	createDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "kindcluster",
+		Name:      "create_duration_seconds",
+		Help:      "Time taken by the Kind server to create a cluster.",
+		Buckets:   operationBuckets,
+	}, []string{"result"})
+
+	deleteDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "kindcluster",
+		Name:      "delete_duration_seconds",
+		Help:      "Time taken by the Kind server to delete a cluster.",
+		Buckets:   operationBuckets,
+	}, []string{"result"})
+
+	failures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "kindcluster",
+		Name:      "failures_total",
+		Help:      "Number of times reconciling a KindCluster failed, by failure reason.",
+	}, []string{"reason"})
+
+	orphansDeleted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "kindcluster",
+		Name:      "orphans_deleted_total",
+		Help:      "Number of Kind clusters deleted because the KindCluster that owned them no longer exists.",
+	})
+
+	phaseDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "kindcluster", "phase"),
+		"Number of KindClusters in each phase.",
+		[]string{"phase"}, nil,
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(createDuration, deleteDuration, failures, orphansDeleted)
+}
+
+// observeDuration records the time since start in the histogram, labelled with the result of the operation
+func observeDuration(histogram *prometheus.HistogramVec, start time.Time, err error) {
+	result := resultSuccess
+	if err != nil {
+		result = resultFailure
+	}
+	histogram.WithLabelValues(result).Observe(time.Since(start).Seconds())
+}
+
+// phaseCollector reports the number of KindClusters in each phase when metrics are scraped
+type phaseCollector struct {
+	client client.Reader
+}
+
+// Describe meets the prometheus.Collector interface
+func (c phaseCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- phaseDesc
+}
+
+// Collect meets the prometheus.Collector interface
+func (c phaseCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), phaseCollectTimeout)
+	defer cancel()
+
+	kindClusters := &infrastructurev1alpha4.KindClusterList{}
+	if err := c.client.List(ctx, kindClusters); err != nil {
+		ch <- prometheus.NewInvalidMetric(phaseDesc, err)
+		return
+	}
+
+	counts := map[string]int{
+		phaseName(infrastructurev1alpha4.KindClusterPhasePending):   0,
+		phaseName(infrastructurev1alpha4.KindClusterPhaseCreating):  0,
+		phaseName(infrastructurev1alpha4.KindClusterPhaseReady):     0,
+		phaseName(infrastructurev1alpha4.KindClusterPhaseDeleting):  0,
+		phaseName(infrastructurev1alpha4.KindClusterPhaseSuspended): 0,
+	}
+	for _, kindCluster := range kindClusters.Items {
+		phase := infrastructurev1alpha4.KindClusterPhasePending
+		if kindCluster.Status.Phase != nil {
+			phase = *kindCluster.Status.Phase
+		}
+		counts[phaseName(phase)]++
+	}
+
+	for phase, count := range counts {
+		ch <- prometheus.MustNewConstMetric(phaseDesc, prometheus.GaugeValue, float64(count), phase)
+	}
+}
+
+// phaseName returns the metric label for the phase, the pending phase is an empty string
+func phaseName(phase infrastructurev1alpha4.KindClusterPhase) string {
+	if phase == infrastructurev1alpha4.KindClusterPhasePending {
+		return "Pending"
+	}
+	return string(phase)
+}
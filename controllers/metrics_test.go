@@ -0,0 +1,63 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrastructurev1alpha4 "github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+)
+
+func TestPhaseCollector(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := infrastructurev1alpha4.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build scheme - %+v", err)
+	}
+
+	newKindCluster := func(name string, phase *infrastructurev1alpha4.KindClusterPhase) *infrastructurev1alpha4.KindCluster {
+		return &infrastructurev1alpha4.KindCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Status:     infrastructurev1alpha4.KindClusterStatus{Phase: phase},
+		}
+	}
+	client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		newKindCluster("a", nil),
+		newKindCluster("b", &infrastructurev1alpha4.KindClusterPhaseReady),
+		newKindCluster("c", &infrastructurev1alpha4.KindClusterPhaseReady),
+		newKindCluster("d", &infrastructurev1alpha4.KindClusterPhaseCreating),
+		newKindCluster("e", &infrastructurev1alpha4.KindClusterPhaseSuspended),
+	).Build()
+
+	expected := `
+# HELP capk_kindcluster_phase Number of KindClusters in each phase.
+# TYPE capk_kindcluster_phase gauge
+capk_kindcluster_phase{phase="Creating"} 1
+capk_kindcluster_phase{phase="Deleting"} 0
+capk_kindcluster_phase{phase="Pending"} 1
+capk_kindcluster_phase{phase="Ready"} 2
+capk_kindcluster_phase{phase="Suspended"} 1
+`
+	if err := testutil.CollectAndCompare(phaseCollector{client: client}, strings.NewReader(expected)); err != nil {
+		t.Errorf("unexpected result - %+v", err)
+	}
+}
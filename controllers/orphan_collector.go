@@ -0,0 +1,149 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	infrastructurev1alpha4 "github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+	kindClient "github.com/AverageMarcus/cluster-api-provider-kind/internal/client"
+)
+
+var _ manager.LeaderElectionRunnable = &OrphanCollector{}
+
+// OrphanCollector periodically deletes Kind clusters whose owning KindCluster no longer exists, such as when the
+// KindCluster was force-deleted by removing its finalizer
+//
+// Only clusters with the ownership labels recorded by the Kind server are considered, other clusters on the host
+// are left alone. When the reconciler's management cluster ID is set only the clusters it created are considered,
+// so management clusters sharing a Kind server don't delete each other's clusters.
+type OrphanCollector struct {
+	// Reconciler provides access to the management cluster and the Kind servers
+	Reconciler *KindClusterReconciler
+	// Interval is the time between collections
+	Interval time.Duration
+}
+
+// Start runs a collection every interval until the context is done
+func (o *OrphanCollector) Start(ctx context.Context) error {
+	ctx = log.IntoContext(ctx, log.FromContext(ctx).WithName("orphan-collector"))
+
+	ticker := time.NewTicker(o.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			o.Collect(ctx)
+		}
+	}
+}
+
+// NeedLeaderElection ensures only the leader deletes clusters
+func (o *OrphanCollector) NeedLeaderElection() bool {
+	return true
+}
+
+// Collect deletes the orphaned clusters on the default Kind server and every KindHost
+//
+// Failures are logged and the cluster or server skipped, to be tried again next time.
+func (o *OrphanCollector) Collect(ctx context.Context) {
+	log := log.FromContext(ctx)
+
+	servers, err := o.servers(ctx)
+	if err != nil {
+		log.Error(err, "failed to find Kind servers")
+	}
+
+	for name, opts := range servers {
+		kind, err := o.Reconciler.newKindClient(ctx, opts)
+		if err != nil {
+			log.Error(err, "failed to create Kind client", "server", name)
+			continue
+		}
+		o.collectServer(ctx, name, kind)
+	}
+}
+
+// servers returns the client options of each Kind server, by KindHost name, with the default server having no name
+func (o *OrphanCollector) servers(ctx context.Context) (map[string]kindClient.Options, error) {
+	servers := map[string]kindClient.Options{}
+	if o.Reconciler.KindServer != nil {
+		servers[""] = *o.Reconciler.KindServer
+	}
+
+	hosts := &infrastructurev1alpha4.KindHostList{}
+	if err := o.Reconciler.List(ctx, hosts); err != nil {
+		return servers, err
+	}
+	for i := range hosts.Items {
+		opts, err := o.Reconciler.hostServer(ctx, &hosts.Items[i])
+		if err != nil {
+			log.FromContext(ctx).Error(err, "failed to get Kind server", "server", hosts.Items[i].Name)
+			continue
+		}
+		servers[hosts.Items[i].Name] = opts
+	}
+	return servers, nil
+}
+
+// collectServer deletes the orphaned clusters on a single Kind server
+func (o *OrphanCollector) collectServer(ctx context.Context, serverName string, kind kindClient.Interface) {
+	log := log.FromContext(ctx).WithValues("server", serverName)
+
+	clusters, err := kind.ListClusters(ctx)
+	if err != nil {
+		log.Error(err, "failed to list clusters")
+		return
+	}
+
+	for _, cluster := range clusters {
+		namespace, name, uid := cluster.Labels[infrastructurev1alpha4.OwnerNamespaceLabel],
+			cluster.Labels[infrastructurev1alpha4.OwnerNameLabel], cluster.Labels[infrastructurev1alpha4.OwnerUIDLabel]
+		if namespace == "" || name == "" || uid == "" {
+			continue
+		}
+		if o.Reconciler.ManagementClusterID != "" &&
+			cluster.Labels[infrastructurev1alpha4.ManagementClusterLabel] != o.Reconciler.ManagementClusterID {
+			continue
+		}
+
+		owner := &infrastructurev1alpha4.KindCluster{}
+		err := o.Reconciler.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, owner)
+		if err == nil && string(owner.UID) == uid {
+			continue
+		} else if err != nil && !apierrors.IsNotFound(err) {
+			log.Error(err, "failed to get owner of cluster", "cluster", cluster.Name)
+			continue
+		}
+
+		log.Info("Deleting orphaned Kind cluster", "cluster", cluster.Name, "owner", namespace+"/"+name, "uid", uid)
+		if err := kind.DeleteCluster(ctx, cluster.Name, cluster.NodeProvider); err != nil {
+			log.Error(err, "failed to delete orphaned cluster", "cluster", cluster.Name)
+			continue
+		}
+		orphansDeleted.Inc()
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrastructurev1alpha4 "github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+	kindFake "github.com/AverageMarcus/cluster-api-provider-kind/internal/client/fake"
+	pkgKindFake "github.com/AverageMarcus/cluster-api-provider-kind/pkg/kind/fake"
+)
+
+func TestOrphanCollector(t *testing.T) {
+	owner := &infrastructurev1alpha4.KindCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "owner", Namespace: "default", UID: "owner-uid"},
+	}
+	ownedBy := func(namespace, name, uid string) map[string]string {
+		return map[string]string{
+			infrastructurev1alpha4.OwnerNamespaceLabel: namespace,
+			infrastructurev1alpha4.OwnerNameLabel:      name,
+			infrastructurev1alpha4.OwnerUIDLabel:       uid,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		labels  map[string]string
+		deleted bool
+	}{
+		{name: "owner exists", labels: ownedBy("default", "owner", "owner-uid")},
+		{name: "owner deleted", labels: ownedBy("default", "deleted", "deleted-uid"), deleted: true},
+		{name: "owner replaced", labels: ownedBy("default", "owner", "previous-uid"), deleted: true},
+		{name: "no ownership labels"},
+		{name: "partial ownership labels", labels: map[string]string{infrastructurev1alpha4.OwnerNameLabel: "deleted"}},
+	}
+
+	kind := kindFake.New()
+	for _, tc := range tests {
+		kind.Add(tc.name, pkgKindFake.Cluster{NodeProvider: infrastructurev1alpha4.NodeProviderDocker, Labels: tc.labels})
+	}
+	r := newTestReconciler(t, kind, owner, newKindHost("host-a", nil, nil, nil))
+	deletedBefore := testutil.ToFloat64(orphansDeleted)
+
+	(&OrphanCollector{Reconciler: r}).Collect(context.Background())
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := kind.Get(tc.name)
+			if deleted := err != nil; deleted != tc.deleted {
+				t.Errorf("unexpected result - wanted deleted %+v, got %+v", tc.deleted, deleted)
+			}
+		})
+	}
+	if deleted := testutil.ToFloat64(orphansDeleted) - deletedBefore; deleted != 2 {
+		t.Errorf("unexpected result - wanted %+v, got %+v", 2, deleted)
+	}
+}
+
+func TestOrphanCollectorManagementCluster(t *testing.T) {
+	ownedBy := func(managementClusterID string) map[string]string {
+		labels := map[string]string{
+			infrastructurev1alpha4.OwnerNamespaceLabel: "default",
+			infrastructurev1alpha4.OwnerNameLabel:      "deleted",
+			infrastructurev1alpha4.OwnerUIDLabel:       "deleted-uid",
+		}
+		if managementClusterID != "" {
+			labels[infrastructurev1alpha4.ManagementClusterLabel] = managementClusterID
+		}
+		return labels
+	}
+
+	tests := []struct {
+		name    string
+		labels  map[string]string
+		deleted bool
+	}{
+		{name: "same management cluster", labels: ownedBy("mc-1"), deleted: true},
+		{name: "other management cluster", labels: ownedBy("mc-2")},
+		{name: "no management cluster", labels: ownedBy("")},
+	}
+
+	kind := kindFake.New()
+	for _, tc := range tests {
+		kind.Add(tc.name, pkgKindFake.Cluster{NodeProvider: infrastructurev1alpha4.NodeProviderDocker, Labels: tc.labels})
+	}
+	r := newTestReconciler(t, kind, newKindHost("host-a", nil, nil, nil))
+	r.ManagementClusterID = "mc-1"
+
+	(&OrphanCollector{Reconciler: r}).Collect(context.Background())
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := kind.Get(tc.name)
+			if deleted := err != nil; deleted != tc.deleted {
+				t.Errorf("unexpected result - wanted deleted %+v, got %+v", tc.deleted, deleted)
+			}
+		})
+	}
+}
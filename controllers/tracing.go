@@ -0,0 +1,42 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	infrastructurev1alpha4 "github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/tracing"
+)
+
+// instrumentation names the spans created by the controllers
+const instrumentation = "github.com/AverageMarcus/cluster-api-provider-kind/controllers"
+
+// kindClusterKey is the attribute holding the namespace and name of the KindCluster a span relates to
+const kindClusterKey = attribute.Key("capk.kindcluster")
+
+// traceStep starts a span for a step of reconciling the KindCluster, returning a func that ends it with the
+// step's result
+func traceStep(ctx context.Context, name string, kindCluster *infrastructurev1alpha4.KindCluster) (context.Context, func(err error)) {
+	return tracing.StartSpan(ctx, instrumentation, name, trace.WithAttributes(
+		kindClusterKey.String(kindCluster.NamespacedName()),
+		tracing.ClusterNameKey.String(kindCluster.KindName()),
+	))
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	kindFake "github.com/AverageMarcus/cluster-api-provider-kind/internal/client/fake"
+)
+
+func TestReconcileTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	kindCluster, cluster := newOwnedKindCluster()
+	r := newTestReconciler(t, kindFake.New(), kindCluster, cluster, newKindHost("host-a", nil, nil, nil))
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: kindCluster.Namespace, Name: kindCluster.Name}}
+
+	if _, err := r.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) == 0 {
+		t.Fatalf("was expecting spans to be recorded")
+	}
+	reconcileSpan := spans[len(spans)-1]
+	if reconcileSpan.Name() != "KindCluster.Reconcile" {
+		t.Fatalf("unexpected result - wanted %+v, got %+v", "KindCluster.Reconcile", reconcileSpan.Name())
+	}
+
+	steps := []string{}
+	for _, span := range spans[:len(spans)-1] {
+		if span.Parent().SpanID() == reconcileSpan.SpanContext().SpanID() {
+			steps = append(steps, span.Name())
+		}
+	}
+	expected := []string{"scheduleHost", "resolveKindConfig", "CreateCluster", "IsReady", "GetKubeConfig", "nodesReady"}
+	if !reflect.DeepEqual(steps, expected) {
+		t.Errorf("unexpected result - wanted %+v, got %+v", expected, steps)
+	}
+}
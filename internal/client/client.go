@@ -1,105 +1,496 @@
 package client
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/kind/types"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/tracing"
 )
 
-var client = http.Client{Timeout: 300 * time.Second}
+// instrumentation names the spans created by the client
+const instrumentation = "github.com/AverageMarcus/cluster-api-provider-kind/internal/client"
 
-// CreateCluster creates a new cluster in Kind
-func CreateCluster(kindCluster *v1alpha4.KindCluster) error {
-	payload, err := json.Marshal(*kindCluster)
-	if err != nil {
-		return err
+const (
+	// DefaultTimeout is the default timeout of each request to the Kind server, long enough for a cluster to be created
+	DefaultTimeout = 300 * time.Second
+	// DefaultUserAgent is the default User-Agent sent to the Kind server
+	DefaultUserAgent = "cluster-api-provider-kind"
+
+	// ManagementClusterHeader identifies the management cluster making the request to the Kind server
+	ManagementClusterHeader = "X-Management-Cluster"
+
+	// defaultRetryAfter is used when the server rejects a request as busy without saying when to retry
+	defaultRetryAfter = 30 * time.Second
+)
+
+// DefaultRetryPolicy is used when no retry policy is configured
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, Backoff: time.Second}
+
+// ServerBusyError is returned when the Kind server can't currently handle the request, either because it doesn't
+// have capacity or because another operation is in progress on the cluster
+type ServerBusyError struct {
+	// Reason is the explanation given by the server
+	Reason string
+	// RetryAfter is how long the server asked callers to wait before retrying
+	RetryAfter time.Duration
+	// InProgress is set when another operation is in progress on the cluster, rather than the server lacking capacity
+	InProgress bool
+}
+
+func (e *ServerBusyError) Error() string {
+	return fmt.Sprintf("Kind server is busy, retry after %s: %s", e.RetryAfter, e.Reason)
+}
+
+// Interface contains the Kind server operations used by the controller
+type Interface interface {
+	// CreateCluster creates a new cluster in Kind, returning the node provider used
+	CreateCluster(ctx context.Context, kindCluster *v1alpha4.KindCluster) (v1alpha4.NodeProvider, error)
+	// IsReady checks if the cluster is ready in Kind
+	IsReady(ctx context.Context, clusterName string, nodeProvider v1alpha4.NodeProvider) (bool, error)
+	// GetKubeConfig returns the KubeConfig for the cluster in Kind matching the given name
+	GetKubeConfig(ctx context.Context, clusterName string, nodeProvider v1alpha4.NodeProvider) (string, error)
+	// DeleteCluster removes the cluster from Kind
+	DeleteCluster(ctx context.Context, clusterName string, nodeProvider v1alpha4.NodeProvider) error
+	// StreamLogs calls the handler with each progress message Kind reports during the cluster's operation with the
+	// given ID, returning once the operation is done
+	StreamLogs(ctx context.Context, clusterName, operationID string, handler func(message string)) error
+	// ListClusters returns the clusters on the Kind server along with the labels identifying their owners, limited
+	// to those of the client's management cluster if it has been identified
+	ListClusters(ctx context.Context) ([]types.ClusterInfo, error)
+	// SuspendCluster stops the node containers of the cluster
+	SuspendCluster(ctx context.Context, clusterName string, nodeProvider v1alpha4.NodeProvider) error
+	// ResumeCluster restarts the node containers of a suspended cluster, returning once its API server is ready
+	ResumeCluster(ctx context.Context, clusterName string, nodeProvider v1alpha4.NodeProvider) error
+	// CreateSnapshot takes a snapshot of the cluster that new clusters can be restored from
+	CreateSnapshot(ctx context.Context, clusterName, snapshotName string, nodeProvider v1alpha4.NodeProvider) (types.SnapshotInfo, error)
+	// DeleteSnapshot removes the snapshot from the Kind server
+	DeleteSnapshot(ctx context.Context, snapshotName string) error
+	// ArchiveLogs collects the logs of the cluster's nodes into an archive kept by the Kind server, returning the URL
+	// the archive can be downloaded from
+	ArchiveLogs(ctx context.Context, clusterName string, nodeProvider v1alpha4.NodeProvider) (string, error)
+}
+
+// operationIDKey is the context key of the operation ID
+type operationIDKey struct{}
+
+// WithOperationID returns a context that tags create and delete requests with the operation ID so their
+// progress can be followed with StreamLogs
+func WithOperationID(ctx context.Context, operationID string) context.Context {
+	return context.WithValue(ctx, operationIDKey{}, operationID)
+}
+
+// OperationID returns the operation ID the context was tagged with, if any
+func OperationID(ctx context.Context) string {
+	operationID, _ := ctx.Value(operationIDKey{}).(string)
+	return operationID
+}
+
+// RetryPolicy controls how requests that failed due to a connection error or server error are retried
+//
+// Only idempotent requests are retried, cluster creation is never retried by the client.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts made for a request, including the first
+	MaxAttempts int
+	// Backoff is the wait before the first retry, doubling for each subsequent retry
+	Backoff time.Duration
+}
+
+// Options contains the configuration of a Client
+type Options struct {
+	// BaseURL is the URL of the Kind server
+	BaseURL string
+	// TLSConfig is used when connecting to a Kind server over HTTPS, if set
+	TLSConfig *tls.Config
+	// Token is sent as a bearer token to authenticate with the Kind server, if set
+	Token string
+	// Timeout is the timeout of each request, defaults to DefaultTimeout
+	//
+	// Requests creating a cluster are also allowed the time Kind waits for its control plane to be ready.
+	Timeout time.Duration
+	// Retry is the retry policy, defaults to DefaultRetryPolicy
+	Retry *RetryPolicy
+	// Logger is used to log retried requests, defaults to discarding logs
+	Logger logr.Logger
+	// UserAgent is sent with every request, defaults to DefaultUserAgent
+	UserAgent string
+	// ManagementClusterID identifies the management cluster to the Kind server so clusters created by other
+	// management clusters sharing the server are left alone, if set
+	ManagementClusterID string
+}
+
+// OptionsFromEnv returns the options of the Kind server configured via the `KIND_SERVER_ENDPOINT`,
+// `KIND_SERVER_PORT` and `KIND_SERVER_TOKEN` environment variables, if set
+func OptionsFromEnv() (Options, bool) {
+	endpoint, ok := os.LookupEnv("KIND_SERVER_ENDPOINT")
+	if !ok {
+		return Options{}, false
 	}
+	return Options{
+		BaseURL: fmt.Sprintf("http://%s:%s", endpoint, os.Getenv("KIND_SERVER_PORT")),
+		Token:   os.Getenv("KIND_SERVER_TOKEN"),
+	}, true
+}
+
+// Client talks to a Kind server over HTTP
+type Client struct {
+	baseURL             *url.URL
+	token               string
+	userAgent           string
+	managementClusterID string
+	retry               RetryPolicy
+	log                 logr.Logger
+	httpClient          *http.Client
+	// streamClient is used for long-lived streaming requests so they are only limited by their context
+	streamClient *http.Client
+}
+
+var _ Interface = &Client{}
 
-	resp, err := client.Post(getAPIEndpoint(), "application/json", bytes.NewReader(payload))
+// New creates a new client for the Kind server described by the options
+func New(opts Options) (*Client, error) {
+	baseURL, err := url.Parse(opts.BaseURL)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("invalid Kind server URL %q: %w", opts.BaseURL, err)
 	}
-	if resp.StatusCode >= 400 {
-		fmt.Println(resp.Status)
-		return fmt.Errorf("unexpected error returned from server")
+	if baseURL.Scheme != "http" && baseURL.Scheme != "https" {
+		return nil, fmt.Errorf("invalid Kind server URL %q: scheme must be http or https", opts.BaseURL)
+	}
+
+	c := &Client{
+		baseURL:             baseURL,
+		token:               opts.Token,
+		userAgent:           opts.UserAgent,
+		managementClusterID: opts.ManagementClusterID,
+		retry:               DefaultRetryPolicy,
+		log:                 opts.Logger,
+		httpClient: &http.Client{
+			Timeout: opts.Timeout,
+		},
+	}
+	if c.userAgent == "" {
+		c.userAgent = DefaultUserAgent
+	}
+	if opts.Retry != nil {
+		c.retry = *opts.Retry
+	}
+	if c.retry.MaxAttempts < 1 {
+		c.retry.MaxAttempts = 1
 	}
+	if c.log == nil {
+		c.log = logr.Discard()
+	}
+	if c.httpClient.Timeout == 0 {
+		c.httpClient.Timeout = DefaultTimeout
+	}
+	if opts.TLSConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = opts.TLSConfig
+		c.httpClient.Transport = transport
+	}
+	c.streamClient = &http.Client{Transport: c.httpClient.Transport}
 
-	return nil
+	return c, nil
 }
 
-// IsReady checks if the cluster is ready in Kind
-func IsReady(clusterName string) (bool, error) {
-	resp, err := client.Get(fmt.Sprintf("%s/%s", getAPIEndpoint(), clusterName))
+// CreateCluster creates a new cluster in Kind, returning the node provider used
+func (c *Client) CreateCluster(ctx context.Context, kindCluster *v1alpha4.KindCluster) (v1alpha4.NodeProvider, error) {
+	payload, err := json.Marshal(*kindCluster)
 	if err != nil {
-		return false, err
-	}
-	if resp.StatusCode >= 400 {
-		fmt.Println(resp.Status)
-		return false, fmt.Errorf("unexpected error returned from server")
+		return "", err
 	}
 
-	isReady := false
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return false, err
+	// The request isn't retried so mustn't time out while the server is still waiting for the cluster to be ready
+	create := c.withTimeout(c.httpClient.Timeout + kindCluster.WaitForReady())
+	var nodeProvider v1alpha4.NodeProvider
+	if err := create.do(ctx, http.MethodPost, c.clusterURL(operationQuery(ctx, nil)), payload, &nodeProvider); err != nil {
+		return "", err
 	}
+	return nodeProvider, nil
+}
 
-	if err := json.Unmarshal(body, &isReady); err != nil {
+// IsReady checks if the cluster is ready in Kind
+func (c *Client) IsReady(ctx context.Context, clusterName string, nodeProvider v1alpha4.NodeProvider) (bool, error) {
+	isReady := false
+	if err := c.do(ctx, http.MethodGet, c.clusterURL(providerQuery(nodeProvider), clusterName), nil, &isReady); err != nil {
 		return false, err
 	}
-
 	return isReady, nil
 }
 
 // GetKubeConfig returns the KubeConfig for the cluster in Kind matching the given name
-func GetKubeConfig(clusterName string) (string, error) {
-	resp, err := client.Get(fmt.Sprintf("%s/%s/kubeconfig", getAPIEndpoint(), clusterName))
-	if err != nil {
+func (c *Client) GetKubeConfig(ctx context.Context, clusterName string, nodeProvider v1alpha4.NodeProvider) (string, error) {
+	kubeconfig := ""
+	if err := c.do(ctx, http.MethodGet, c.clusterURL(providerQuery(nodeProvider), clusterName, "kubeconfig"), nil, &kubeconfig); err != nil {
 		return "", err
 	}
-	if resp.StatusCode >= 400 {
-		fmt.Println(resp.Status)
-		return "", fmt.Errorf("unexpected error returned from server")
+	return kubeconfig, nil
+}
+
+// DeleteCluster removes the cluster from Kind
+func (c *Client) DeleteCluster(ctx context.Context, clusterName string, nodeProvider v1alpha4.NodeProvider) error {
+	return c.do(ctx, http.MethodDelete, c.clusterURL(operationQuery(ctx, providerQuery(nodeProvider)), clusterName), nil, nil)
+}
+
+// ListClusters returns the clusters on the Kind server along with the labels identifying their owners, limited to
+// those of the client's management cluster if it has been identified
+func (c *Client) ListClusters(ctx context.Context) ([]types.ClusterInfo, error) {
+	clusters := []types.ClusterInfo{}
+	if err := c.do(ctx, http.MethodGet, c.clusterURL(nil), nil, &clusters); err != nil {
+		return nil, err
 	}
+	return clusters, nil
+}
 
-	kubeconfig := ""
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+// SuspendCluster stops the node containers of the cluster
+func (c *Client) SuspendCluster(ctx context.Context, clusterName string, nodeProvider v1alpha4.NodeProvider) error {
+	return c.do(ctx, http.MethodPost, c.clusterURL(providerQuery(nodeProvider), clusterName, "suspend"), nil, nil)
+}
+
+// ResumeCluster restarts the node containers of a suspended cluster, returning once its API server is ready
+func (c *Client) ResumeCluster(ctx context.Context, clusterName string, nodeProvider v1alpha4.NodeProvider) error {
+	return c.do(ctx, http.MethodPost, c.clusterURL(providerQuery(nodeProvider), clusterName, "resume"), nil, nil)
+}
+
+// CreateSnapshot takes a snapshot of the cluster that new clusters can be restored from
+func (c *Client) CreateSnapshot(ctx context.Context, clusterName, snapshotName string, nodeProvider v1alpha4.NodeProvider) (types.SnapshotInfo, error) {
+	snapshot := types.SnapshotInfo{}
+	if err := c.do(ctx, http.MethodPost, c.clusterURL(providerQuery(nodeProvider), clusterName, "snapshots", snapshotName), nil, &snapshot); err != nil {
+		return types.SnapshotInfo{}, err
 	}
+	return snapshot, nil
+}
 
-	if err := json.Unmarshal(body, &kubeconfig); err != nil {
+// DeleteSnapshot removes the snapshot from the Kind server
+func (c *Client) DeleteSnapshot(ctx context.Context, snapshotName string) error {
+	return c.do(ctx, http.MethodDelete, c.clusterURL(nil, "snapshots", snapshotName), nil, nil)
+}
+
+// ArchiveLogs collects the logs of the cluster's nodes into an archive kept by the Kind server, returning the URL
+// the archive can be downloaded from
+func (c *Client) ArchiveLogs(ctx context.Context, clusterName string, nodeProvider v1alpha4.NodeProvider) (string, error) {
+	archive := types.LogArchiveInfo{}
+	if err := c.do(ctx, http.MethodPost, c.clusterURL(providerQuery(nodeProvider), clusterName, "export-logs"), nil, &archive); err != nil {
 		return "", err
 	}
-
-	return kubeconfig, nil
+	return c.clusterURL(nil, "log-archives", archive.Name), nil
 }
 
-// DeleteCluster removes the cluster from Kind
-func DeleteCluster(clusterName string) error {
-	req, err := http.NewRequest("DELETE", fmt.Sprintf("%s/%s", getAPIEndpoint(), clusterName), nil)
+// StreamLogs calls the handler with each progress message Kind reports during the cluster's operation with the
+// given ID, returning once the operation is done
+//
+// The Kind server sends the messages as server-sent events, finishing with a `done` event.
+func (c *Client) StreamLogs(ctx context.Context, clusterName, operationID string, handler func(message string)) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.clusterURL(url.Values{"operation": []string{operationID}}, clusterName, "logs"), nil)
 	if err != nil {
 		return err
 	}
-	resp, err := client.Do(req)
+	c.setHeaders(req)
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.streamClient.Do(req)
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		fmt.Println(resp.Status)
-		return fmt.Errorf("unexpected error returned from server")
+		return responseError(resp)
 	}
 
-	return nil
+	event, data := "", []string{}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if event == "done" {
+				return nil
+			}
+			if len(data) > 0 {
+				handler(strings.Join(data, "\n"))
+			}
+			event, data = "", []string{}
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("log stream for cluster %s ended before the operation was done", clusterName)
+}
+
+// do sends the request, retrying idempotent requests according to the retry policy, and decodes the JSON
+// response into out, if set
+func (c *Client) do(ctx context.Context, method, u string, payload []byte, out interface{}) error {
+	attempts := 1
+	if method != http.MethodPost {
+		attempts = c.retry.MaxAttempts
+	}
+
+	backoff := c.retry.Backoff
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		var retryable bool
+		retryable, err = c.doOnce(ctx, method, u, payload, out)
+		if err == nil || !retryable || attempt == attempts {
+			break
+		}
+
+		c.log.V(1).Info("retrying request to Kind server", "method", method, "url", u, "attempt", attempt, "error", err.Error())
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return err
 }
 
-func getAPIEndpoint() string {
-	return fmt.Sprintf("http://%s:%s", os.Getenv("KIND_SERVER_ENDPOINT"), os.Getenv("KIND_SERVER_PORT"))
+// withTimeout returns a copy of the client whose requests have the given timeout
+func (c *Client) withTimeout(timeout time.Duration) *Client {
+	client := *c
+	client.httpClient = &http.Client{Transport: c.httpClient.Transport, Timeout: timeout}
+	return &client
+}
+
+// doOnce sends a single request, returning whether a failure can be retried
+func (c *Client) doOnce(ctx context.Context, method, u string, payload []byte, out interface{}) (retryable bool, err error) {
+	ctx, endSpan := tracing.StartSpan(ctx, instrumentation, "HTTP "+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPMethodKey.String(method), semconv.HTTPURLKey.String(u)),
+	)
+	defer func() { endSpan(err) }()
+
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return false, err
+	}
+	c.setHeaders(req)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		// Connection errors can be retried, unless the caller gave up
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	trace.SpanFromContext(ctx).SetAttributes(semconv.HTTPStatusCodeKey.Int(resp.StatusCode))
+
+	if resp.StatusCode >= 400 {
+		return resp.StatusCode >= 500, responseError(resp)
+	}
+
+	if out == nil {
+		return false, nil
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return true, err
+	}
+	return false, json.Unmarshal(respBody, out)
+}
+
+// setHeaders sets the headers sent with every request, including the trace context so the Kind server can continue
+// the caller's trace
+func (c *Client) setHeaders(req *http.Request) {
+	req.Header.Set("User-Agent", c.userAgent)
+	if c.token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	}
+	if c.managementClusterID != "" {
+		req.Header.Set(ManagementClusterHeader, c.managementClusterID)
+	}
+	otel.GetTextMapPropagator().Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+}
+
+// clusterURL builds the URL for the given cluster resource from its unescaped path segments
+//
+// Each segment is escaped once in RawPath, so a name containing a `/` stays a single segment.
+func (c *Client) clusterURL(query url.Values, segments ...string) string {
+	u := *c.baseURL
+	path := strings.TrimSuffix(u.Path, "/")
+	rawPath := strings.TrimSuffix(u.EscapedPath(), "/")
+	for _, segment := range segments {
+		path += "/" + segment
+		rawPath += "/" + url.PathEscape(segment)
+	}
+	if len(segments) == 0 {
+		path += "/"
+		rawPath += "/"
+	}
+	u.Path = path
+	u.RawPath = rawPath
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// providerQuery returns the query containing the node provider, if one is known
+func providerQuery(nodeProvider v1alpha4.NodeProvider) url.Values {
+	query := url.Values{}
+	if nodeProvider != "" {
+		query.Set("provider", string(nodeProvider))
+	}
+	return query
+}
+
+// operationQuery adds the context's operation ID, if any, to the query
+func operationQuery(ctx context.Context, query url.Values) url.Values {
+	if query == nil {
+		query = url.Values{}
+	}
+	if operationID := OperationID(ctx); operationID != "" {
+		query.Set("operation", operationID)
+	}
+	return query
+}
+
+// responseError builds an error from an unsuccessful response, including the reason given by the server
+//
+// A ServerBusyError is returned if the server asked for the request to be retried later.
+func responseError(resp *http.Response) error {
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusConflict {
+		retryAfter := defaultRetryAfter
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			retryAfter = time.Duration(seconds) * time.Second
+		}
+		return &ServerBusyError{
+			Reason:     strings.TrimSpace(string(body)),
+			RetryAfter: retryAfter,
+			InProgress: resp.StatusCode == http.StatusConflict,
+		}
+	}
+	return fmt.Errorf("unexpected error returned from server: %s %s", resp.Status, strings.TrimSpace(string(body)))
 }
@@ -1,36 +1,65 @@
 package client
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/kind/types"
 )
 
-var (
-	ts       *httptest.Server
-	response = ""
-)
+// testServer records the requests made to it and replies with the configured response
+type testServer struct {
+	*httptest.Server
 
-func init() {
-	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintln(w, response)
-	}))
+	mu          sync.Mutex
+	status      int
+	response    string
+	requests    int
+	lastRequest *http.Request
+}
 
-	url := strings.TrimPrefix(ts.URL, "http://")
-	parts := strings.Split(url, ":")
+func newTestServer(t *testing.T, response string) *testServer {
+	ts := &testServer{status: http.StatusOK, response: response}
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ts.mu.Lock()
+		defer ts.mu.Unlock()
+		ts.requests++
+		ts.lastRequest = r
+		w.WriteHeader(ts.status)
+		fmt.Fprintln(w, ts.response)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
 
-	os.Setenv("KIND_SERVER_ENDPOINT", parts[0])
-	os.Setenv("KIND_SERVER_PORT", parts[1])
+func newTestClient(t *testing.T, opts Options) *Client {
+	c, err := New(opts)
+	if err != nil {
+		t.Fatalf("failed to create client - %+v", err)
+	}
+	return c
 }
 
 func TestCreateCluster(t *testing.T) {
+	ts := newTestServer(t, "\"docker\"")
+	c := newTestClient(t, Options{BaseURL: ts.URL})
+
 	cluster := &v1alpha4.KindCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "test-cluster",
@@ -38,44 +67,476 @@ func TestCreateCluster(t *testing.T) {
 		},
 	}
 
-	if err := CreateCluster(cluster); err != nil {
+	nodeProvider, err := c.CreateCluster(context.Background(), cluster)
+	if err != nil {
 		t.Errorf("unexpected error when creating cluster - %+v", err)
 	}
+	if nodeProvider != v1alpha4.NodeProviderDocker {
+		t.Errorf("unexpected node provider returned")
+	}
+	if ts.lastRequest.Method != http.MethodPost || ts.lastRequest.URL.Path != "/" {
+		t.Errorf("unexpected request - %s %s", ts.lastRequest.Method, ts.lastRequest.URL.Path)
+	}
 }
 
-func TestIsReady(t *testing.T) {
-	response = "true"
-	result, err := IsReady("test-cluster")
-	if err != nil {
-		t.Errorf("unexpected error when getting status - %+v", err)
+func TestCreateClusterWaitsForReady(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Creating the cluster takes longer than the client's timeout
+		time.Sleep(200 * time.Millisecond)
+		fmt.Fprintln(w, "\"docker\"")
+	}))
+	t.Cleanup(ts.Close)
+	c := newTestClient(t, Options{BaseURL: ts.URL, Timeout: 100 * time.Millisecond, Retry: &RetryPolicy{MaxAttempts: 1}})
+
+	cluster := &v1alpha4.KindCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"},
+		Spec:       v1alpha4.KindClusterSpec{WaitForReady: &metav1.Duration{Duration: time.Second}},
 	}
-	if !result {
-		t.Errorf("was expecting the cluster to be marked as ready")
+	if _, err := c.CreateCluster(context.Background(), cluster); err != nil {
+		t.Errorf("unexpected error - %+v", err)
 	}
 
-	response = "false"
-	result, err = IsReady("test-cluster")
-	if err != nil {
-		t.Errorf("unexpected error when getting status - %+v", err)
+	// Other requests still use the client's timeout
+	if _, err := c.IsReady(context.Background(), "test-cluster", ""); err == nil {
+		t.Errorf("was expecting the request to time out")
 	}
-	if result {
-		t.Errorf("was expecting the cluster to be marked as not ready")
+}
+
+func TestIsReady(t *testing.T) {
+	tests := []struct {
+		response    string
+		expected    bool
+		expectedErr bool
+	}{
+		{response: "true", expected: true},
+		{response: "false", expected: false},
+		{response: "", expectedErr: true},
 	}
 
-	response = ""
-	result, err = IsReady("test-cluster")
-	if err == nil {
-		t.Errorf("was expecting an error when getting status")
+	for _, tc := range tests {
+		t.Run(tc.response, func(t *testing.T) {
+			ts := newTestServer(t, tc.response)
+			c := newTestClient(t, Options{BaseURL: ts.URL})
+
+			result, err := c.IsReady(context.Background(), "test-cluster", "")
+			if tc.expectedErr != (err != nil) {
+				t.Fatalf("unexpected error - %+v", err)
+			}
+			if result != tc.expected {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tc.expected, result)
+			}
+		})
 	}
 }
 
 func TestGetKubeConfig(t *testing.T) {
-	response = "\"test\""
-	result, err := GetKubeConfig("test-cluster")
+	ts := newTestServer(t, "\"test\"")
+	c := newTestClient(t, Options{BaseURL: ts.URL})
+
+	result, err := c.GetKubeConfig(context.Background(), "test-cluster", "")
 	if err != nil {
 		t.Errorf("unexpected error when getting status - %+v", err)
 	}
 	if result != "test" {
 		t.Errorf("unexpected value returned")
 	}
+	if ts.lastRequest.URL.Path != "/test-cluster/kubeconfig" {
+		t.Errorf("unexpected result - wanted %+v, got %+v", "/test-cluster/kubeconfig", ts.lastRequest.URL.Path)
+	}
+}
+
+func TestNodeProviderQuery(t *testing.T) {
+	ts := newTestServer(t, "true")
+	c := newTestClient(t, Options{BaseURL: ts.URL})
+
+	if _, err := c.IsReady(context.Background(), "test-cluster", v1alpha4.NodeProviderPodman); err != nil {
+		t.Errorf("unexpected error when getting status - %+v", err)
+	}
+	if ts.lastRequest.URL.Query().Get("provider") != "podman" {
+		t.Errorf("was expecting the node provider to be sent to the server")
+	}
+
+	if _, err := c.IsReady(context.Background(), "test-cluster", ""); err != nil {
+		t.Errorf("unexpected error when getting status - %+v", err)
+	}
+	if ts.lastRequest.URL.RawQuery != "" {
+		t.Errorf("was not expecting a node provider to be sent to the server")
+	}
+}
+
+func TestRequestHeaders(t *testing.T) {
+	tests := []struct {
+		name                      string
+		opts                      Options
+		expectedAuth              string
+		expectedUserAgent         string
+		expectedManagementCluster string
+	}{
+		{
+			name:              "Defaults",
+			expectedUserAgent: DefaultUserAgent,
+		},
+		{
+			name:              "Token and user agent",
+			opts:              Options{Token: "secret-token", UserAgent: "test-agent"},
+			expectedAuth:      "Bearer secret-token",
+			expectedUserAgent: "test-agent",
+		},
+		{
+			name:                      "Management cluster",
+			opts:                      Options{ManagementClusterID: "mc-1"},
+			expectedUserAgent:         DefaultUserAgent,
+			expectedManagementCluster: "mc-1",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := newTestServer(t, "true")
+			tc.opts.BaseURL = ts.URL
+			c := newTestClient(t, tc.opts)
+
+			if _, err := c.IsReady(context.Background(), "test-cluster", ""); err != nil {
+				t.Errorf("unexpected error when getting status - %+v", err)
+			}
+			if auth := ts.lastRequest.Header.Get("Authorization"); auth != tc.expectedAuth {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tc.expectedAuth, auth)
+			}
+			if userAgent := ts.lastRequest.Header.Get("User-Agent"); userAgent != tc.expectedUserAgent {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tc.expectedUserAgent, userAgent)
+			}
+			if managementCluster := ts.lastRequest.Header.Get(ManagementClusterHeader); managementCluster != tc.expectedManagementCluster {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tc.expectedManagementCluster, managementCluster)
+			}
+		})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name             string
+		status           int
+		create           bool
+		expectedRequests int
+	}{
+		{name: "Server error is retried", status: http.StatusInternalServerError, expectedRequests: 3},
+		{name: "Client error is not retried", status: http.StatusNotFound, expectedRequests: 1},
+		{name: "Busy is not retried", status: http.StatusTooManyRequests, expectedRequests: 1},
+		{name: "Create is not retried", status: http.StatusInternalServerError, create: true, expectedRequests: 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := newTestServer(t, "failed")
+			ts.status = tc.status
+			c := newTestClient(t, Options{BaseURL: ts.URL, Retry: &RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}})
+
+			var err error
+			if tc.create {
+				_, err = c.CreateCluster(context.Background(), &v1alpha4.KindCluster{})
+			} else {
+				_, err = c.IsReady(context.Background(), "test-cluster", "")
+			}
+			if err == nil {
+				t.Errorf("was expecting an error")
+			}
+			if ts.requests != tc.expectedRequests {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tc.expectedRequests, ts.requests)
+			}
+		})
+	}
+}
+
+func TestContextCancelled(t *testing.T) {
+	block := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer ts.Close()
+	defer close(block)
+
+	c := newTestClient(t, Options{BaseURL: ts.URL})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := c.IsReady(ctx, "test-cluster", ""); err == nil {
+		t.Errorf("was expecting an error when the context is cancelled")
+	}
+}
+
+func TestServerBusy(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		reason     string
+		inProgress bool
+	}{
+		{name: "no capacity", status: http.StatusTooManyRequests, reason: "creation queue is full", inProgress: false},
+		{name: "in progress", status: http.StatusConflict, reason: "cluster test-cluster is already being created", inProgress: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			busyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Retry-After", "45")
+				w.WriteHeader(tt.status)
+				fmt.Fprintln(w, tt.reason)
+			}))
+			defer busyServer.Close()
+
+			c := newTestClient(t, Options{BaseURL: busyServer.URL})
+			cluster := &v1alpha4.KindCluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster"}}
+			_, err := c.CreateCluster(context.Background(), cluster)
+
+			busyErr, ok := err.(*ServerBusyError)
+			if !ok {
+				t.Fatalf("was expecting a ServerBusyError, got %+v", err)
+			}
+			expected := ServerBusyError{Reason: tt.reason, RetryAfter: 45 * time.Second, InProgress: tt.inProgress}
+			if *busyErr != expected {
+				t.Errorf("unexpected result - wanted %+v, got %+v", expected, *busyErr)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		baseURL     string
+		expectedErr bool
+	}{
+		{baseURL: "http://127.0.0.1:3000"},
+		{baseURL: "https://kind.example.com/"},
+		{baseURL: "127.0.0.1:3000", expectedErr: true},
+		{baseURL: "", expectedErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.baseURL, func(t *testing.T) {
+			_, err := New(Options{BaseURL: tc.baseURL})
+			if tc.expectedErr != (err != nil) {
+				t.Errorf("unexpected error - %+v", err)
+			}
+		})
+	}
+}
+
+func TestOptionsFromEnv(t *testing.T) {
+	os.Setenv("KIND_SERVER_ENDPOINT", "127.0.0.1")
+	os.Setenv("KIND_SERVER_PORT", "3000")
+	os.Setenv("KIND_SERVER_TOKEN", "secret-token")
+	defer os.Unsetenv("KIND_SERVER_ENDPOINT")
+	defer os.Unsetenv("KIND_SERVER_PORT")
+	defer os.Unsetenv("KIND_SERVER_TOKEN")
+
+	opts, ok := OptionsFromEnv()
+	if !ok {
+		t.Fatalf("was expecting options to be found")
+	}
+	if opts.BaseURL != "http://127.0.0.1:3000" || opts.Token != "secret-token" {
+		t.Errorf("unexpected result - %+v", opts)
+	}
+}
+
+func TestStreamLogs(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/test-cluster/logs" || r.URL.Query().Get("operation") != "op-1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "id: 0\ndata: Ensuring node image\n\nid: 1\ndata: failed to start\ndata: control-plane\n\nevent: done\ndata: \n\n")
+	}))
+	defer ts.Close()
+
+	c := newTestClient(t, Options{BaseURL: ts.URL})
+	messages := []string{}
+	err := c.StreamLogs(context.Background(), "test-cluster", "op-1", func(message string) {
+		messages = append(messages, message)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+
+	expected := []string{"Ensuring node image", "failed to start\ncontrol-plane"}
+	if strings.Join(messages, "|") != strings.Join(expected, "|") {
+		t.Errorf("unexpected result - wanted %q, got %q", expected, messages)
+	}
+
+	if err := c.StreamLogs(context.Background(), "other-cluster", "op-1", func(string) {}); err == nil {
+		t.Errorf("was expecting an error for an unknown operation")
+	}
+}
+
+func TestOperationID(t *testing.T) {
+	ts := newTestServer(t, "\"docker\"")
+	c := newTestClient(t, Options{BaseURL: ts.URL})
+
+	ctx := WithOperationID(context.Background(), "op-1")
+	if _, err := c.CreateCluster(ctx, &v1alpha4.KindCluster{}); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if operationID := ts.lastRequest.URL.Query().Get("operation"); operationID != "op-1" {
+		t.Errorf("unexpected result - wanted %+v, got %+v", "op-1", operationID)
+	}
+
+	if err := c.DeleteCluster(ctx, "test-cluster", v1alpha4.NodeProviderDocker); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	query := ts.lastRequest.URL.Query()
+	if query.Get("operation") != "op-1" || query.Get("provider") != "docker" {
+		t.Errorf("unexpected query - %+v", query)
+	}
+}
+
+func TestTracePropagation(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	ts := newTestServer(t, "true")
+	c := newTestClient(t, Options{BaseURL: ts.URL})
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "reconcile")
+	if _, err := c.IsReady(ctx, "test-cluster", ""); err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+	parent.End()
+
+	traceID := parent.SpanContext().TraceID().String()
+	if traceparent := ts.lastRequest.Header.Get("traceparent"); !strings.Contains(traceparent, traceID) {
+		t.Errorf("unexpected result - wanted traceparent for trace %+v, got %+v", traceID, traceparent)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("unexpected result - wanted %+v, got %+v", 2, len(spans))
+	}
+	requestSpan := spans[0]
+	if requestSpan.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("unexpected result - wanted %+v, got %+v", parent.SpanContext().SpanID(), requestSpan.Parent().SpanID())
+	}
+	if requestSpan.SpanKind() != trace.SpanKindClient {
+		t.Errorf("unexpected result - wanted %+v, got %+v", trace.SpanKindClient, requestSpan.SpanKind())
+	}
+}
+
+func TestListClusters(t *testing.T) {
+	ts := newTestServer(t, `[{"name":"default-test-cluster","nodeProvider":"docker","nodes":2,"labels":{"kindcluster.cluster.x-k8s.io/name":"test-cluster"}}]`)
+	c := newTestClient(t, Options{BaseURL: ts.URL})
+
+	clusters, err := c.ListClusters(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+
+	expected := []types.ClusterInfo{{
+		Name:         "default-test-cluster",
+		NodeProvider: v1alpha4.NodeProviderDocker,
+		Nodes:        2,
+		Labels:       map[string]string{v1alpha4.OwnerNameLabel: "test-cluster"},
+	}}
+	if !reflect.DeepEqual(clusters, expected) {
+		t.Errorf("unexpected result - wanted %+v, got %+v", expected, clusters)
+	}
+	if ts.lastRequest.Method != http.MethodGet || ts.lastRequest.URL.Path != "/" {
+		t.Errorf("unexpected request - %s %s", ts.lastRequest.Method, ts.lastRequest.URL.Path)
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	ts := newTestServer(t, `{"name":"default-test-snapshot","clusterName":"default-test-cluster","nodeProvider":"podman","image":"kindest/node:v1.21.1","contents":["etcd","serviceAccountKeys"]}`)
+	c := newTestClient(t, Options{BaseURL: ts.URL})
+
+	snapshot, err := c.CreateSnapshot(context.Background(), "default-test-cluster", "default-test-snapshot", v1alpha4.NodeProviderPodman)
+	if err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+
+	expected := types.SnapshotInfo{
+		Name:         "default-test-snapshot",
+		ClusterName:  "default-test-cluster",
+		NodeProvider: v1alpha4.NodeProviderPodman,
+		Image:        "kindest/node:v1.21.1",
+		Contents:     []string{types.SnapshotContentsEtcd, types.SnapshotContentsServiceAccountKeys},
+	}
+	if !reflect.DeepEqual(snapshot, expected) {
+		t.Errorf("unexpected result - wanted %+v, got %+v", expected, snapshot)
+	}
+	if ts.lastRequest.Method != http.MethodPost || ts.lastRequest.URL.Path != "/default-test-cluster/snapshots/default-test-snapshot" {
+		t.Errorf("unexpected request - %s %s", ts.lastRequest.Method, ts.lastRequest.URL.Path)
+	}
+	if provider := ts.lastRequest.URL.Query().Get("provider"); provider != "podman" {
+		t.Errorf("unexpected result - wanted %+v, got %+v", "podman", provider)
+	}
+
+	if err := c.DeleteSnapshot(context.Background(), "default-test-snapshot"); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if ts.lastRequest.Method != http.MethodDelete || ts.lastRequest.URL.Path != "/snapshots/default-test-snapshot" {
+		t.Errorf("unexpected request - %s %s", ts.lastRequest.Method, ts.lastRequest.URL.Path)
+	}
+}
+
+func TestArchiveLogs(t *testing.T) {
+	ts := newTestServer(t, `{"name":"default-test-cluster-20210701T120000Z","clusterName":"default-test-cluster","created":"2021-07-01T12:00:00Z"}`)
+	c := newTestClient(t, Options{BaseURL: ts.URL + "/kind"})
+
+	archiveURL, err := c.ArchiveLogs(context.Background(), "default-test-cluster", v1alpha4.NodeProviderDocker)
+	if err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+
+	expected := ts.URL + "/kind/log-archives/default-test-cluster-20210701T120000Z"
+	if archiveURL != expected {
+		t.Errorf("unexpected result - wanted %+v, got %+v", expected, archiveURL)
+	}
+	if ts.lastRequest.Method != http.MethodPost || ts.lastRequest.URL.Path != "/kind/default-test-cluster/export-logs" {
+		t.Errorf("unexpected request - %s %s", ts.lastRequest.Method, ts.lastRequest.URL.Path)
+	}
+}
+
+func TestEscapedPaths(t *testing.T) {
+	ts := newTestServer(t, `{}`)
+	c := newTestClient(t, Options{BaseURL: ts.URL + "/kind%20server/"})
+
+	tests := []struct {
+		name    string
+		call    func() error
+		rawPath string
+	}{
+		{
+			name:    "cluster",
+			call:    func() error { return c.DeleteCluster(context.Background(), "test cluster", "") },
+			rawPath: "/kind%20server/test%20cluster",
+		},
+		{
+			name: "snapshot",
+			call: func() error {
+				_, err := c.CreateSnapshot(context.Background(), "test cluster", "default/test%snapshot", "")
+				return err
+			},
+			rawPath: "/kind%20server/test%20cluster/snapshots/default%2Ftest%25snapshot",
+		},
+		{
+			name:    "delete snapshot",
+			call:    func() error { return c.DeleteSnapshot(context.Background(), "default/test%snapshot") },
+			rawPath: "/kind%20server/snapshots/default%2Ftest%25snapshot",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); err != nil {
+				t.Fatalf("unexpected error - %+v", err)
+			}
+			if rawPath := ts.lastRequest.URL.EscapedPath(); rawPath != tt.rawPath {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tt.rawPath, rawPath)
+			}
+		})
+	}
 }
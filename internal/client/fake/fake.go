@@ -0,0 +1,138 @@
+package fake
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+	kindClient "github.com/AverageMarcus/cluster-api-provider-kind/internal/client"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/kind"
+	kindFake "github.com/AverageMarcus/cluster-api-provider-kind/pkg/kind/fake"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/kind/types"
+)
+
+var _ kindClient.Interface = &Client{}
+
+// pollInterval is how often StreamLogs checks for the operation to finish
+const pollInterval = 10 * time.Millisecond
+
+// LogArchiveURL is the URL the log archives are returned under by ArchiveLogs
+const LogArchiveURL = "http://kind-server/log-archives/"
+
+// Client is an in-memory implementation of the Kind server client for use in tests
+type Client struct {
+	// Kind holds the clusters, and can be used to inspect them or inject errors
+	*kindFake.Kind
+	// ManagementClusterID is recorded against created clusters and limits the clusters listed, as with the Kind
+	// server, if set
+	ManagementClusterID string
+
+	mu sync.Mutex
+	// logs contains the progress messages of each finished operation
+	logs map[string][]string
+}
+
+// New creates a client backed by an empty in-memory Kind
+func New() *Client {
+	return &Client{Kind: kindFake.New(), logs: map[string][]string{}}
+}
+
+// CreateCluster records a new cluster with its owner's labels, returning the node provider used
+func (c *Client) CreateCluster(ctx context.Context, kindCluster *v1alpha4.KindCluster) (v1alpha4.NodeProvider, error) {
+	progress := []string{}
+	defer c.recordLogs(ctx, &progress)
+	return c.Kind.CreateCluster(kindCluster, kind.OwnerLabels(kindCluster, c.ManagementClusterID), func(message string) { progress = append(progress, message) })
+}
+
+// IsReady checks if the cluster has been marked as ready
+func (c *Client) IsReady(ctx context.Context, clusterName string, nodeProvider v1alpha4.NodeProvider) (bool, error) {
+	return c.Kind.IsReady(clusterName, nodeProvider)
+}
+
+// GetKubeConfig returns a KubeConfig for the cluster
+func (c *Client) GetKubeConfig(ctx context.Context, clusterName string, nodeProvider v1alpha4.NodeProvider) (string, error) {
+	return c.Kind.GetKubeConfig(clusterName, nodeProvider)
+}
+
+// DeleteCluster removes the cluster
+func (c *Client) DeleteCluster(ctx context.Context, clusterName string, nodeProvider v1alpha4.NodeProvider) error {
+	progress := []string{}
+	defer c.recordLogs(ctx, &progress)
+	return c.Kind.DeleteCluster(clusterName, nodeProvider, func(message string) { progress = append(progress, message) })
+}
+
+// ListClusters returns the clusters held by the fake, limited to those of the management cluster if set
+func (c *Client) ListClusters(ctx context.Context) ([]types.ClusterInfo, error) {
+	clusters, err := c.Kind.ListClusters()
+	if err != nil {
+		return nil, err
+	}
+	return kind.FilterByManagementCluster(clusters, c.ManagementClusterID), nil
+}
+
+// SuspendCluster marks the cluster as suspended
+func (c *Client) SuspendCluster(ctx context.Context, clusterName string, nodeProvider v1alpha4.NodeProvider) error {
+	return c.Kind.SuspendCluster(clusterName, nodeProvider)
+}
+
+// ResumeCluster marks the cluster as no longer suspended
+func (c *Client) ResumeCluster(ctx context.Context, clusterName string, nodeProvider v1alpha4.NodeProvider) error {
+	return c.Kind.ResumeCluster(clusterName, nodeProvider)
+}
+
+// CreateSnapshot records a snapshot of the cluster
+func (c *Client) CreateSnapshot(ctx context.Context, clusterName, snapshotName string, nodeProvider v1alpha4.NodeProvider) (types.SnapshotInfo, error) {
+	return c.Kind.CreateSnapshot(clusterName, snapshotName, nodeProvider)
+}
+
+// DeleteSnapshot removes the snapshot
+func (c *Client) DeleteSnapshot(ctx context.Context, snapshotName string) error {
+	return c.Kind.DeleteSnapshot(snapshotName)
+}
+
+// ArchiveLogs keeps the cluster's logs, returning the archive's URL
+func (c *Client) ArchiveLogs(ctx context.Context, clusterName string, nodeProvider v1alpha4.NodeProvider) (string, error) {
+	archive, err := c.Kind.ArchiveLogs(clusterName, nodeProvider)
+	if err != nil {
+		return "", err
+	}
+	return LogArchiveURL + archive.Name, nil
+}
+
+// StreamLogs waits for the operation to finish then calls the handler with each of its progress messages
+func (c *Client) StreamLogs(ctx context.Context, clusterName, operationID string, handler func(message string)) error {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		c.mu.Lock()
+		logs, ok := c.logs[operationID]
+		c.mu.Unlock()
+
+		if ok {
+			for _, message := range logs {
+				handler(message)
+			}
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// recordLogs stores the progress messages against the context's operation ID, if any
+func (c *Client) recordLogs(ctx context.Context, progress *[]string) {
+	operationID := kindClient.OperationID(ctx)
+	if operationID == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.logs[operationID] = *progress
+}
@@ -17,8 +17,11 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
+	"path/filepath"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -35,6 +38,9 @@ import (
 	infrastructurev1alpha4 "github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
 	"github.com/AverageMarcus/cluster-api-provider-kind/cmd/server"
 	"github.com/AverageMarcus/cluster-api-provider-kind/controllers"
+	kindClient "github.com/AverageMarcus/cluster-api-provider-kind/internal/client"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/kind"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/tracing"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -55,11 +61,50 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var nodeProvider string
+	var serverToken string
+	var serverLimits server.Limits
+	var kindVerbosity int
+	var stateDir string
+	var kubeConfigOpts kind.KubeConfigOptions
+	var orphanCollectionInterval time.Duration
+	var tracingOpts tracing.Options
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&nodeProvider, "node-provider", "",
+		"The container runtime used by the Kind server for clusters that don't request one (docker or podman). "+
+			"Auto-detected if not set.")
+	flag.StringVar(&serverToken, "server-token", os.Getenv("KIND_SERVER_TOKEN"),
+		"The bearer token the Kind server requires on all requests. Defaults to the KIND_SERVER_TOKEN environment variable.")
+	flag.IntVar(&serverLimits.MaxClusters, "max-clusters", 0,
+		"The maximum number of clusters the Kind server allows on the host. Unlimited if 0.")
+	flag.IntVar(&serverLimits.MaxNodes, "max-nodes", 0,
+		"The maximum number of nodes, across all clusters, the Kind server allows on the host. Unlimited if 0.")
+	flag.IntVar(&serverLimits.MaxConcurrentCreates, "max-concurrent-creates", 2,
+		"The maximum number of clusters the Kind server creates at the same time. Unlimited if 0.")
+	flag.IntVar(&serverLimits.MaxQueuedCreates, "max-queued-creates", 10,
+		"The maximum number of cluster creations the Kind server queues once the concurrent limit is reached.")
+	flag.IntVar(&kindVerbosity, "kind-verbosity", 0,
+		"The highest level of Kind's info messages the Kind server logs, matching Kind's -v flag. "+
+			"Messages at level n are logged at log level n so --zap-log-level may also need raising to see them.")
+	flag.StringVar(&stateDir, "state-dir", defaultStateDir(),
+		"The directory the Kind server records the owner of each cluster, and keeps cluster snapshots and log archives, in.")
+	flag.StringVar(&kubeConfigOpts.ExportDir, "kubeconfig-export-dir", "",
+		"The directory the Kind server writes the kubeconfig of each cluster to, as <cluster name>.kubeconfig. "+
+			"Kubeconfigs are only available from the Kind server if not set.")
+	flag.BoolVar(&kubeConfigOpts.MergeDefault, "merge-kubeconfig", false,
+		"Merge the kubeconfig of each cluster into the Kind server user's default kubeconfig ($KUBECONFIG or ~/.kube/config), "+
+			"as the Kind CLI does.")
+	flag.DurationVar(&orphanCollectionInterval, "orphan-collection-interval", 0,
+		"How often to delete Kind clusters whose KindCluster no longer exists, e.g. 10m. Disabled if 0.")
+	flag.StringVar((*string)(&tracingOpts.Exporter), "tracing-exporter", "",
+		"Where to send OpenTelemetry traces, either otlp or stdout. Tracing is disabled if not set.")
+	flag.StringVar(&tracingOpts.Endpoint, "tracing-endpoint", "",
+		"The URL of the OTLP/HTTP collector traces are sent to, e.g. http://localhost:4318. "+
+			"Defaults to the OTEL_EXPORTER_OTLP_ENDPOINT environment variable or https://localhost:4318.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -68,22 +113,41 @@ func main() {
 
 	if flag.Arg(0) == "server" {
 		// Run the Kind server (on the host machine)
-		if err := server.Start(); err != nil {
+		tracingOpts.ServiceName = "capk-server"
+		shutdownTracing, err := tracing.Setup(context.Background(), tracingOpts)
+		if err != nil {
 			panic(err)
 		}
-	} else {
-		// Ensure the Kind server values are set
-		_, ok := os.LookupEnv("KIND_SERVER_ENDPOINT")
-		if !ok {
-			panic("`KIND_SERVER_ENDPOINT` is required")
+		defer shutdownTracing(context.Background())
+
+		if err := server.Start(server.Options{
+			NodeProvider:  infrastructurev1alpha4.NodeProvider(nodeProvider),
+			Token:         serverToken,
+			Limits:        serverLimits,
+			KindVerbosity: kindVerbosity,
+			StateDir:      stateDir,
+			KubeConfig:    kubeConfigOpts,
+			Logger:        zap.New(zap.UseFlagOptions(&opts)),
+		}); err != nil {
+			panic(err)
 		}
-		_, ok = os.LookupEnv("KIND_SERVER_PORT")
-		if !ok {
-			panic("`KIND_SERVER_PORT` is required")
+	} else {
+		// Ensure the default Kind server values are set together, they're optional when using KindHosts
+		_, hasEndpoint := os.LookupEnv("KIND_SERVER_ENDPOINT")
+		_, hasPort := os.LookupEnv("KIND_SERVER_PORT")
+		if hasEndpoint != hasPort {
+			panic("`KIND_SERVER_ENDPOINT` and `KIND_SERVER_PORT` must be set together")
 		}
 
 		ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+		tracingOpts.ServiceName = "capk-controller"
+		shutdownTracing, err := tracing.Setup(context.Background(), tracingOpts)
+		if err != nil {
+			setupLog.Error(err, "unable to set up tracing")
+			os.Exit(1)
+		}
+
 		mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 			Scheme:                 scheme,
 			MetricsBindAddress:     metricsAddr,
@@ -97,13 +161,39 @@ func main() {
 			os.Exit(1)
 		}
 
-		if err = (&controllers.KindClusterReconciler{
-			Client: mgr.GetClient(),
-			Scheme: mgr.GetScheme(),
-		}).SetupWithManager(mgr); err != nil {
+		// The manager's cache isn't running yet so the API server is read directly
+		managementClusterID, err := controllers.ManagementClusterID(context.Background(), mgr.GetAPIReader())
+		if err != nil {
+			setupLog.Error(err, "unable to identify management cluster")
+			os.Exit(1)
+		}
+
+		reconciler := &controllers.KindClusterReconciler{
+			Client:              mgr.GetClient(),
+			Scheme:              mgr.GetScheme(),
+			Recorder:            mgr.GetEventRecorderFor("kindcluster-controller"),
+			ManagementClusterID: managementClusterID,
+		}
+		if kindServer, ok := kindClient.OptionsFromEnv(); ok {
+			reconciler.KindServer = &kindServer
+		}
+		if err = reconciler.SetupWithManager(mgr); err != nil {
 			setupLog.Error(err, "unable to create controller", "controller", "KindCluster")
 			os.Exit(1)
 		}
+		if err = (&controllers.KindClusterSnapshotReconciler{
+			Client:   mgr.GetClient(),
+			Clusters: reconciler,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "KindClusterSnapshot")
+			os.Exit(1)
+		}
+		if orphanCollectionInterval > 0 {
+			if err := mgr.Add(&controllers.OrphanCollector{Reconciler: reconciler, Interval: orphanCollectionInterval}); err != nil {
+				setupLog.Error(err, "unable to add orphan collector")
+				os.Exit(1)
+			}
+		}
 		if err = (&infrastructurev1alpha4.KindCluster{}).SetupWebhookWithManager(mgr); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "KindCluster")
 			os.Exit(1)
@@ -124,5 +214,18 @@ func main() {
 			setupLog.Error(err, "problem running manager")
 			os.Exit(1)
 		}
+		if err := shutdownTracing(context.Background()); err != nil {
+			setupLog.Error(err, "failed to flush traces")
+		}
+	}
+}
+
+// defaultStateDir returns the directory the Kind server keeps its state in by default, within the user's config
+// directory so it survives restarts
+func defaultStateDir() string {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
 	}
+	return filepath.Join(configDir, "cluster-api-provider-kind")
 }
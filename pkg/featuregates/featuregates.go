@@ -0,0 +1,178 @@
+package featuregates
+
+// known contains the feature gates recognised by the Kubernetes components
+// in the versions supported by Kind, including those that have since
+// graduated but are still accepted.
+var known = map[string]bool{
+	"APIListChunking":                                true,
+	"APIPriorityAndFairness":                         true,
+	"APIResponseCompression":                         true,
+	"AdvancedAuditing":                               true,
+	"AllAlpha":                                       true,
+	"AllBeta":                                        true,
+	"AllowInsecureBackendProxy":                      true,
+	"AnyVolumeDataSource":                            true,
+	"AppArmor":                                       true,
+	"AttachVolumeLimit":                              true,
+	"BalanceAttachedNodeVolumes":                     true,
+	"BlockVolume":                                    true,
+	"BoundServiceAccountTokenVolume":                 true,
+	"CPUCFSQuotaPeriod":                              true,
+	"CPUManager":                                     true,
+	"CRIContainerLogRotation":                        true,
+	"CSIBlockVolume":                                 true,
+	"CSIDriverRegistry":                              true,
+	"CSIInlineVolume":                                true,
+	"CSIMigration":                                   true,
+	"CSIMigrationAWS":                                true,
+	"CSIMigrationAWSComplete":                        true,
+	"CSIMigrationAzureDisk":                          true,
+	"CSIMigrationAzureDiskComplete":                  true,
+	"CSIMigrationAzureFile":                          true,
+	"CSIMigrationAzureFileComplete":                  true,
+	"CSIMigrationGCE":                                true,
+	"CSIMigrationGCEComplete":                        true,
+	"CSIMigrationOpenStack":                          true,
+	"CSIMigrationOpenStackComplete":                  true,
+	"CSIMigrationvSphere":                            true,
+	"CSIMigrationvSphereComplete":                    true,
+	"CSINodeInfo":                                    true,
+	"CSIPersistentVolume":                            true,
+	"CSIServiceAccountToken":                         true,
+	"CSIStorageCapacity":                             true,
+	"CSIVolumeFSGroupPolicy":                         true,
+	"CSIVolumeHealth":                                true,
+	"ConfigurableFSGroupPolicy":                      true,
+	"ControllerManagerLeaderMigration":               true,
+	"CronJobControllerV2":                            true,
+	"CustomCPUCFSQuotaPeriod":                        true,
+	"CustomPodDNS":                                   true,
+	"CustomResourceDefaulting":                       true,
+	"CustomResourcePublishOpenAPI":                   true,
+	"CustomResourceSubresources":                     true,
+	"CustomResourceValidation":                       true,
+	"CustomResourceWebhookConversion":                true,
+	"DaemonSetUpdateSurge":                           true,
+	"DefaultPodTopologySpread":                       true,
+	"DevicePlugins":                                  true,
+	"DisableAcceleratorUsageMetrics":                 true,
+	"DownwardAPIHugePages":                           true,
+	"DryRun":                                         true,
+	"DynamicAuditing":                                true,
+	"DynamicKubeletConfig":                           true,
+	"EfficientWatchResumption":                       true,
+	"EndpointSlice":                                  true,
+	"EndpointSliceNodeName":                          true,
+	"EndpointSliceProxying":                          true,
+	"EndpointSliceTerminatingCondition":              true,
+	"EphemeralContainers":                            true,
+	"EvenPodsSpread":                                 true,
+	"ExecProbeTimeout":                               true,
+	"ExpandCSIVolumes":                               true,
+	"ExpandInUsePersistentVolumes":                   true,
+	"ExpandPersistentVolumes":                        true,
+	"ExperimentalHostUserNamespaceDefaulting":        true,
+	"GenericEphemeralVolume":                         true,
+	"GracefulNodeShutdown":                           true,
+	"HPAContainerMetrics":                            true,
+	"HPAScaleToZero":                                 true,
+	"HugePageStorageMediumSize":                      true,
+	"HugePages":                                      true,
+	"HyperVContainer":                                true,
+	"IPv6DualStack":                                  true,
+	"ImmutableEphemeralVolumes":                      true,
+	"InTreePluginAWSUnregister":                      true,
+	"InTreePluginAzureDiskUnregister":                true,
+	"InTreePluginAzureFileUnregister":                true,
+	"InTreePluginGCEUnregister":                      true,
+	"InTreePluginOpenStackUnregister":                true,
+	"InTreePluginvSphereUnregister":                  true,
+	"IndexedJob":                                     true,
+	"KubeletCredentialProviders":                     true,
+	"KubeletPluginsWatcher":                          true,
+	"KubeletPodResources":                            true,
+	"KubeletPodResourcesGetAllocatable":              true,
+	"LegacyNodeRoleBehavior":                         true,
+	"LocalStorageCapacityIsolation":                  true,
+	"LocalStorageCapacityIsolationFSQuotaMonitoring": true,
+	"LogarithmicScaleDown":                           true,
+	"MemoryManager":                                  true,
+	"MixedProtocolLBService":                         true,
+	"MountPropagation":                               true,
+	"NamespaceDefaultLabelName":                      true,
+	"NetworkPolicyEndPort":                           true,
+	"NodeDisruptionExclusion":                        true,
+	"NodeLease":                                      true,
+	"NonPreemptingPriority":                          true,
+	"PersistentLocalVolumes":                         true,
+	"PodAffinityNamespaceSelector":                   true,
+	"PodDeletionCost":                                true,
+	"PodDisruptionBudget":                            true,
+	"PodOverhead":                                    true,
+	"PodPriority":                                    true,
+	"PodReadinessGates":                              true,
+	"PodShareProcessNamespace":                       true,
+	"PreferNominatedNode":                            true,
+	"ProbeTerminationGracePeriod":                    true,
+	"ProcMountType":                                  true,
+	"QOSReserved":                                    true,
+	"RemainingItemCount":                             true,
+	"RemoveSelfLink":                                 true,
+	"RequestManagement":                              true,
+	"ResourceLimitsPriorityFunction":                 true,
+	"ResourceQuotaScopeSelectors":                    true,
+	"RootCAConfigMap":                                true,
+	"RotateKubeletClientCertificate":                 true,
+	"RotateKubeletServerCertificate":                 true,
+	"RunAsGroup":                                     true,
+	"RuntimeClass":                                   true,
+	"SCTPSupport":                                    true,
+	"ScheduleDaemonSetPods":                          true,
+	"SelectorIndex":                                  true,
+	"ServerSideApply":                                true,
+	"ServiceAccountIssuerDiscovery":                  true,
+	"ServiceAppProtocol":                             true,
+	"ServiceInternalTrafficPolicy":                   true,
+	"ServiceLBNodePortControl":                       true,
+	"ServiceLoadBalancerClass":                       true,
+	"ServiceLoadBalancerFinalizer":                   true,
+	"ServiceNodeExclusion":                           true,
+	"ServiceTopology":                                true,
+	"SetHostnameAsFQDN":                              true,
+	"SizeMemoryBackedVolumes":                        true,
+	"StartupProbe":                                   true,
+	"StorageObjectInUseProtection":                   true,
+	"StorageVersionAPI":                              true,
+	"StorageVersionHash":                             true,
+	"StreamingProxyRedirects":                        true,
+	"SupportNodePidsLimit":                           true,
+	"SupportPodPidsLimit":                            true,
+	"SuspendJob":                                     true,
+	"Sysctls":                                        true,
+	"TTLAfterFinished":                               true,
+	"TaintBasedEvictions":                            true,
+	"TaintNodesByCondition":                          true,
+	"TokenRequest":                                   true,
+	"TokenRequestProjection":                         true,
+	"TopologyAwareHints":                             true,
+	"TopologyManager":                                true,
+	"ValidateProxyRedirects":                         true,
+	"VolumeCapacityPriority":                         true,
+	"VolumePVCDataSource":                            true,
+	"VolumeScheduling":                               true,
+	"VolumeSnapshotDataSource":                       true,
+	"VolumeSubpath":                                  true,
+	"VolumeSubpathEnvExpansion":                      true,
+	"WarningHeaders":                                 true,
+	"WatchBookmark":                                  true,
+	"WinDSR":                                         true,
+	"WinOverlay":                                     true,
+	"WindowsEndpointSliceProxying":                   true,
+	"WindowsGMSA":                                    true,
+	"WindowsRunAsUserName":                           true,
+}
+
+// IsKnown checks if the given name is a recognised Kubernetes feature gate
+func IsKnown(name string) bool {
+	return known[name]
+}
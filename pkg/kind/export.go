@@ -0,0 +1,259 @@
+package kind
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	kindcluster "github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/kind/types"
+)
+
+// Files kept in the state directory for each log archive
+const (
+	logArchiveSuffix     = ".tar.gz"
+	logArchiveInfoSuffix = ".json"
+)
+
+// logArchiveRetention is how long log archives are kept, older archives are removed when new ones are made
+const logArchiveRetention = 7 * 24 * time.Hour
+
+// ExportLogs collects the logs of the cluster's nodes, as `kind export logs` does, and writes them to w as a gzipped
+// tarball with the files under a directory named after the cluster
+func (k *Kind) ExportLogs(clusterName string, nodeProvider kindcluster.NodeProvider, w io.Writer) error {
+	provider, _, err := k.provider(nodeProvider)
+	if err != nil {
+		return err
+	}
+
+	clusterNodes, err := provider.ListNodes(clusterName)
+	if err != nil {
+		return err
+	}
+	if len(clusterNodes) == 0 {
+		return fmt.Errorf("no nodes found for cluster %q", clusterName)
+	}
+
+	dir, err := ioutil.TempDir("", "capk-logs-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := provider.CollectLogs(clusterName, dir); err != nil {
+		return fmt.Errorf("failed to collect logs: %w", err)
+	}
+	return writeTarball(w, dir, clusterName)
+}
+
+// ArchiveLogs collects the logs of the cluster's nodes into an archive in the state directory, so they're still
+// available once the cluster has been deleted
+//
+// Archives are kept for a week, those older are removed first.
+func (k *Kind) ArchiveLogs(clusterName string, nodeProvider kindcluster.NodeProvider) (types.LogArchiveInfo, error) {
+	if k.logArchives.dir == "" {
+		return types.LogArchiveInfo{}, fmt.Errorf("a state directory is required to archive logs")
+	}
+
+	created := time.Now().UTC()
+	if err := k.logArchives.prune(created.Add(-logArchiveRetention)); err != nil {
+		// The new archive can still be made
+		k.log.Error(err, "failed to remove expired log archives")
+	}
+
+	labels, err := k.owners.get(clusterName)
+	if err != nil {
+		return types.LogArchiveInfo{}, err
+	}
+
+	info := types.LogArchiveInfo{
+		Name:        fmt.Sprintf("%s-%s", clusterName, created.Format("20060102T150405Z")),
+		ClusterName: clusterName,
+		Created:     created,
+		Labels:      labels,
+	}
+
+	f, err := k.logArchives.create(info.Name)
+	if err != nil {
+		return types.LogArchiveInfo{}, err
+	}
+	err = k.ExportLogs(clusterName, nodeProvider, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = k.logArchives.save(info)
+	}
+	if err != nil {
+		if removeErr := k.logArchives.remove(info.Name); removeErr != nil {
+			k.log.Error(removeErr, "failed to remove incomplete log archive", "archive", info.Name)
+		}
+		return types.LogArchiveInfo{}, err
+	}
+	return info, nil
+}
+
+// OpenLogArchive returns the details of the log archive along with its contents, or nil if it doesn't exist
+//
+// The caller must close the contents once read.
+func (k *Kind) OpenLogArchive(archiveName string) (*types.LogArchiveInfo, io.ReadCloser, error) {
+	return k.logArchives.open(archiveName)
+}
+
+// writeTarball writes the files within dir to w as a gzipped tarball, under the given directory name
+func writeTarball(w io.Writer, dir, name string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// Only directories and regular files are collected by Kind
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(filepath.Join(name, rel))
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// logArchiveStore keeps the log archives in the state directory, each alongside a file describing it
+type logArchiveStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// create opens a new archive file for writing, failing if the archive already exists
+func (s *logArchiveStore) create(archiveName string) (*os.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(s.path(archiveName, logArchiveSuffix), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		return nil, fmt.Errorf("log archive %q already exists", archiveName)
+	}
+	return f, err
+}
+
+// save records the details of a complete archive, making it available
+func (s *logArchiveStore) save(info types.LogArchiveInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.path(info.Name, logArchiveInfoSuffix), data, 0600)
+}
+
+// open returns the details and contents of the archive, or nil if it doesn't exist or is incomplete
+func (s *logArchiveStore) open(archiveName string) (*types.LogArchiveInfo, io.ReadCloser, error) {
+	// Archive names come from requests so mustn't be able to reach outside the directory
+	if s.dir == "" || !isFileName(archiveName) {
+		return nil, nil, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := ioutil.ReadFile(s.path(archiveName, logArchiveInfoSuffix))
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+	info := &types.LogArchiveInfo{}
+	if err := json.Unmarshal(data, info); err != nil {
+		return nil, nil, err
+	}
+
+	f, err := os.Open(s.path(archiveName, logArchiveSuffix))
+	if err != nil {
+		return nil, nil, err
+	}
+	return info, f, nil
+}
+
+// remove deletes the archive and its details
+func (s *logArchiveStore) remove(archiveName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, suffix := range []string{logArchiveInfoSuffix, logArchiveSuffix} {
+		if err := os.Remove(s.path(archiveName, suffix)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// prune deletes the files of archives last written before the cutoff, including those of incomplete archives
+func (s *logArchiveStore) prune(cutoff time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := ioutil.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !entry.ModTime().Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, entry.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *logArchiveStore) path(archiveName, suffix string) string {
+	return filepath.Join(s.dir, archiveName+suffix)
+}
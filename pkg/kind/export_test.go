@@ -0,0 +1,161 @@
+package kind
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/kind/types"
+)
+
+func TestWriteTarball(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "test-cluster-control-plane"), 0700); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	files := map[string]string{
+		"kind-version.txt":                          "kind v0.11.1",
+		"test-cluster-control-plane/kubelet.log":    "kubelet started",
+		"test-cluster-control-plane/containerd.log": "containerd started",
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0600); err != nil {
+			t.Fatalf("unexpected error - %+v", err)
+		}
+	}
+
+	tarball := &bytes.Buffer{}
+	if err := writeTarball(tarball, dir, "test-cluster"); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+
+	gz, err := gzip.NewReader(tarball)
+	if err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	tr := tar.NewReader(gz)
+	actual := map[string]string{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("unexpected error - %+v", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		contents, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("unexpected error - %+v", err)
+		}
+		actual[header.Name] = string(contents)
+	}
+
+	expected := map[string]string{}
+	for name, contents := range files {
+		expected["test-cluster/"+name] = contents
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("unexpected result - wanted %+v, got %+v", expected, actual)
+	}
+}
+
+func TestLogArchiveStore(t *testing.T) {
+	store := &logArchiveStore{dir: t.TempDir()}
+	info := types.LogArchiveInfo{
+		Name:        "test-cluster-20210701T120000Z",
+		ClusterName: "test-cluster",
+		Created:     time.Date(2021, 7, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	f, err := store.create(info.Name)
+	if err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	f.WriteString("logs")
+	f.Close()
+	if _, err := store.create(info.Name); err == nil {
+		t.Errorf("was expecting an error creating a duplicate archive")
+	}
+
+	// Archives aren't available until their details are saved
+	if actual, _, err := store.open(info.Name); err != nil || actual != nil {
+		t.Errorf("unexpected result - wanted %+v, got %+v %+v", nil, actual, err)
+	}
+	if err := store.save(info); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+
+	actual, contents, err := store.open(info.Name)
+	if err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	data, _ := ioutil.ReadAll(contents)
+	contents.Close()
+	if actual == nil || !reflect.DeepEqual(*actual, info) || string(data) != "logs" {
+		t.Errorf("unexpected result - wanted %+v, got %+v %s", info, actual, data)
+	}
+
+	for _, name := range []string{"", "../" + info.Name, "..", ".hidden"} {
+		if actual, _, err := store.open(name); err != nil || actual != nil {
+			t.Errorf("was expecting no archive named %q - %+v %+v", name, actual, err)
+		}
+	}
+
+	if err := store.remove(info.Name); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if actual, _, err := store.open(info.Name); err != nil || actual != nil {
+		t.Errorf("unexpected result - wanted %+v, got %+v %+v", nil, actual, err)
+	}
+}
+
+func TestLogArchiveStorePrune(t *testing.T) {
+	store := &logArchiveStore{dir: t.TempDir()}
+	now := time.Now()
+
+	for name, modified := range map[string]time.Time{
+		"expired-cluster-20210701T120000Z": now.Add(-logArchiveRetention - time.Hour),
+		"recent-cluster-20210707T120000Z":  now.Add(-time.Hour),
+	} {
+		for _, suffix := range []string{logArchiveSuffix, logArchiveInfoSuffix} {
+			path := store.path(name, suffix)
+			if err := ioutil.WriteFile(path, []byte("{}"), 0600); err != nil {
+				t.Fatalf("unexpected error - %+v", err)
+			}
+			if err := os.Chtimes(path, modified, modified); err != nil {
+				t.Fatalf("unexpected error - %+v", err)
+			}
+		}
+	}
+
+	if err := store.prune(now.Add(-logArchiveRetention)); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+
+	entries, err := ioutil.ReadDir(store.dir)
+	if err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	remaining := []string{}
+	for _, entry := range entries {
+		remaining = append(remaining, entry.Name())
+	}
+	expected := []string{"recent-cluster-20210707T120000Z.json", "recent-cluster-20210707T120000Z.tar.gz"}
+	if !reflect.DeepEqual(remaining, expected) {
+		t.Errorf("unexpected result - wanted %+v, got %+v", expected, remaining)
+	}
+
+	// Nothing to prune before the first archive is made
+	if err := (&logArchiveStore{dir: filepath.Join(store.dir, "missing")}).prune(now); err != nil {
+		t.Errorf("unexpected error - %+v", err)
+	}
+}
@@ -0,0 +1,389 @@
+package fake
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/kind"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/kind/types"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/nodeimage"
+)
+
+var _ kind.KindProvider = &Kind{}
+
+// firstPort is the API server port given to the first cluster created
+const firstPort = 40000
+
+// Cluster is a cluster held in memory by the fake
+type Cluster struct {
+	NodeProvider v1alpha4.NodeProvider
+	Nodes        int
+	Ready        bool
+	Port         int
+	Labels       map[string]string
+	Suspended    bool
+	// Image is the resolved node image of the cluster
+	Image string
+	// RestoredFrom is the name of the snapshot the cluster was restored from, if any
+	RestoredFrom string
+}
+
+// Kind is an in-memory implementation of the Kind operations for use in tests
+//
+// The client fake in internal/client/fake wraps it for testing the controller.
+type Kind struct {
+	mu          sync.Mutex
+	clusters    map[string]*Cluster
+	snapshots   map[string]*types.SnapshotInfo
+	logArchives map[string]*logArchive
+	nextPort    int
+
+	// DefaultNodeProvider is used for clusters that don't request one, defaults to docker
+	DefaultNodeProvider v1alpha4.NodeProvider
+	// NotReady causes new clusters to be reported as not ready until SetReady is called
+	NotReady bool
+	// CreateErr, if set, is returned by CreateCluster
+	CreateErr error
+	// DeleteErr, if set, is returned by DeleteCluster
+	DeleteErr error
+	// ExportLogsErr, if set, is returned by ExportLogs and ArchiveLogs
+	ExportLogsErr error
+}
+
+// logArchive is a log archive held in memory by the fake
+type logArchive struct {
+	info     types.LogArchiveInfo
+	contents []byte
+}
+
+// New creates an empty fake
+func New() *Kind {
+	return &Kind{
+		clusters:    map[string]*Cluster{},
+		snapshots:   map[string]*types.SnapshotInfo{},
+		logArchives: map[string]*logArchive{},
+		nextPort:    firstPort,
+	}
+}
+
+// CreateMessages are the progress messages reported by CreateCluster
+var CreateMessages = []string{"Ensuring node image", "Preparing nodes", "Starting control-plane"}
+
+// DeleteMessages are the progress messages reported by DeleteCluster
+var DeleteMessages = []string{"Deleting cluster"}
+
+// CreateCluster records a new cluster with its labels, returning the node provider used
+func (k *Kind) CreateCluster(kindCluster *v1alpha4.KindCluster, labels map[string]string, progress kind.Progress) (v1alpha4.NodeProvider, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	report(progress, CreateMessages)
+
+	if k.CreateErr != nil {
+		return "", k.CreateErr
+	}
+	if _, ok := k.clusters[kindCluster.KindName()]; ok {
+		return "", fmt.Errorf("node(s) already exist for a cluster with the name %q", kindCluster.KindName())
+	}
+	image := nodeimage.Resolve(kindCluster.Spec.Image, kindCluster.Spec.Version)
+	if snapshotName := kindCluster.SnapshotName(); snapshotName != "" {
+		snapshot, ok := k.snapshots[snapshotName]
+		if !ok {
+			return "", fmt.Errorf("snapshot %q not found", snapshotName)
+		}
+		if snapshot.Image != image {
+			return "", fmt.Errorf("snapshot %q was taken of a cluster using node image %s, not %s", snapshotName, snapshot.Image, image)
+		}
+	}
+
+	nodeProvider := kindCluster.Spec.Provider
+	if nodeProvider == "" {
+		nodeProvider = k.DefaultNodeProvider
+	}
+	if nodeProvider == "" {
+		nodeProvider = v1alpha4.NodeProviderDocker
+	}
+
+	k.clusters[kindCluster.KindName()] = &Cluster{
+		NodeProvider: nodeProvider,
+		Nodes:        int(kindCluster.NodeCount()),
+		Ready:        !k.NotReady,
+		Port:         k.nextPort,
+		Labels:       labels,
+		Image:        image,
+		RestoredFrom: kindCluster.SnapshotName(),
+	}
+	k.nextPort++
+
+	return nodeProvider, nil
+}
+
+// GetKubeConfig returns a KubeConfig pointing at the cluster's (non-existent) API server on localhost
+func (k *Kind) GetKubeConfig(clusterName string, nodeProvider v1alpha4.NodeProvider) (string, error) {
+	cluster, err := k.Get(clusterName)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://127.0.0.1:%[2]d
+  name: kind-%[1]s
+contexts:
+- context:
+    cluster: kind-%[1]s
+    user: kind-%[1]s
+  name: kind-%[1]s
+current-context: kind-%[1]s
+users:
+- name: kind-%[1]s
+  user:
+    token: fake
+`, clusterName, cluster.Port), nil
+}
+
+// IsReady checks if the cluster has been marked as ready and isn't suspended
+func (k *Kind) IsReady(clusterName string, nodeProvider v1alpha4.NodeProvider) (bool, error) {
+	cluster, err := k.Get(clusterName)
+	if err != nil {
+		return false, err
+	}
+	return cluster.Ready && !cluster.Suspended, nil
+}
+
+// SuspendCluster marks the cluster as suspended
+func (k *Kind) SuspendCluster(clusterName string, nodeProvider v1alpha4.NodeProvider) error {
+	return k.setSuspended(clusterName, true)
+}
+
+// ResumeCluster marks the cluster as no longer suspended
+func (k *Kind) ResumeCluster(clusterName string, nodeProvider v1alpha4.NodeProvider) error {
+	return k.setSuspended(clusterName, false)
+}
+
+func (k *Kind) setSuspended(clusterName string, suspended bool) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	cluster, ok := k.clusters[clusterName]
+	if !ok {
+		return fmt.Errorf("no nodes found for cluster %q", clusterName)
+	}
+	cluster.Suspended = suspended
+	return nil
+}
+
+// CreateSnapshot records a snapshot of the cluster
+func (k *Kind) CreateSnapshot(clusterName, snapshotName string, nodeProvider v1alpha4.NodeProvider) (types.SnapshotInfo, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	cluster, ok := k.clusters[clusterName]
+	if !ok {
+		return types.SnapshotInfo{}, fmt.Errorf("no nodes found for cluster %q", clusterName)
+	}
+	if _, ok := k.snapshots[snapshotName]; ok {
+		return types.SnapshotInfo{}, fmt.Errorf("snapshot %q already exists", snapshotName)
+	}
+
+	info := types.SnapshotInfo{
+		Name:         snapshotName,
+		ClusterName:  clusterName,
+		NodeProvider: cluster.NodeProvider,
+		Image:        cluster.Image,
+		Labels:       cluster.Labels,
+		Contents:     []string{types.SnapshotContentsEtcd, types.SnapshotContentsServiceAccountKeys},
+	}
+	k.snapshots[snapshotName] = &info
+	return info, nil
+}
+
+// GetSnapshot returns a copy of the named snapshot, or nil if it doesn't exist
+func (k *Kind) GetSnapshot(snapshotName string) (*types.SnapshotInfo, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	info, ok := k.snapshots[snapshotName]
+	if !ok {
+		return nil, nil
+	}
+	snapshot := *info
+	return &snapshot, nil
+}
+
+// DeleteSnapshot removes the snapshot, succeeding if it doesn't exist
+func (k *Kind) DeleteSnapshot(snapshotName string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	delete(k.snapshots, snapshotName)
+	return nil
+}
+
+// ExportLogs writes the cluster's logs, as returned by Logs, to w
+func (k *Kind) ExportLogs(clusterName string, nodeProvider v1alpha4.NodeProvider, w io.Writer) error {
+	logs, err := k.exportLogs(clusterName)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(logs)
+	return err
+}
+
+// ArchiveLogs keeps the cluster's logs, as returned by Logs, in an archive named after the cluster and the number of
+// archives already kept
+func (k *Kind) ArchiveLogs(clusterName string, nodeProvider v1alpha4.NodeProvider) (types.LogArchiveInfo, error) {
+	logs, err := k.exportLogs(clusterName)
+	if err != nil {
+		return types.LogArchiveInfo{}, err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	info := types.LogArchiveInfo{
+		Name:        fmt.Sprintf("%s-%d", clusterName, len(k.logArchives)),
+		ClusterName: clusterName,
+		Created:     time.Now(),
+	}
+	if cluster, ok := k.clusters[clusterName]; ok {
+		info.Labels = cluster.Labels
+	}
+	k.logArchives[info.Name] = &logArchive{info: info, contents: logs}
+	return info, nil
+}
+
+// OpenLogArchive returns the details and contents of the log archive, or nil if it doesn't exist
+func (k *Kind) OpenLogArchive(archiveName string) (*types.LogArchiveInfo, io.ReadCloser, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	archive, ok := k.logArchives[archiveName]
+	if !ok {
+		return nil, nil, nil
+	}
+	info := archive.info
+	return &info, ioutil.NopCloser(bytes.NewReader(archive.contents)), nil
+}
+
+// Logs returns the logs exported for the named cluster
+func Logs(clusterName string) []byte {
+	return []byte(fmt.Sprintf("logs of %s", clusterName))
+}
+
+// exportLogs returns the logs of the cluster, failing if it doesn't exist as Kind does
+func (k *Kind) exportLogs(clusterName string) ([]byte, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.ExportLogsErr != nil {
+		return nil, k.ExportLogsErr
+	}
+	if _, ok := k.clusters[clusterName]; !ok {
+		return nil, fmt.Errorf("no nodes found for cluster %q", clusterName)
+	}
+	return Logs(clusterName), nil
+}
+
+// DeleteCluster removes the cluster, succeeding if it doesn't exist as Kind does
+func (k *Kind) DeleteCluster(clusterName string, nodeProvider v1alpha4.NodeProvider, progress kind.Progress) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	report(progress, DeleteMessages)
+
+	if k.DeleteErr != nil {
+		return k.DeleteErr
+	}
+	delete(k.clusters, clusterName)
+	return nil
+}
+
+// ClusterNodeCounts returns the number of nodes in each cluster
+func (k *Kind) ClusterNodeCounts() (map[string]int, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	counts := map[string]int{}
+	for name, cluster := range k.clusters {
+		counts[name] = cluster.Nodes
+	}
+	return counts, nil
+}
+
+// ListClusters returns the clusters held by the fake, sorted by name
+func (k *Kind) ListClusters() ([]types.ClusterInfo, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	clusters := []types.ClusterInfo{}
+	for name, cluster := range k.clusters {
+		clusters = append(clusters, types.ClusterInfo{
+			Name:         name,
+			NodeProvider: cluster.NodeProvider,
+			Nodes:        cluster.Nodes,
+			Labels:       cluster.Labels,
+		})
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].Name < clusters[j].Name
+	})
+	return clusters, nil
+}
+
+// ClusterLabels returns the labels the cluster was created with, or nil if it doesn't exist
+func (k *Kind) ClusterLabels(clusterName string) (map[string]string, error) {
+	cluster, err := k.Get(clusterName)
+	if err != nil {
+		return nil, nil
+	}
+	return cluster.Labels, nil
+}
+
+// Add adds a cluster directly, as if created outside of the Kind server or by another management cluster
+func (k *Kind) Add(clusterName string, cluster Cluster) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.clusters[clusterName] = &cluster
+}
+
+// Get returns a copy of the named cluster
+func (k *Kind) Get(clusterName string) (Cluster, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	cluster, ok := k.clusters[clusterName]
+	if !ok {
+		return Cluster{}, fmt.Errorf("unknown cluster %q", clusterName)
+	}
+	return *cluster, nil
+}
+
+// SetReady marks the named cluster as ready or not
+func (k *Kind) SetReady(clusterName string, ready bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if cluster, ok := k.clusters[clusterName]; ok {
+		cluster.Ready = ready
+	}
+}
+
+// report sends the messages to the progress func, if set
+func report(progress kind.Progress, messages []string) {
+	if progress == nil {
+		return
+	}
+	for _, message := range messages {
+		progress(message)
+	}
+}
@@ -2,51 +2,202 @@ package kind
 
 import (
 	"fmt"
-	"os"
-	"path"
-	"time"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 
 	kindcluster "github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/kind/types"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/kindconfig"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/nodeimage"
 	"github.com/go-logr/logr"
+	"gopkg.in/yaml.v2"
 	"sigs.k8s.io/kind/pkg/apis/config/v1alpha4"
 	"sigs.k8s.io/kind/pkg/cluster"
 )
 
-const createWaitTime = 60 * time.Second
+// KindProvider contains the operations for managing Kind clusters
+type KindProvider interface {
+	// CreateCluster creates a new cluster in Kind, recording the labels identifying its owner, returning the node
+	// provider used and reporting Kind's progress messages to the progress func, if set
+	CreateCluster(kindCluster *kindcluster.KindCluster, labels map[string]string, progress Progress) (kindcluster.NodeProvider, error)
+	// GetKubeConfig returns the KubeConfig for the cluster in Kind matching the given name
+	GetKubeConfig(clusterName string, nodeProvider kindcluster.NodeProvider) (string, error)
+	// IsReady checks if the cluster is ready in Kind
+	IsReady(clusterName string, nodeProvider kindcluster.NodeProvider) (bool, error)
+	// DeleteCluster removes the cluster from Kind, reporting Kind's progress messages to the progress func, if set
+	DeleteCluster(clusterName string, nodeProvider kindcluster.NodeProvider, progress Progress) error
+	// ClusterNodeCounts returns the number of nodes in each cluster managed by Kind
+	ClusterNodeCounts() (map[string]int, error)
+	// ListClusters returns the clusters managed by Kind along with the labels identifying their owners
+	ListClusters() ([]types.ClusterInfo, error)
+	// ClusterLabels returns the labels identifying the owner of the cluster, or nil if none were recorded
+	ClusterLabels(clusterName string) (map[string]string, error)
+	// SuspendCluster stops the node containers of the cluster, keeping them so the cluster can be resumed
+	SuspendCluster(clusterName string, nodeProvider kindcluster.NodeProvider) error
+	// ResumeCluster restarts the node containers of a suspended cluster, waiting for its API server to be ready
+	ResumeCluster(clusterName string, nodeProvider kindcluster.NodeProvider) error
+	// CreateSnapshot keeps a copy of the cluster's etcd data and service account keys, so new clusters can be restored
+	// from it
+	CreateSnapshot(clusterName, snapshotName string, nodeProvider kindcluster.NodeProvider) (types.SnapshotInfo, error)
+	// GetSnapshot returns the snapshot with the given name, or nil if it doesn't exist
+	GetSnapshot(snapshotName string) (*types.SnapshotInfo, error)
+	// DeleteSnapshot removes the snapshot's files, succeeding if it doesn't exist
+	DeleteSnapshot(snapshotName string) error
+	// ExportLogs collects the logs of the cluster's nodes and writes them to w as a gzipped tarball
+	ExportLogs(clusterName string, nodeProvider kindcluster.NodeProvider, w io.Writer) error
+	// ArchiveLogs collects the logs of the cluster's nodes into an archive kept by the server
+	ArchiveLogs(clusterName string, nodeProvider kindcluster.NodeProvider) (types.LogArchiveInfo, error)
+	// OpenLogArchive returns the details and contents of the log archive, or nil if it doesn't exist
+	OpenLogArchive(archiveName string) (*types.LogArchiveInfo, io.ReadCloser, error)
+}
+
+var _ KindProvider = &Kind{}
 
 // Kind provides function for interacting with Kind clusters
 type Kind struct {
-	provider *cluster.Provider
+	log                 logr.Logger
+	defaultNodeProvider kindcluster.NodeProvider
+	verbosity           int
+
+	providersMu sync.Mutex
+	providers   map[kindcluster.NodeProvider]*cluster.Provider
+
+	owners      *ownerStore
+	snapshots   *snapshotStore
+	logArchives *logArchiveStore
+	kubeConfigs KubeConfigOptions
 }
 
 // New create a new instance of Kind
-func New(log logr.Logger) *Kind {
+//
+// The default node provider is used for clusters that don't request one, if empty the
+// node provider is auto-detected. The verbosity is the highest level of Kind's info messages
+// that are logged, matching the `-v` flag of the Kind CLI. The labels identifying the owner of
+// each cluster, cluster snapshots and log archives are kept in the state directory. Owners aren't
+// kept, and snapshots and log archives can't be made, if it's empty. The kubeconfig options control
+// where the kubeconfig of each cluster is written on the host, if anywhere.
+func New(log logr.Logger, defaultNodeProvider kindcluster.NodeProvider, verbosity int, stateDir string, kubeConfigs KubeConfigOptions) *Kind {
+	snapshotDir, logArchiveDir := "", ""
+	if stateDir != "" {
+		snapshotDir = filepath.Join(stateDir, "snapshots")
+		logArchiveDir = filepath.Join(stateDir, "logs")
+	}
 	return &Kind{
-		provider: cluster.NewProvider(cluster.ProviderWithLogger(kindLogger{log})),
+		log:                 log,
+		defaultNodeProvider: defaultNodeProvider,
+		verbosity:           verbosity,
+		providers:           map[kindcluster.NodeProvider]*cluster.Provider{},
+		owners:              &ownerStore{dir: stateDir},
+		snapshots:           &snapshotStore{dir: snapshotDir},
+		logArchives:         &logArchiveStore{dir: logArchiveDir},
+		kubeConfigs:         kubeConfigs,
 	}
 }
 
-// CreateCluster creates a new cluster in Kind
-func (k *Kind) CreateCluster(kindCluster *kindcluster.KindCluster) error {
-	return k.provider.Create(
-		kindCluster.NamespacedName(),
-		cluster.CreateWithV1Alpha4Config(kindClusterToKindConfig(kindCluster)),
-		cluster.CreateWithWaitForReady(createWaitTime),
-		cluster.CreateWithKubeconfigPath(path.Join(os.TempDir(), "kubeconfig")),
-		cluster.CreateWithRetain(false),
-		cluster.CreateWithDisplayUsage(false),
-		cluster.CreateWithDisplaySalutation(false),
-	)
+// CreateCluster creates a new cluster in Kind, recording the labels identifying its owner, returning the node
+// provider used
+//
+// Kind has no way of adding labels to the node containers so the labels are recorded in the state directory.
+// Clusters referencing a snapshot have its state restored once created, and are removed again if that fails.
+func (k *Kind) CreateCluster(kindCluster *kindcluster.KindCluster, labels map[string]string, progress Progress) (kindcluster.NodeProvider, error) {
+	provider, nodeProvider, err := k.operationProvider(kindCluster.Spec.Provider, progress)
+	if err != nil {
+		return "", err
+	}
+
+	config, err := kindClusterToKindConfig(kindCluster)
+	if err != nil {
+		return "", err
+	}
+
+	var snapshot *types.SnapshotInfo
+	if snapshotName := kindCluster.SnapshotName(); snapshotName != "" {
+		if snapshot, err = k.snapshots.get(snapshotName); err != nil {
+			return "", err
+		} else if snapshot == nil {
+			return "", fmt.Errorf("snapshot %q not found", snapshotName)
+		}
+		if err := checkRestorable(config, snapshot); err != nil {
+			return "", err
+		}
+	}
+
+	// Checked here, as well as by Kind, so the owner of an existing cluster isn't replaced
+	existing, err := provider.List()
+	if err != nil {
+		return "", err
+	}
+	for _, existingCluster := range existing {
+		if existingCluster == kindCluster.KindName() {
+			return "", fmt.Errorf("node(s) already exist for a cluster with the name %q", kindCluster.KindName())
+		}
+	}
+
+	// The owner is recorded first so the cluster can always be traced back to its KindCluster
+	if err := k.owners.set(kindCluster.KindName(), labels); err != nil {
+		return "", fmt.Errorf("failed to record owner of cluster: %w", err)
+	}
+
+	// Kind always writes the kubeconfig somewhere, it's only kept if requested once the cluster is ready
+	err = withScratchKubeConfig(func(kubeconfigPath string) error {
+		return provider.Create(
+			kindCluster.KindName(),
+			cluster.CreateWithV1Alpha4Config(config),
+			cluster.CreateWithWaitForReady(kindCluster.WaitForReady()),
+			cluster.CreateWithKubeconfigPath(kubeconfigPath),
+			cluster.CreateWithRetain(false),
+			cluster.CreateWithDisplayUsage(false),
+			cluster.CreateWithDisplaySalutation(false),
+		)
+	})
+	if err != nil {
+		// Kind removes the nodes of clusters that fail to create
+		if removeErr := k.owners.remove(kindCluster.KindName()); removeErr != nil {
+			k.log.Error(removeErr, "failed to remove owner of cluster", "cluster", kindCluster.KindName())
+		}
+		return "", err
+	}
+
+	if snapshot != nil {
+		if progress != nil {
+			progress(fmt.Sprintf("Restoring snapshot %s", snapshot.Name))
+		}
+		if err := k.restoreSnapshot(provider, kindCluster.KindName(), snapshot); err != nil {
+			if deleteErr := k.DeleteCluster(kindCluster.KindName(), nodeProvider, nil); deleteErr != nil {
+				k.log.Error(deleteErr, "failed to remove cluster that couldn't be restored", "cluster", kindCluster.KindName())
+			}
+			return "", fmt.Errorf("failed to restore snapshot %q: %w", snapshot.Name, err)
+		}
+	}
+
+	k.exportKubeConfigs(provider, kindCluster.KindName())
+	return nodeProvider, nil
+}
+
+// ClusterLabels returns the labels identifying the owner of the cluster, or nil if none were recorded
+func (k *Kind) ClusterLabels(clusterName string) (map[string]string, error) {
+	return k.owners.get(clusterName)
 }
 
 // GetKubeConfig returns the KubeConfig for the cluster in Kind matching the given name
-func (k *Kind) GetKubeConfig(clusterName string) (string, error) {
-	return k.provider.KubeConfig(clusterName, false)
+func (k *Kind) GetKubeConfig(clusterName string, nodeProvider kindcluster.NodeProvider) (string, error) {
+	provider, _, err := k.provider(nodeProvider)
+	if err != nil {
+		return "", err
+	}
+	return provider.KubeConfig(clusterName, false)
 }
 
 // IsReady checks if the cluster is ready in Kind
-func (k *Kind) IsReady(clusterName string) (bool, error) {
-	readyClusters, err := k.provider.List()
+func (k *Kind) IsReady(clusterName string, nodeProvider kindcluster.NodeProvider) (bool, error) {
+	provider, _, err := k.provider(nodeProvider)
+	if err != nil {
+		return false, err
+	}
+	readyClusters, err := provider.List()
 	if err != nil {
 		return false, err
 	}
@@ -59,48 +210,137 @@ func (k *Kind) IsReady(clusterName string) (bool, error) {
 }
 
 // DeleteCluster removes the cluster from Kind
-func (k *Kind) DeleteCluster(clusterName string) error {
-	return k.provider.Delete(clusterName, path.Join(os.TempDir(), "kubeconfig"))
+func (k *Kind) DeleteCluster(clusterName string, nodeProvider kindcluster.NodeProvider, progress Progress) error {
+	provider, _, err := k.operationProvider(nodeProvider, progress)
+	if err != nil {
+		return err
+	}
+
+	deleteCluster := func(kubeconfigPath string) error {
+		return provider.Delete(clusterName, kubeconfigPath)
+	}
+	if k.kubeConfigs.MergeDefault {
+		// Kind removes the cluster from the user's default kubeconfig
+		err = deleteCluster("")
+	} else {
+		err = withScratchKubeConfig(deleteCluster)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := k.removeExportedKubeConfig(clusterName); err != nil {
+		k.log.Error(err, "failed to remove exported kubeconfig", "cluster", clusterName)
+	}
+	return k.owners.remove(clusterName)
 }
 
-func kindClusterToKindConfig(kindCluster *kindcluster.KindCluster) *v1alpha4.Cluster {
-	replicas := 1
-	featureGates := map[string]bool{}
-	runtimeConfig := map[string]string{}
-	image := "kindest/node"
-	version := "v1.21.2"
+func kindClusterToKindConfig(kindCluster *kindcluster.KindCluster) (*v1alpha4.Cluster, error) {
+	image := nodeimage.Resolve(kindCluster.Spec.Image, kindCluster.Spec.Version)
 
-	if kindCluster.Spec.Replicas > 0 {
-		replicas = int(kindCluster.Spec.Replicas)
+	nodes := []v1alpha4.Node{}
+	for _, node := range kindCluster.KindNodes() {
+		patches, err := kubeadmConfigPatches(node)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := 0; i < int(node.Count); i++ {
+			nodes = append(nodes, v1alpha4.Node{
+				Role:                 v1alpha4.NodeRole(node.Role),
+				Image:                image,
+				KubeadmConfigPatches: patches,
+			})
+		}
 	}
 
-	if kindCluster.Spec.FeatureGates != nil {
-		featureGates = kindCluster.Spec.FeatureGates
+	config := &v1alpha4.Cluster{
+		FeatureGates:  kindCluster.Spec.FeatureGates,
+		RuntimeConfig: kindCluster.Spec.RuntimeConfig,
+		Nodes:         nodes,
+		Networking: v1alpha4.Networking{
+			IPFamily:          v1alpha4.ClusterIPFamily(kindCluster.Spec.Networking.IPFamily),
+			PodSubnet:         kindCluster.Spec.Networking.PodSubnet,
+			ServiceSubnet:     kindCluster.Spec.Networking.ServiceSubnet,
+			DisableDefaultCNI: kindCluster.Spec.Networking.DisableDefaultCNI,
+		},
 	}
 
-	if kindCluster.Spec.RuntimeConfig != nil {
-		runtimeConfig = kindCluster.Spec.RuntimeConfig
+	if kindCluster.Spec.KindConfig != nil {
+		// ConfigMap references are resolved to inline config by the controller before sending
+		if kindCluster.Spec.KindConfig.Inline == "" {
+			return nil, fmt.Errorf("raw Kind config has not been resolved")
+		}
+
+		rawConfig, err := kindconfig.Parse(kindCluster.Spec.KindConfig.Inline)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := kindconfig.Merge(config, rawConfig); err != nil {
+			return nil, err
+		}
 	}
 
-	if kindCluster.Spec.Image != "" {
-		image = kindCluster.Spec.Image
+	return config, nil
+}
+
+type kubeadmConfigPatch struct {
+	Kind             string           `yaml:"kind"`
+	NodeRegistration nodeRegistration `yaml:"nodeRegistration"`
+}
+
+type nodeRegistration struct {
+	KubeletExtraArgs map[string]string `yaml:"kubeletExtraArgs"`
+}
+
+// kubeadmConfigPatches builds the kubeadm patches that apply the node labels, taints and kubelet args
+func kubeadmConfigPatches(node kindcluster.KindNode) ([]string, error) {
+	kubeletExtraArgs := map[string]string{}
+	for arg, value := range node.KubeletExtraArgs {
+		kubeletExtraArgs[arg] = value
 	}
 
-	if kindCluster.Spec.Version != "" {
-		version = kindCluster.Spec.Version
+	if len(node.Labels) > 0 {
+		labels := []string{}
+		for key, value := range node.Labels {
+			labels = append(labels, fmt.Sprintf("%s=%s", key, value))
+		}
+		sort.Strings(labels)
+		kubeletExtraArgs["node-labels"] = strings.Join(labels, ",")
 	}
 
-	nodes := []v1alpha4.Node{}
-	for i := 0; i < replicas; i++ {
-		nodes = append(nodes, v1alpha4.Node{
-			Role:  v1alpha4.ControlPlaneRole,
-			Image: fmt.Sprintf("%s:%s", image, version),
-		})
+	if len(node.Taints) > 0 {
+		taints := []string{}
+		for _, taint := range node.Taints {
+			taints = append(taints, taint.ToString())
+		}
+		kubeletExtraArgs["register-with-taints"] = strings.Join(taints, ",")
 	}
 
-	return &v1alpha4.Cluster{
-		FeatureGates:  featureGates,
-		RuntimeConfig: runtimeConfig,
-		Nodes:         nodes,
+	if len(kubeletExtraArgs) == 0 {
+		return nil, nil
 	}
+
+	// The first control plane node is configured with `kubeadm init`, all others join the cluster
+	kinds := []string{"JoinConfiguration"}
+	if node.Role == kindcluster.NodeRoleControlPlane {
+		kinds = []string{"InitConfiguration", "JoinConfiguration"}
+	}
+
+	patches := []string{}
+	for _, kind := range kinds {
+		patch, err := yaml.Marshal(kubeadmConfigPatch{
+			Kind: kind,
+			NodeRegistration: nodeRegistration{
+				KubeletExtraArgs: kubeletExtraArgs,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		patches = append(patches, string(patch))
+	}
+
+	return patches, nil
 }
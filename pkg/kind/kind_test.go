@@ -0,0 +1,197 @@
+package kind
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/kind/pkg/apis/config/v1alpha4"
+
+	kindcluster "github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+)
+
+func TestKindClusterToKindConfig(t *testing.T) {
+	image := "kindest/node:v1.21.1@sha256:69860bda5563ac81e3c0057d654b5253219618a22ec3a346306239bba8cfa1a6"
+
+	tests := []struct {
+		name     string
+		replicas int32
+		nodes    []kindcluster.KindNode
+		want     []v1alpha4.Node
+	}{
+		{
+			name:     "replicas without nodes",
+			replicas: 3,
+			want: []v1alpha4.Node{
+				{Role: v1alpha4.ControlPlaneRole, Image: image},
+				{Role: v1alpha4.ControlPlaneRole, Image: image},
+				{Role: v1alpha4.ControlPlaneRole, Image: image},
+			},
+		},
+		{
+			name: "no replicas or nodes",
+			want: []v1alpha4.Node{
+				{Role: v1alpha4.ControlPlaneRole, Image: image},
+			},
+		},
+		{
+			name: "control plane only",
+			nodes: []kindcluster.KindNode{
+				{Role: kindcluster.NodeRoleControlPlane, Count: 3},
+			},
+			want: []v1alpha4.Node{
+				{Role: v1alpha4.ControlPlaneRole, Image: image},
+				{Role: v1alpha4.ControlPlaneRole, Image: image},
+				{Role: v1alpha4.ControlPlaneRole, Image: image},
+			},
+		},
+		{
+			name: "customised workers",
+			nodes: []kindcluster.KindNode{
+				{Role: kindcluster.NodeRoleControlPlane, Count: 1},
+				{
+					Role:  kindcluster.NodeRoleWorker,
+					Count: 2,
+					Labels: map[string]string{
+						"example.com/role": "ingress",
+						"example.com/zone": "a",
+					},
+					Taints: []corev1.Taint{
+						{Key: "example.com/dedicated", Value: "ingress", Effect: corev1.TaintEffectNoSchedule},
+					},
+					KubeletExtraArgs: map[string]string{
+						"max-pods": "50",
+					},
+				},
+			},
+			want: []v1alpha4.Node{
+				{Role: v1alpha4.ControlPlaneRole, Image: image},
+				{
+					Role:  v1alpha4.WorkerRole,
+					Image: image,
+					KubeadmConfigPatches: []string{`kind: JoinConfiguration
+nodeRegistration:
+  kubeletExtraArgs:
+    max-pods: "50"
+    node-labels: example.com/role=ingress,example.com/zone=a
+    register-with-taints: example.com/dedicated=ingress:NoSchedule
+`},
+				},
+				{
+					Role:  v1alpha4.WorkerRole,
+					Image: image,
+					KubeadmConfigPatches: []string{`kind: JoinConfiguration
+nodeRegistration:
+  kubeletExtraArgs:
+    max-pods: "50"
+    node-labels: example.com/role=ingress,example.com/zone=a
+    register-with-taints: example.com/dedicated=ingress:NoSchedule
+`},
+				},
+			},
+		},
+		{
+			name: "labelled control plane",
+			nodes: []kindcluster.KindNode{
+				{
+					Role:   kindcluster.NodeRoleControlPlane,
+					Count:  1,
+					Labels: map[string]string{"ingress-ready": "true"},
+				},
+			},
+			want: []v1alpha4.Node{
+				{
+					Role:  v1alpha4.ControlPlaneRole,
+					Image: image,
+					KubeadmConfigPatches: []string{`kind: InitConfiguration
+nodeRegistration:
+  kubeletExtraArgs:
+    node-labels: ingress-ready=true
+`, `kind: JoinConfiguration
+nodeRegistration:
+  kubeletExtraArgs:
+    node-labels: ingress-ready=true
+`},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kindCluster := &kindcluster.KindCluster{
+				Spec: kindcluster.KindClusterSpec{
+					Image:    "kindest/node",
+					Version:  "v1.21.1",
+					Replicas: tt.replicas,
+					Nodes:    tt.nodes,
+				},
+			}
+
+			config, err := kindClusterToKindConfig(kindCluster)
+			if err != nil {
+				t.Fatalf("unexpected error - %+v", err)
+			}
+			if !reflect.DeepEqual(config.Nodes, tt.want) {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tt.want, config.Nodes)
+			}
+		})
+	}
+}
+
+func TestKindClusterToKindConfigNetworking(t *testing.T) {
+	tests := []struct {
+		name       string
+		networking kindcluster.KindClusterNetworking
+		want       v1alpha4.Networking
+	}{
+		{
+			name: "kind defaults",
+			want: v1alpha4.Networking{},
+		},
+		{
+			name: "ipv6",
+			networking: kindcluster.KindClusterNetworking{
+				IPFamily:      kindcluster.IPFamilyIPv6,
+				PodSubnet:     "fd00:10:244::/56",
+				ServiceSubnet: "fd00:10:96::/112",
+			},
+			want: v1alpha4.Networking{
+				IPFamily:      v1alpha4.IPv6Family,
+				PodSubnet:     "fd00:10:244::/56",
+				ServiceSubnet: "fd00:10:96::/112",
+			},
+		},
+		{
+			name: "dual-stack",
+			networking: kindcluster.KindClusterNetworking{
+				IPFamily:      kindcluster.IPFamilyDualStack,
+				PodSubnet:     "10.244.0.0/16,fd00:10:244::/56",
+				ServiceSubnet: "10.96.0.0/12,fd00:10:96::/112",
+			},
+			want: v1alpha4.Networking{
+				IPFamily:      v1alpha4.DualStackFamily,
+				PodSubnet:     "10.244.0.0/16,fd00:10:244::/56",
+				ServiceSubnet: "10.96.0.0/12,fd00:10:96::/112",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kindCluster := &kindcluster.KindCluster{
+				Spec: kindcluster.KindClusterSpec{
+					Image:      "kindest/node",
+					Version:    "v1.21.1",
+					Networking: tt.networking,
+				},
+			}
+
+			config, err := kindClusterToKindConfig(kindCluster)
+			if err != nil {
+				t.Fatalf("unexpected error - %+v", err)
+			}
+			if !reflect.DeepEqual(config.Networking, tt.want) {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tt.want, config.Networking)
+			}
+		})
+	}
+}
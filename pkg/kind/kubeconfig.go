@@ -0,0 +1,96 @@
+package kind
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"sigs.k8s.io/kind/pkg/cluster"
+)
+
+// kubeConfigSuffix is the extension of the kubeconfig files written to the export directory
+const kubeConfigSuffix = ".kubeconfig"
+
+// kubeConfigLockBackoff is how long to wait before each attempt to export a kubeconfig, as Kind fails rather than
+// waits when another operation has the file locked
+var kubeConfigLockBackoff = []time.Duration{0, 10 * time.Millisecond, 50 * time.Millisecond, 100 * time.Millisecond}
+
+// exportKubeConfig merges the cluster's external kubeconfig into the file at path, or into the user's default
+// kubeconfig if path is empty
+var exportKubeConfig = func(provider *cluster.Provider, clusterName, path string) error {
+	return provider.ExportKubeConfig(clusterName, path)
+}
+
+// KubeConfigOptions controls where the kubeconfig of each cluster is written on the host
+//
+// The kubeconfigs are always available from the server, they're only written to the host if requested.
+type KubeConfigOptions struct {
+	// ExportDir is the directory each cluster's kubeconfig is written to, as <cluster name>.kubeconfig, if set
+	ExportDir string
+	// MergeDefault merges each cluster's kubeconfig into the host user's default kubeconfig ($KUBECONFIG or
+	// ~/.kube/config), as the Kind CLI does
+	MergeDefault bool
+}
+
+// paths returns the files the cluster's kubeconfig is exported to, an empty path being the user's default kubeconfig
+func (o KubeConfigOptions) paths(clusterName string) []string {
+	paths := []string{}
+	if o.ExportDir != "" {
+		paths = append(paths, o.exportPath(clusterName))
+	}
+	if o.MergeDefault {
+		paths = append(paths, "")
+	}
+	return paths
+}
+
+// exportPath returns the file in the export directory holding the cluster's kubeconfig
+func (o KubeConfigOptions) exportPath(clusterName string) string {
+	return filepath.Join(o.ExportDir, clusterName+kubeConfigSuffix)
+}
+
+// exportKubeConfigs writes the cluster's kubeconfig to each of the requested files
+//
+// Failures are logged rather than returned as the cluster has been created and its kubeconfig can still be fetched
+// from the server.
+func (k *Kind) exportKubeConfigs(provider *cluster.Provider, clusterName string) {
+	for _, path := range k.kubeConfigs.paths(clusterName) {
+		var err error
+		for _, backoff := range kubeConfigLockBackoff {
+			time.Sleep(backoff)
+			if err = exportKubeConfig(provider, clusterName, path); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			k.log.Error(err, "failed to export kubeconfig", "cluster", clusterName, "path", path)
+		}
+	}
+}
+
+// removeExportedKubeConfig removes the cluster's kubeconfig from the export directory, succeeding if it isn't there
+//
+// Kind removes the cluster from the user's default kubeconfig itself when deleting it.
+func (k *Kind) removeExportedKubeConfig(clusterName string) error {
+	if k.kubeConfigs.ExportDir == "" {
+		return nil
+	}
+	if err := os.Remove(k.kubeConfigs.exportPath(clusterName)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// withScratchKubeConfig calls f with the path of a kubeconfig file in a new temporary directory, removed once f
+// returns, for the Kind operations that always write to a kubeconfig
+//
+// Each operation has its own file so concurrent operations don't contend for it, and credentials aren't left behind.
+func withScratchKubeConfig(f func(path string) error) error {
+	dir, err := ioutil.TempDir("", "capk-kubeconfig-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	return f(filepath.Join(dir, "kubeconfig"))
+}
@@ -0,0 +1,116 @@
+package kind
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/kind/pkg/cluster"
+)
+
+func TestExportKubeConfigs(t *testing.T) {
+	exportDir := t.TempDir()
+
+	tests := []struct {
+		name string
+		opts KubeConfigOptions
+		// failures is the number of times exporting fails before succeeding, as if the file were locked
+		failures int
+		want     []string
+	}{
+		{
+			name: "not exported",
+			want: []string{},
+		},
+		{
+			name: "export directory",
+			opts: KubeConfigOptions{ExportDir: exportDir},
+			want: []string{filepath.Join(exportDir, "test-cluster.kubeconfig")},
+		},
+		{
+			name: "merged into default kubeconfig",
+			opts: KubeConfigOptions{MergeDefault: true},
+			want: []string{""},
+		},
+		{
+			name: "both",
+			opts: KubeConfigOptions{ExportDir: exportDir, MergeDefault: true},
+			want: []string{filepath.Join(exportDir, "test-cluster.kubeconfig"), ""},
+		},
+		{
+			name:     "retried while locked",
+			opts:     KubeConfigOptions{MergeDefault: true},
+			failures: 2,
+			want:     []string{""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			failures := tt.failures
+			exportKubeConfig = func(provider *cluster.Provider, clusterName, path string) error {
+				if failures > 0 {
+					failures--
+					return errors.New("failed to lock config file")
+				}
+				got = append(got, path)
+				return nil
+			}
+
+			k := New(zap.New(), "", 0, "", tt.opts)
+			k.exportKubeConfigs(nil, "test-cluster")
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRemoveExportedKubeConfig(t *testing.T) {
+	exportDir := t.TempDir()
+	path := filepath.Join(exportDir, "test-cluster.kubeconfig")
+	if err := ioutil.WriteFile(path, []byte("apiVersion: v1\nkind: Config\n"), 0600); err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+	k := New(zap.New(), "", 0, "", KubeConfigOptions{ExportDir: exportDir})
+
+	if err := k.removeExportedKubeConfig("test-cluster"); err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("was expecting the kubeconfig to be removed - %v", err)
+	}
+	// Removing it again succeeds, e.g. when a failed delete is retried
+	if err := k.removeExportedKubeConfig("test-cluster"); err != nil {
+		t.Errorf("unexpected error - %v", err)
+	}
+}
+
+func TestWithScratchKubeConfig(t *testing.T) {
+	var paths []string
+	for i := 0; i < 2; i++ {
+		err := withScratchKubeConfig(func(path string) error {
+			paths = append(paths, path)
+			return ioutil.WriteFile(path, []byte("apiVersion: v1\nkind: Config\n"), 0600)
+		})
+		if err != nil {
+			t.Fatalf("unexpected error - %v", err)
+		}
+	}
+
+	// Each operation has its own file, removed once it's finished with
+	if paths[0] == paths[1] {
+		t.Errorf("was expecting a different path for each operation - %+v", paths)
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(filepath.Dir(path)); !os.IsNotExist(err) {
+			t.Errorf("was expecting %s to be removed - %v", filepath.Dir(path), err)
+		}
+	}
+}
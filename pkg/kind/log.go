@@ -8,9 +8,23 @@ import (
 	"sigs.k8s.io/kind/pkg/log"
 )
 
+// Progress receives the progress messages Kind logs during an operation
+type Progress func(message string)
+
 // kindLogger implements the Logger interface
 type kindLogger struct {
 	Log logr.Logger
+	// Verbosity is the highest level of Kind's info messages that are logged
+	Verbosity log.Level
+	// Progress also receives the messages shown to users by the Kind CLI, if set
+	Progress Progress
+}
+
+// report sends the message to the progress func, if set
+func (l kindLogger) report(message string) {
+	if l.Progress != nil && message != "" {
+		l.Progress(message)
+	}
 }
 
 // Warn meets the Logger interface
@@ -19,8 +33,12 @@ func (l kindLogger) Warn(message string) {
 }
 
 // Warnf meets the Logger interface
+//
+// logr has no warning level so warnings are logged as info messages.
 func (l kindLogger) Warnf(format string, args ...interface{}) {
-	l.Log.Info(strings.TrimSpace(fmt.Sprintf(format, args...)))
+	message := strings.TrimSpace(fmt.Sprintf(format, args...))
+	l.Log.Info(message, "severity", "warning")
+	l.report(message)
 }
 
 // Error meets the Logger interface
@@ -30,25 +48,36 @@ func (l kindLogger) Error(message string) {
 
 // Errorf meets the Logger interface
 func (l kindLogger) Errorf(format string, args ...interface{}) {
-	l.Log.Info(strings.TrimSpace(fmt.Sprintf(format, args...)))
+	message := strings.TrimSpace(fmt.Sprintf(format, args...))
+	l.Log.Error(nil, message)
+	l.report(message)
 }
 
 // V meets the Logger interface
+//
+// Levels above the configured verbosity are discarded, the rest are logged at the same logr level. Only level 0
+// messages are reported as progress as those are the messages the Kind CLI shows by default.
 func (l kindLogger) V(level log.Level) log.InfoLogger {
+	if level > l.Verbosity {
+		return noopInfoLogger{}
+	}
+
+	infoLogger := kindInfoLogger{Log: l.Log.V(int(level))}
 	if level == 0 {
-		return kindInfoLogger{l.Log}
+		infoLogger.Progress = l.Progress
 	}
-	return noopInfoLogger{}
+	return infoLogger
 }
 
 // kindInfoLogger implements the InfoLogger interface
 type kindInfoLogger struct {
-	Log logr.Logger
+	Log      logr.Logger
+	Progress Progress
 }
 
-// Enabled meets the InfoLogger interface but always returns false
+// Enabled meets the InfoLogger interface
 func (l kindInfoLogger) Enabled() bool {
-	return false
+	return l.Progress != nil || l.Log.Enabled()
 }
 
 // Info meets the InfoLogger interface
@@ -58,7 +87,11 @@ func (l kindInfoLogger) Info(message string) {
 
 // Infof meets the InfoLogger interface
 func (l kindInfoLogger) Infof(format string, args ...interface{}) {
-	l.Log.Info(strings.TrimSpace(fmt.Sprintf(format, args...)))
+	message := strings.TrimSpace(fmt.Sprintf(format, args...))
+	l.Log.Info(message)
+	if l.Progress != nil && message != "" {
+		l.Progress(message)
+	}
 }
 
 type noopInfoLogger struct{}
@@ -0,0 +1,158 @@
+package kind
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/kind/pkg/log"
+)
+
+// logEntry is a message received by the recording logger
+type logEntry struct {
+	level   int
+	isError bool
+	message string
+}
+
+// recordingLogger is a logr.Logger that records messages up to a maximum level
+type recordingLogger struct {
+	level    int
+	maxLevel int
+	entries  *[]logEntry
+}
+
+func (l recordingLogger) Enabled() bool {
+	return l.level <= l.maxLevel
+}
+
+func (l recordingLogger) Info(msg string, keysAndValues ...interface{}) {
+	if l.Enabled() {
+		*l.entries = append(*l.entries, logEntry{level: l.level, message: msg})
+	}
+}
+
+func (l recordingLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	*l.entries = append(*l.entries, logEntry{level: l.level, isError: true, message: msg})
+}
+
+func (l recordingLogger) V(level int) logr.Logger {
+	l.level += level
+	return l
+}
+
+func (l recordingLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	return l
+}
+
+func (l recordingLogger) WithName(name string) logr.Logger {
+	return l
+}
+
+func TestKindLogger(t *testing.T) {
+	tests := []struct {
+		name             string
+		verbosity        log.Level
+		maxLevel         int
+		log              func(l kindLogger)
+		expected         []logEntry
+		expectedProgress []string
+		expectedEnabled  bool
+	}{
+		{
+			name:             "Error is logged as an error",
+			log:              func(l kindLogger) { l.Errorf("failed to %s", "create") },
+			expected:         []logEntry{{isError: true, message: "failed to create"}},
+			expectedProgress: []string{"failed to create"},
+		},
+		{
+			name:             "Warning is logged as info",
+			log:              func(l kindLogger) { l.Warn("  careful  ") },
+			expected:         []logEntry{{message: "careful"}},
+			expectedProgress: []string{"careful"},
+		},
+		{
+			name:             "Level 0 is logged and reported",
+			log:              func(l kindLogger) { l.V(0).Infof(" • %s  ...\n", "Ensuring node image") },
+			expected:         []logEntry{{message: "• Ensuring node image  ..."}},
+			expectedProgress: []string{"• Ensuring node image  ..."},
+		},
+		{
+			name:     "Level above verbosity is dropped",
+			maxLevel: 10,
+			log:      func(l kindLogger) { l.V(1).Info("debug") },
+		},
+		{
+			name:      "Level within verbosity is logged at the same level but not reported",
+			verbosity: 2,
+			maxLevel:  10,
+			log:       func(l kindLogger) { l.V(2).Info("debug") },
+			expected:  []logEntry{{level: 2, message: "debug"}},
+		},
+		{
+			name:      "Level within verbosity but not enabled by the logger",
+			verbosity: 2,
+			maxLevel:  1,
+			log:       func(l kindLogger) { l.V(2).Info("debug") },
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			entries := []logEntry{}
+			progress := []string{}
+			l := kindLogger{
+				Log:       recordingLogger{maxLevel: tc.maxLevel, entries: &entries},
+				Verbosity: tc.verbosity,
+				Progress:  func(message string) { progress = append(progress, message) },
+			}
+
+			tc.log(l)
+
+			if len(entries) != len(tc.expected) {
+				t.Fatalf("unexpected result - wanted %+v, got %+v", tc.expected, entries)
+			}
+			for i := range entries {
+				if entries[i] != tc.expected[i] {
+					t.Errorf("unexpected result - wanted %+v, got %+v", tc.expected[i], entries[i])
+				}
+			}
+			if len(progress) != len(tc.expectedProgress) {
+				t.Fatalf("unexpected result - wanted %+v, got %+v", tc.expectedProgress, progress)
+			}
+			for i := range progress {
+				if progress[i] != tc.expectedProgress[i] {
+					t.Errorf("unexpected result - wanted %+v, got %+v", tc.expectedProgress[i], progress[i])
+				}
+			}
+		})
+	}
+}
+
+func TestKindLoggerEnabled(t *testing.T) {
+	tests := []struct {
+		name      string
+		verbosity log.Level
+		maxLevel  int
+		level     log.Level
+		progress  Progress
+		expected  bool
+	}{
+		{name: "Level 0 enabled by logger", level: 0, expected: true},
+		{name: "Level above verbosity", verbosity: 1, maxLevel: 5, level: 2, expected: false},
+		{name: "Level within verbosity enabled by logger", verbosity: 2, maxLevel: 5, level: 2, expected: true},
+		{name: "Level within verbosity disabled by logger", verbosity: 2, maxLevel: 1, level: 2, expected: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := kindLogger{
+				Log:       recordingLogger{maxLevel: tc.maxLevel, entries: &[]logEntry{}},
+				Verbosity: tc.verbosity,
+				Progress:  tc.progress,
+			}
+			if enabled := l.V(tc.level).Enabled(); enabled != tc.expected {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tc.expected, enabled)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package kind
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	kindcluster "github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/kind/types"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/version"
+)
+
+// OwnerLabels returns the labels recorded against a cluster identifying the KindCluster and management cluster that
+// own it, along with the version of cluster-api-provider-kind that created it
+//
+// The management cluster label is left out if its ID isn't known.
+func OwnerLabels(kindCluster *kindcluster.KindCluster, managementClusterID string) map[string]string {
+	labels := kindCluster.OwnershipLabels()
+	if managementClusterID != "" {
+		labels[kindcluster.ManagementClusterLabel] = managementClusterID
+	}
+	labels[kindcluster.VersionLabel] = version.Version
+	return labels
+}
+
+// OwnedBy checks if the labels recorded against a cluster allow the management cluster to manage it
+//
+// Clusters recorded without a management cluster, and callers that don't identify their management cluster, are
+// allowed so clusters created before the management cluster was recorded can still be managed.
+func OwnedBy(labels map[string]string, managementClusterID string) bool {
+	owner := labels[kindcluster.ManagementClusterLabel]
+	return owner == "" || managementClusterID == "" || owner == managementClusterID
+}
+
+// FilterByManagementCluster returns the clusters recorded as created by the management cluster, or all clusters if
+// the management cluster isn't known
+//
+// Unlike OwnedBy clusters without a recorded management cluster are left out, as they may belong to any management
+// cluster sharing the Kind server.
+func FilterByManagementCluster(clusters []types.ClusterInfo, managementClusterID string) []types.ClusterInfo {
+	if managementClusterID == "" {
+		return clusters
+	}
+
+	filtered := []types.ClusterInfo{}
+	for _, cluster := range clusters {
+		if cluster.Labels[kindcluster.ManagementClusterLabel] == managementClusterID {
+			filtered = append(filtered, cluster)
+		}
+	}
+	return filtered
+}
+
+// ownerStore records the labels identifying the owner of each cluster
+//
+// Kind has nowhere to keep extra metadata with a cluster so the labels are kept in a file per cluster in the
+// state directory. Nothing is recorded if no directory is set.
+type ownerStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// set records the labels of the cluster, replacing any already recorded
+func (s *ownerStore) set(clusterName string, labels map[string]string) error {
+	if s.dir == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(labels)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.path(clusterName), data, 0600)
+}
+
+// get returns the labels recorded for the cluster, or nil if there are none
+func (s *ownerStore) get(clusterName string) (map[string]string, error) {
+	if s.dir == "" {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := ioutil.ReadFile(s.path(clusterName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	labels := map[string]string{}
+	if err := json.Unmarshal(data, &labels); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+// remove forgets the labels of the cluster
+func (s *ownerStore) remove(clusterName string) error {
+	if s.dir == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(s.path(clusterName)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *ownerStore) path(clusterName string) string {
+	return filepath.Join(s.dir, clusterName+".json")
+}
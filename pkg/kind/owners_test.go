@@ -0,0 +1,95 @@
+package kind
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOwnerStore(t *testing.T) {
+	labels := map[string]string{"kindcluster.cluster.x-k8s.io/name": "test-cluster"}
+
+	tests := []struct {
+		name string
+		dir  string
+		want map[string]string
+	}{
+		{
+			name: "with state directory",
+			dir:  t.TempDir(),
+			want: labels,
+		},
+		{
+			name: "without state directory",
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &ownerStore{dir: tt.dir}
+
+			if err := store.set("test-cluster", labels); err != nil {
+				t.Fatalf("unexpected error - %v", err)
+			}
+			got, err := store.get("test-cluster")
+			if err != nil {
+				t.Fatalf("unexpected error - %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tt.want, got)
+			}
+
+			if err := store.remove("test-cluster"); err != nil {
+				t.Fatalf("unexpected error - %v", err)
+			}
+			if got, err := store.get("test-cluster"); err != nil || got != nil {
+				t.Errorf("unexpected result - wanted %+v, got %+v (%v)", nil, got, err)
+			}
+			if err := store.remove("test-cluster"); err != nil {
+				t.Errorf("unexpected error removing a second time - %v", err)
+			}
+		})
+	}
+}
+
+func TestOwnedBy(t *testing.T) {
+	tests := []struct {
+		name                string
+		labels              map[string]string
+		managementClusterID string
+		want                bool
+	}{
+		{
+			name:                "same management cluster",
+			labels:              map[string]string{"kindcluster.cluster.x-k8s.io/management-cluster": "mc-1"},
+			managementClusterID: "mc-1",
+			want:                true,
+		},
+		{
+			name:                "different management cluster",
+			labels:              map[string]string{"kindcluster.cluster.x-k8s.io/management-cluster": "mc-1"},
+			managementClusterID: "mc-2",
+			want:                false,
+		},
+		{
+			name:                "no management cluster recorded",
+			labels:              nil,
+			managementClusterID: "mc-1",
+			want:                true,
+		},
+		{
+			name:                "caller without management cluster",
+			labels:              map[string]string{"kindcluster.cluster.x-k8s.io/management-cluster": "mc-1"},
+			managementClusterID: "",
+			want:                true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := OwnedBy(tt.labels, tt.managementClusterID); got != tt.want {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package kind
+
+import (
+	"fmt"
+	"os/exec"
+
+	kindcluster "github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/kind/types"
+	"sigs.k8s.io/kind/pkg/cluster"
+	"sigs.k8s.io/kind/pkg/log"
+)
+
+// isAvailable checks if the container runtime for the given node provider can be used on the host
+var isAvailable = func(nodeProvider kindcluster.NodeProvider) bool {
+	return exec.Command(string(nodeProvider), "-v").Run() == nil
+}
+
+// provider returns the Kind provider for the requested node provider, falling back to the
+// server default and then auto-detection if none is requested
+func (k *Kind) provider(nodeProvider kindcluster.NodeProvider) (*cluster.Provider, kindcluster.NodeProvider, error) {
+	nodeProvider, providerOption, err := k.resolveNodeProvider(nodeProvider)
+	if err != nil {
+		return nil, "", err
+	}
+
+	k.providersMu.Lock()
+	defer k.providersMu.Unlock()
+
+	if provider, ok := k.providers[nodeProvider]; ok {
+		return provider, nodeProvider, nil
+	}
+
+	provider := cluster.NewProvider(cluster.ProviderWithLogger(k.logger(nil)), providerOption)
+	k.providers[nodeProvider] = provider
+	return provider, nodeProvider, nil
+}
+
+// operationProvider returns a new Kind provider for a single operation that also reports Kind's progress
+// messages, if a progress func is given
+func (k *Kind) operationProvider(nodeProvider kindcluster.NodeProvider, progress Progress) (*cluster.Provider, kindcluster.NodeProvider, error) {
+	if progress == nil {
+		return k.provider(nodeProvider)
+	}
+
+	nodeProvider, providerOption, err := k.resolveNodeProvider(nodeProvider)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return cluster.NewProvider(cluster.ProviderWithLogger(k.logger(progress)), providerOption), nodeProvider, nil
+}
+
+// logger returns the logger given to Kind providers
+func (k *Kind) logger(progress Progress) kindLogger {
+	return kindLogger{Log: k.log, Verbosity: log.Level(k.verbosity), Progress: progress}
+}
+
+// resolveNodeProvider works out which node provider to use and checks it is available on the host
+func (k *Kind) resolveNodeProvider(nodeProvider kindcluster.NodeProvider) (kindcluster.NodeProvider, cluster.ProviderOption, error) {
+	if nodeProvider == "" {
+		nodeProvider = k.defaultNodeProvider
+	}
+
+	if nodeProvider == "" {
+		for _, candidate := range []kindcluster.NodeProvider{kindcluster.NodeProviderDocker, kindcluster.NodeProviderPodman} {
+			if isAvailable(candidate) {
+				nodeProvider = candidate
+				break
+			}
+		}
+		if nodeProvider == "" {
+			return "", nil, fmt.Errorf("no supported node provider found on the host")
+		}
+	}
+
+	if err := ValidateNodeProvider(nodeProvider); err != nil {
+		return "", nil, err
+	}
+	providerOption := cluster.ProviderWithDocker()
+	if nodeProvider == kindcluster.NodeProviderPodman {
+		providerOption = cluster.ProviderWithPodman()
+	}
+
+	if !isAvailable(nodeProvider) {
+		return "", nil, fmt.Errorf("node provider %s is not available on the host", nodeProvider)
+	}
+
+	return nodeProvider, providerOption, nil
+}
+
+// ValidateNodeProvider checks the node provider is supported by this version of Kind, an empty node provider being
+// auto-detected
+func ValidateNodeProvider(nodeProvider kindcluster.NodeProvider) error {
+	switch nodeProvider {
+	case "", kindcluster.NodeProviderDocker, kindcluster.NodeProviderPodman:
+		return nil
+	case kindcluster.NodeProviderNerdctl:
+		return fmt.Errorf("node provider %s is not supported by this version of Kind", nodeProvider)
+	default:
+		return fmt.Errorf("unknown node provider %s", nodeProvider)
+	}
+}
+
+// ClusterNodeCounts returns the number of nodes in each cluster managed by Kind across all the
+// node providers available on the host
+func (k *Kind) ClusterNodeCounts() (map[string]int, error) {
+	clusters, err := k.ListClusters()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int{}
+	for _, cluster := range clusters {
+		counts[cluster.Name] += cluster.Nodes
+	}
+	return counts, nil
+}
+
+// ListClusters returns the clusters managed by Kind across all the node providers available on the host
+func (k *Kind) ListClusters() ([]types.ClusterInfo, error) {
+	clusters := []types.ClusterInfo{}
+	for _, candidate := range []kindcluster.NodeProvider{kindcluster.NodeProviderDocker, kindcluster.NodeProviderPodman} {
+		if !isAvailable(candidate) {
+			continue
+		}
+
+		provider, _, err := k.provider(candidate)
+		if err != nil {
+			return nil, err
+		}
+
+		clusterNames, err := provider.List()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, clusterName := range clusterNames {
+			nodes, err := provider.ListNodes(clusterName)
+			if err != nil {
+				return nil, err
+			}
+			labels, err := k.owners.get(clusterName)
+			if err != nil {
+				return nil, err
+			}
+			clusters = append(clusters, types.ClusterInfo{
+				Name:         clusterName,
+				NodeProvider: candidate,
+				Nodes:        len(nodes),
+				Labels:       labels,
+			})
+		}
+	}
+	return clusters, nil
+}
@@ -0,0 +1,87 @@
+package kind
+
+import (
+	"testing"
+
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	kindcluster "github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+)
+
+func TestProvider(t *testing.T) {
+	available := map[kindcluster.NodeProvider]bool{
+		kindcluster.NodeProviderPodman: true,
+	}
+	isAvailable = func(nodeProvider kindcluster.NodeProvider) bool {
+		return available[nodeProvider]
+	}
+
+	tests := []struct {
+		name            string
+		defaultProvider kindcluster.NodeProvider
+		requested       kindcluster.NodeProvider
+		want            kindcluster.NodeProvider
+		wantError       bool
+	}{
+		{
+			name: "auto-detect available provider",
+			want: kindcluster.NodeProviderPodman,
+		},
+		{
+			name:            "use server default",
+			defaultProvider: kindcluster.NodeProviderPodman,
+			want:            kindcluster.NodeProviderPodman,
+		},
+		{
+			name:            "requested provider overrides server default",
+			defaultProvider: kindcluster.NodeProviderDocker,
+			requested:       kindcluster.NodeProviderPodman,
+			want:            kindcluster.NodeProviderPodman,
+		},
+		{
+			name:      "requested provider not available",
+			requested: kindcluster.NodeProviderDocker,
+			wantError: true,
+		},
+		{
+			name:      "requested provider not supported",
+			requested: kindcluster.NodeProviderNerdctl,
+			wantError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := New(zap.New(), tt.defaultProvider, 0, "", KubeConfigOptions{})
+			provider, nodeProvider, err := k.provider(tt.requested)
+			if (err != nil) != tt.wantError {
+				t.Fatalf("unexpected result - wanted error %+v, got %+v", tt.wantError, err)
+			}
+			if tt.wantError {
+				return
+			}
+			if provider == nil || nodeProvider != tt.want {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tt.want, nodeProvider)
+			}
+		})
+	}
+}
+
+func TestValidateNodeProvider(t *testing.T) {
+	tests := []struct {
+		nodeProvider kindcluster.NodeProvider
+		wantError    bool
+	}{
+		{nodeProvider: "", wantError: false},
+		{nodeProvider: kindcluster.NodeProviderDocker, wantError: false},
+		{nodeProvider: kindcluster.NodeProviderPodman, wantError: false},
+		{nodeProvider: kindcluster.NodeProviderNerdctl, wantError: true},
+		{nodeProvider: "containerd", wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.nodeProvider), func(t *testing.T) {
+			if err := ValidateNodeProvider(tt.nodeProvider); (err != nil) != tt.wantError {
+				t.Errorf("unexpected result - wanted error %+v, got %+v", tt.wantError, err)
+			}
+		})
+	}
+}
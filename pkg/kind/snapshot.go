@@ -0,0 +1,368 @@
+package kind
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	kindcluster "github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/kind/types"
+	"sigs.k8s.io/kind/pkg/apis/config/v1alpha4"
+	"sigs.k8s.io/kind/pkg/cluster"
+	"sigs.k8s.io/kind/pkg/cluster/nodes"
+	"sigs.k8s.io/kind/pkg/cluster/nodeutils"
+)
+
+// Files kept in the state directory for each snapshot
+const (
+	snapshotInfoFile = "snapshot.json"
+	snapshotEtcdFile = "etcd.db"
+	// The service account signing keys are kept so the tokens stored in etcd remain valid once restored
+	snapshotSAKeyFile    = "sa.key"
+	snapshotSAPubKeyFile = "sa.pub"
+)
+
+// snapshotEtcdScript saves a snapshot of etcd from within the control plane node, writing it to stdout
+const snapshotEtcdScript = `set -e
+etcd=$(crictl ps --quiet --state running --name '^etcd$' | head -n 1)
+crictl exec "$etcd" etcdctl --endpoints=https://127.0.0.1:2379 \
+  --cacert=/etc/kubernetes/pki/etcd/ca.crt \
+  --cert=/etc/kubernetes/pki/etcd/healthcheck-client.crt \
+  --key=/etc/kubernetes/pki/etcd/healthcheck-client.key \
+  snapshot save /var/lib/etcd/capk-snapshot.db >/dev/null
+cat /var/lib/etcd/capk-snapshot.db
+rm -f /var/lib/etcd/capk-snapshot.db
+`
+
+// restoreDir is where the snapshot files are copied to within the control plane node
+const restoreDir = "/var/lib/capk-restore"
+
+// restoreEtcdScript replaces the etcd data and service account keys of the control plane node with those copied to
+// the restore directory
+//
+// The control plane static pods are stopped while the data is replaced. The etcd image of the cluster is used to
+// restore the snapshot, with the member name and peer URLs of the new node.
+const restoreEtcdScript = `set -e
+restore=` + restoreDir + `
+manifests=/etc/kubernetes/manifests
+flag() { sed -n "s/^ *- --$1=//p" "$manifests/etcd.yaml"; }
+image=$(sed -n 's/^ *image: *//p' "$manifests/etcd.yaml")
+name=$(flag name)
+initialCluster=$(flag initial-cluster)
+peerURLs=$(flag initial-advertise-peer-urls)
+
+mkdir -p "$restore/manifests"
+mv "$manifests"/*.yaml "$restore/manifests/"
+for i in $(seq 60); do
+  [ -z "$(crictl ps --quiet --name '^(etcd|kube-apiserver|kube-controller-manager|kube-scheduler)$')" ] && break
+  [ "$i" = 60 ] && echo "control plane did not stop" >&2 && exit 1
+  sleep 1
+done
+
+cp "$restore/sa.key" "$restore/sa.pub" /etc/kubernetes/pki/
+ctr --namespace k8s.io run --rm --env ETCDCTL_API=3 \
+  --mount "type=bind,src=$restore,dst=$restore,options=rbind:rw" "$image" capk-etcd-restore \
+  etcdctl snapshot restore "$restore/etcd.db" --name "$name" --initial-cluster "$initialCluster" \
+  --initial-advertise-peer-urls "$peerURLs" --data-dir "$restore/etcd"
+rm -rf /var/lib/etcd/member
+mv "$restore/etcd/member" /var/lib/etcd/member
+mv "$restore/manifests"/*.yaml "$manifests/"
+rm -rf "$restore"
+`
+
+// CreateSnapshot keeps a copy of the cluster's etcd data and service account keys in the state directory, so new
+// clusters can be restored from it
+//
+// Only the state held in etcd is restored, new clusters start with fresh nodes so anything kept on the nodes'
+// filesystems, such as the contents of hostPath volumes, isn't. Only clusters with a single control plane node are
+// supported, as the etcd data is restored as a single member.
+func (k *Kind) CreateSnapshot(clusterName, snapshotName string, nodeProvider kindcluster.NodeProvider) (types.SnapshotInfo, error) {
+	if k.snapshots.dir == "" {
+		return types.SnapshotInfo{}, fmt.Errorf("snapshots can't be taken as the Kind server has no state directory")
+	}
+
+	provider, nodeProvider, err := k.provider(nodeProvider)
+	if err != nil {
+		return types.SnapshotInfo{}, err
+	}
+	clusterNodes, err := provider.ListNodes(clusterName)
+	if err != nil {
+		return types.SnapshotInfo{}, err
+	}
+	if len(clusterNodes) == 0 {
+		return types.SnapshotInfo{}, fmt.Errorf("no nodes found for cluster %q", clusterName)
+	}
+	controlPlanes, err := nodeutils.ControlPlaneNodes(clusterNodes)
+	if err != nil {
+		return types.SnapshotInfo{}, err
+	}
+	if len(controlPlanes) != 1 {
+		return types.SnapshotInfo{}, fmt.Errorf("cluster %q has %d control plane nodes, only clusters with one can be snapshotted", clusterName, len(controlPlanes))
+	}
+
+	labels, err := k.owners.get(clusterName)
+	if err != nil {
+		return types.SnapshotInfo{}, err
+	}
+	image, err := runtimeCommand(nodeProvider, "inspect", "--format", "{{.Config.Image}}", controlPlanes[0].String())
+	if err != nil {
+		return types.SnapshotInfo{}, err
+	}
+
+	dir, err := k.snapshots.create(snapshotName)
+	if err != nil {
+		return types.SnapshotInfo{}, err
+	}
+	info := types.SnapshotInfo{
+		Name:         snapshotName,
+		ClusterName:  clusterName,
+		NodeProvider: nodeProvider,
+		Image:        image,
+		Labels:       labels,
+		Contents:     []string{types.SnapshotContentsEtcd, types.SnapshotContentsServiceAccountKeys},
+	}
+	if err := k.takeSnapshot(dir, controlPlanes[0], info); err != nil {
+		// Don't leave a partial snapshot behind
+		if removeErr := k.snapshots.remove(snapshotName); removeErr != nil {
+			k.log.Error(removeErr, "failed to remove failed snapshot", "snapshot", snapshotName)
+		}
+		return types.SnapshotInfo{}, err
+	}
+	return info, nil
+}
+
+// takeSnapshot copies the etcd data and service account keys out of the control plane node then saves the info
+func (k *Kind) takeSnapshot(dir string, controlPlane nodes.Node, info types.SnapshotInfo) error {
+	etcd, err := os.Create(filepath.Join(dir, snapshotEtcdFile))
+	if err != nil {
+		return err
+	}
+	defer etcd.Close()
+	if err := controlPlane.Command("sh", "-c", snapshotEtcdScript).SetStdout(etcd).Run(); err != nil {
+		return fmt.Errorf("failed to snapshot etcd: %s", commandError(err))
+	}
+
+	for file, src := range map[string]string{
+		snapshotSAKeyFile:    "/etc/kubernetes/pki/sa.key",
+		snapshotSAPubKeyFile: "/etc/kubernetes/pki/sa.pub",
+	} {
+		if err := copyFromNode(controlPlane, src, filepath.Join(dir, file)); err != nil {
+			return err
+		}
+	}
+
+	return k.snapshots.save(info)
+}
+
+// ValidateSnapshotName checks the snapshot name can be used as the name of its directory, so it can't reach outside
+// the state directory
+func ValidateSnapshotName(snapshotName string) error {
+	if !isFileName(snapshotName) {
+		return fmt.Errorf("invalid snapshot name %q", snapshotName)
+	}
+	return nil
+}
+
+// isFileName checks the name, which may come from a request, is that of a file directly within a directory
+func isFileName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, `/\`) && !strings.HasPrefix(name, ".")
+}
+
+// GetSnapshot returns the snapshot with the given name, or nil if it doesn't exist
+func (k *Kind) GetSnapshot(snapshotName string) (*types.SnapshotInfo, error) {
+	return k.snapshots.get(snapshotName)
+}
+
+// DeleteSnapshot removes the snapshot's files, succeeding if it doesn't exist
+func (k *Kind) DeleteSnapshot(snapshotName string) error {
+	return k.snapshots.remove(snapshotName)
+}
+
+// checkRestorable checks the cluster config is compatible with the snapshot it is restored from
+func checkRestorable(config *v1alpha4.Cluster, snapshot *types.SnapshotInfo) error {
+	controlPlanes := 0
+	for _, node := range config.Nodes {
+		if node.Role == v1alpha4.ControlPlaneRole {
+			controlPlanes++
+		}
+		if node.Image != snapshot.Image {
+			return fmt.Errorf("snapshot %q was taken of a cluster using node image %s, not %s", snapshot.Name, snapshot.Image, node.Image)
+		}
+	}
+	if controlPlanes != 1 {
+		return fmt.Errorf("clusters restored from a snapshot must have one control plane node, not %d", controlPlanes)
+	}
+	return nil
+}
+
+// restoreSnapshot replaces the state of the newly created cluster with that of the snapshot, waiting for its API
+// server to be ready again
+//
+// The snapshotted cluster's nodes are still recorded in etcd so are removed, leaving the new nodes that registered
+// themselves when the cluster was created.
+func (k *Kind) restoreSnapshot(provider *cluster.Provider, clusterName string, snapshot *types.SnapshotInfo) error {
+	clusterNodes, err := provider.ListNodes(clusterName)
+	if err != nil {
+		return err
+	}
+	controlPlane, err := nodeutils.BootstrapControlPlaneNode(clusterNodes)
+	if err != nil {
+		return err
+	}
+
+	dir := k.snapshots.path(snapshot.Name)
+	for _, file := range []string{snapshotEtcdFile, snapshotSAKeyFile, snapshotSAPubKeyFile} {
+		if err := copyToNode(controlPlane, filepath.Join(dir, file), restoreDir+"/"+file); err != nil {
+			return err
+		}
+	}
+	if err := controlPlane.Command("sh", "-c", restoreEtcdScript).Run(); err != nil {
+		return fmt.Errorf("failed to restore etcd: %s", commandError(err))
+	}
+	if err := waitForAPIServer(clusterName, controlPlane); err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	cmd := controlPlane.Command("kubectl", "--kubeconfig=/etc/kubernetes/admin.conf", "get", "nodes", "--output=name")
+	if err := cmd.SetStdout(&out).Run(); err != nil {
+		return fmt.Errorf("failed to list restored nodes: %s", commandError(err))
+	}
+	current := map[string]bool{}
+	for _, name := range nodeNames(clusterNodes) {
+		current[name] = true
+	}
+	stale := []string{}
+	for _, name := range strings.Fields(out.String()) {
+		if name = strings.TrimPrefix(name, "node/"); !current[name] {
+			stale = append(stale, name)
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	args := append([]string{"--kubeconfig=/etc/kubernetes/admin.conf", "delete", "nodes"}, stale...)
+	if err := controlPlane.Command("kubectl", args...).Run(); err != nil {
+		return fmt.Errorf("failed to remove nodes of the snapshotted cluster: %s", commandError(err))
+	}
+	return nil
+}
+
+// copyFromNode copies a file out of the node
+func copyFromNode(node nodes.Node, src, dst string) error {
+	f, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := node.Command("cat", src).SetStdout(f).Run(); err != nil {
+		return fmt.Errorf("failed to copy %s from node %s: %s", src, node, commandError(err))
+	}
+	return nil
+}
+
+// copyToNode copies a file into the node, creating its directory if needed
+func copyToNode(node nodes.Node, src, dst string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	cmd := node.Command("sh", "-c", `mkdir -p "$(dirname "$1")" && cat > "$1"`, "sh", dst)
+	if err := cmd.SetStdin(f).Run(); err != nil {
+		return fmt.Errorf("failed to copy %s to node %s: %s", src, node, commandError(err))
+	}
+	return nil
+}
+
+// snapshotStore keeps the files of each snapshot in a directory per snapshot
+//
+// Nothing can be stored if no directory is set.
+type snapshotStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// create makes the directory of a new snapshot, failing if the snapshot already exists
+func (s *snapshotStore) create(snapshotName string) (string, error) {
+	if err := ValidateSnapshotName(snapshotName); err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return "", err
+	}
+	dir := s.path(snapshotName)
+	if err := os.Mkdir(dir, 0700); os.IsExist(err) {
+		return "", fmt.Errorf("snapshot %q already exists", snapshotName)
+	} else if err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// save records the details of the snapshot, marking it as complete
+func (s *snapshotStore) save(info types.SnapshotInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(s.path(info.Name), snapshotInfoFile), data, 0600)
+}
+
+// get returns the details of the snapshot, or nil if it doesn't exist or is incomplete
+func (s *snapshotStore) get(snapshotName string) (*types.SnapshotInfo, error) {
+	if s.dir == "" {
+		return nil, nil
+	}
+	if err := ValidateSnapshotName(snapshotName); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := ioutil.ReadFile(filepath.Join(s.path(snapshotName), snapshotInfoFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	info := &types.SnapshotInfo{}
+	if err := json.Unmarshal(data, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// remove deletes the files of the snapshot
+func (s *snapshotStore) remove(snapshotName string) error {
+	if s.dir == "" {
+		return nil
+	}
+	// Removing a name such as `..` would remove the whole state directory
+	if err := ValidateSnapshotName(snapshotName); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return os.RemoveAll(s.path(snapshotName))
+}
+
+func (s *snapshotStore) path(snapshotName string) string {
+	return filepath.Join(s.dir, snapshotName)
+}
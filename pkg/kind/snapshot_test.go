@@ -0,0 +1,115 @@
+package kind
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/kind/pkg/apis/config/v1alpha4"
+
+	"github.com/AverageMarcus/cluster-api-provider-kind/pkg/kind/types"
+)
+
+func TestCheckRestorable(t *testing.T) {
+	snapshot := &types.SnapshotInfo{Name: "default-test-snapshot", Image: "kindest/node:v1.21.1"}
+
+	tests := []struct {
+		name    string
+		nodes   []v1alpha4.Node
+		wantErr bool
+	}{
+		{
+			name: "single control plane with the snapshot's image",
+			nodes: []v1alpha4.Node{
+				{Role: v1alpha4.ControlPlaneRole, Image: "kindest/node:v1.21.1"},
+				{Role: v1alpha4.WorkerRole, Image: "kindest/node:v1.21.1"},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "different image",
+			nodes:   []v1alpha4.Node{{Role: v1alpha4.ControlPlaneRole, Image: "kindest/node:v1.20.7"}},
+			wantErr: true,
+		},
+		{
+			name: "multiple control planes",
+			nodes: []v1alpha4.Node{
+				{Role: v1alpha4.ControlPlaneRole, Image: "kindest/node:v1.21.1"},
+				{Role: v1alpha4.ControlPlaneRole, Image: "kindest/node:v1.21.1"},
+				{Role: v1alpha4.ControlPlaneRole, Image: "kindest/node:v1.21.1"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRestorable(&v1alpha4.Cluster{Nodes: tt.nodes}, snapshot)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestSnapshotStore(t *testing.T) {
+	store := &snapshotStore{dir: t.TempDir()}
+
+	info := types.SnapshotInfo{Name: "default-test-snapshot", ClusterName: "default-test-cluster"}
+	if _, err := store.create(info.Name); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if _, err := store.create(info.Name); err == nil {
+		t.Errorf("was expecting an error creating a duplicate snapshot")
+	}
+
+	// Snapshots aren't visible until their details are saved
+	if actual, err := store.get(info.Name); err != nil || actual != nil {
+		t.Errorf("unexpected result - wanted %+v, got %+v %+v", nil, actual, err)
+	}
+	if err := store.save(info); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	actual, err := store.get(info.Name)
+	if err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if actual == nil || !reflect.DeepEqual(*actual, info) {
+		t.Errorf("unexpected result - wanted %+v, got %+v", info, actual)
+	}
+
+	if err := store.remove(info.Name); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+	if actual, err := store.get(info.Name); err != nil || actual != nil {
+		t.Errorf("unexpected result - wanted %+v, got %+v %+v", nil, actual, err)
+	}
+}
+
+func TestSnapshotStoreInvalidName(t *testing.T) {
+	dir := t.TempDir()
+	store := &snapshotStore{dir: filepath.Join(dir, "snapshots")}
+	if _, err := store.create("default-test-snapshot"); err != nil {
+		t.Fatalf("unexpected error - %+v", err)
+	}
+
+	for _, name := range []string{"", ".", "..", ".hidden", "default/test-snapshot", `default\test-snapshot`} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.create(name); err == nil {
+				t.Errorf("was expecting an error creating the snapshot")
+			}
+			if _, err := store.get(name); err == nil {
+				t.Errorf("was expecting an error getting the snapshot")
+			}
+			if err := store.remove(name); err == nil {
+				t.Errorf("was expecting an error removing the snapshot")
+			}
+		})
+	}
+
+	// Nothing outside the snapshot is removed
+	if _, err := os.Stat(store.path("default-test-snapshot")); err != nil {
+		t.Errorf("unexpected error - %+v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package kind
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	kindcluster "github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+	"sigs.k8s.io/kind/pkg/cluster/nodes"
+	"sigs.k8s.io/kind/pkg/cluster/nodeutils"
+	kindexec "sigs.k8s.io/kind/pkg/exec"
+)
+
+// apiServerPollInterval is how often the API server is checked while waiting for it to be ready
+const apiServerPollInterval = 2 * time.Second
+
+// runtimeCommand runs a command of the container runtime on the host, returning its output
+var runtimeCommand = func(nodeProvider kindcluster.NodeProvider, args ...string) (string, error) {
+	output, err := exec.Command(string(nodeProvider), args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s %s failed: %w: %s", nodeProvider, args[0], err, strings.TrimSpace(string(output)))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// SuspendCluster stops the node containers of the cluster, keeping them so the cluster can be resumed
+func (k *Kind) SuspendCluster(clusterName string, nodeProvider kindcluster.NodeProvider) error {
+	provider, nodeProvider, err := k.provider(nodeProvider)
+	if err != nil {
+		return err
+	}
+	clusterNodes, err := provider.ListNodes(clusterName)
+	if err != nil {
+		return err
+	}
+	if len(clusterNodes) == 0 {
+		return fmt.Errorf("no nodes found for cluster %q", clusterName)
+	}
+	_, err = runtimeCommand(nodeProvider, append([]string{"stop"}, nodeNames(clusterNodes)...)...)
+	return err
+}
+
+// ResumeCluster restarts the node containers of a suspended cluster, waiting for its API server to be ready
+func (k *Kind) ResumeCluster(clusterName string, nodeProvider kindcluster.NodeProvider) error {
+	provider, nodeProvider, err := k.provider(nodeProvider)
+	if err != nil {
+		return err
+	}
+	clusterNodes, err := provider.ListNodes(clusterName)
+	if err != nil {
+		return err
+	}
+	if len(clusterNodes) == 0 {
+		return fmt.Errorf("no nodes found for cluster %q", clusterName)
+	}
+	if _, err := runtimeCommand(nodeProvider, append([]string{"start"}, nodeNames(clusterNodes)...)...); err != nil {
+		return err
+	}
+
+	controlPlane, err := nodeutils.BootstrapControlPlaneNode(clusterNodes)
+	if err != nil {
+		return err
+	}
+	return waitForAPIServer(clusterName, controlPlane)
+}
+
+// waitForAPIServer waits for the API server of the cluster to be ready
+//
+// Kind's own readiness check is used, running kubectl within the control plane node, waiting as long as Kind does
+// by default when creating a cluster.
+func waitForAPIServer(clusterName string, controlPlane nodes.Node) error {
+	deadline := time.Now().Add(kindcluster.DefaultWaitForReady)
+	for {
+		cmd := controlPlane.Command("kubectl", "--kubeconfig=/etc/kubernetes/admin.conf", "get", "--raw=/readyz")
+		if err := cmd.Run(); err == nil {
+			return nil
+		} else if time.Now().After(deadline) {
+			return fmt.Errorf("API server of cluster %q not ready after %s: %s", clusterName, kindcluster.DefaultWaitForReady, commandError(err))
+		}
+		time.Sleep(apiServerPollInterval)
+	}
+}
+
+// nodeNames returns the container names of the nodes
+func nodeNames(clusterNodes []nodes.Node) []string {
+	names := make([]string, 0, len(clusterNodes))
+	for _, node := range clusterNodes {
+		names = append(names, node.String())
+	}
+	return names
+}
+
+// commandError includes the output of a failed command run within a node in the error message
+func commandError(err error) string {
+	if runErr, ok := err.(*kindexec.RunError); ok && len(runErr.Output) > 0 {
+		return strings.TrimSpace(string(runErr.Output))
+	}
+	return err.Error()
+}
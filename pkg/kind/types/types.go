@@ -0,0 +1,47 @@
+// Package types contains the details the Kind server responds with, kept apart from the server so its client doesn't
+// depend on Kind
+package types
+
+import (
+	"time"
+
+	kindcluster "github.com/AverageMarcus/cluster-api-provider-kind/api/v1alpha4"
+)
+
+// ClusterInfo describes a cluster managed by Kind
+type ClusterInfo struct {
+	Name         string                   `json:"name"`
+	NodeProvider kindcluster.NodeProvider `json:"nodeProvider"`
+	Nodes        int                      `json:"nodes"`
+	// Labels identify the KindCluster that owns the cluster, they're empty for clusters not created by the server
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// What a snapshot keeps of the snapshotted cluster
+const (
+	SnapshotContentsEtcd               = "etcd"
+	SnapshotContentsServiceAccountKeys = "serviceAccountKeys"
+)
+
+// SnapshotInfo describes a snapshot of a cluster kept by the Kind server
+type SnapshotInfo struct {
+	Name         string                   `json:"name"`
+	ClusterName  string                   `json:"clusterName"`
+	NodeProvider kindcluster.NodeProvider `json:"nodeProvider"`
+	// Image is the node image of the snapshotted cluster, clusters restored from the snapshot must use the same image
+	Image string `json:"image"`
+	// Labels are those of the snapshotted cluster, identifying its owner
+	Labels map[string]string `json:"labels,omitempty"`
+	// Contents lists what the snapshot keeps and restores into new clusters, nothing kept on the nodes' filesystems,
+	// such as hostPath or local-path volumes and images loaded into the nodes, is included
+	Contents []string `json:"contents"`
+}
+
+// LogArchiveInfo describes an archive of a cluster's node logs kept by the Kind server
+type LogArchiveInfo struct {
+	Name        string    `json:"name"`
+	ClusterName string    `json:"clusterName"`
+	Created     time.Time `json:"created"`
+	// Labels are those of the cluster the logs were collected from, identifying its owner
+	Labels map[string]string `json:"labels,omitempty"`
+}
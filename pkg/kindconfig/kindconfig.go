@@ -0,0 +1,164 @@
+package kindconfig
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"sigs.k8s.io/kind/pkg/apis/config/v1alpha4"
+)
+
+// typeMeta is metav1.TypeMeta but with yaml tags, as used by Kind
+type typeMeta struct {
+	Kind       string `yaml:"kind,omitempty"`
+	APIVersion string `yaml:"apiVersion,omitempty"`
+}
+
+// Parse decodes a raw Kind cluster config the same way Kind does, rejecting
+// unknown apiVersions, kinds and fields
+func Parse(raw string) (*v1alpha4.Cluster, error) {
+	tm := typeMeta{}
+	if err := yaml.Unmarshal([]byte(raw), &tm); err != nil {
+		return nil, fmt.Errorf("could not determine kind / apiVersion for config: %w", err)
+	}
+
+	if tm.APIVersion != "kind.x-k8s.io/v1alpha4" {
+		return nil, fmt.Errorf("unknown apiVersion: %s", tm.APIVersion)
+	}
+
+	if tm.Kind != "Cluster" {
+		return nil, fmt.Errorf("unknown kind %s for apiVersion: %s", tm.Kind, tm.APIVersion)
+	}
+
+	config := &v1alpha4.Cluster{}
+	decoder := yaml.NewDecoder(bytes.NewReader([]byte(raw)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil {
+		return nil, fmt.Errorf("unable to decode config: %w", err)
+	}
+
+	return config, nil
+}
+
+// Conflicts returns the fields set in the raw config that are already set in the generated config
+func Conflicts(generated, raw *v1alpha4.Cluster) []string {
+	conflicts := []string{}
+
+	if raw.Name != "" {
+		conflicts = append(conflicts, "name")
+	}
+
+	for key := range raw.FeatureGates {
+		if _, ok := generated.FeatureGates[key]; ok {
+			conflicts = append(conflicts, fmt.Sprintf("featureGates[%s]", key))
+		}
+	}
+
+	for key := range raw.RuntimeConfig {
+		if _, ok := generated.RuntimeConfig[key]; ok {
+			conflicts = append(conflicts, fmt.Sprintf("runtimeConfig[%s]", key))
+		}
+	}
+
+	if raw.Networking.IPFamily != "" && generated.Networking.IPFamily != "" {
+		conflicts = append(conflicts, "networking.ipFamily")
+	}
+
+	if raw.Networking.PodSubnet != "" && generated.Networking.PodSubnet != "" {
+		conflicts = append(conflicts, "networking.podSubnet")
+	}
+
+	if raw.Networking.ServiceSubnet != "" && generated.Networking.ServiceSubnet != "" {
+		conflicts = append(conflicts, "networking.serviceSubnet")
+	}
+
+	if len(raw.Nodes) > 0 {
+		if len(raw.Nodes) != len(generated.Nodes) {
+			conflicts = append(conflicts, "nodes")
+		} else {
+			for i, node := range raw.Nodes {
+				if node.Role != "" && node.Role != generated.Nodes[i].Role {
+					conflicts = append(conflicts, fmt.Sprintf("nodes[%d].role", i))
+				}
+				if node.Image != "" {
+					conflicts = append(conflicts, fmt.Sprintf("nodes[%d].image", i))
+				}
+			}
+		}
+	}
+
+	sort.Strings(conflicts)
+	return conflicts
+}
+
+// Merge applies the raw config on top of the generated config
+//
+// Nodes in the raw config are matched to the generated nodes by position so
+// extra mounts, port mappings and patches can be added to specific nodes.
+func Merge(generated, raw *v1alpha4.Cluster) error {
+	if conflicts := Conflicts(generated, raw); len(conflicts) > 0 {
+		return fmt.Errorf("raw config conflicts with the KindCluster spec: %s", strings.Join(conflicts, ", "))
+	}
+
+	if len(raw.FeatureGates) > 0 && generated.FeatureGates == nil {
+		generated.FeatureGates = map[string]bool{}
+	}
+	for key, value := range raw.FeatureGates {
+		generated.FeatureGates[key] = value
+	}
+
+	if len(raw.RuntimeConfig) > 0 && generated.RuntimeConfig == nil {
+		generated.RuntimeConfig = map[string]string{}
+	}
+	for key, value := range raw.RuntimeConfig {
+		generated.RuntimeConfig[key] = value
+	}
+
+	mergeNetworking(&generated.Networking, raw.Networking)
+
+	for i, node := range raw.Nodes {
+		generatedNode := &generated.Nodes[i]
+		if len(node.Labels) > 0 && generatedNode.Labels == nil {
+			generatedNode.Labels = map[string]string{}
+		}
+		for key, value := range node.Labels {
+			generatedNode.Labels[key] = value
+		}
+		generatedNode.ExtraMounts = append(generatedNode.ExtraMounts, node.ExtraMounts...)
+		generatedNode.ExtraPortMappings = append(generatedNode.ExtraPortMappings, node.ExtraPortMappings...)
+		generatedNode.KubeadmConfigPatches = append(generatedNode.KubeadmConfigPatches, node.KubeadmConfigPatches...)
+		generatedNode.KubeadmConfigPatchesJSON6902 = append(generatedNode.KubeadmConfigPatchesJSON6902, node.KubeadmConfigPatchesJSON6902...)
+	}
+
+	generated.KubeadmConfigPatches = append(generated.KubeadmConfigPatches, raw.KubeadmConfigPatches...)
+	generated.KubeadmConfigPatchesJSON6902 = append(generated.KubeadmConfigPatchesJSON6902, raw.KubeadmConfigPatchesJSON6902...)
+	generated.ContainerdConfigPatches = append(generated.ContainerdConfigPatches, raw.ContainerdConfigPatches...)
+	generated.ContainerdConfigPatchesJSON6902 = append(generated.ContainerdConfigPatchesJSON6902, raw.ContainerdConfigPatchesJSON6902...)
+
+	return nil
+}
+
+// mergeNetworking fills any networking values not set by the spec from the raw config
+func mergeNetworking(generated *v1alpha4.Networking, raw v1alpha4.Networking) {
+	if generated.IPFamily == "" {
+		generated.IPFamily = raw.IPFamily
+	}
+	if generated.APIServerPort == 0 {
+		generated.APIServerPort = raw.APIServerPort
+	}
+	if generated.APIServerAddress == "" {
+		generated.APIServerAddress = raw.APIServerAddress
+	}
+	if generated.PodSubnet == "" {
+		generated.PodSubnet = raw.PodSubnet
+	}
+	if generated.ServiceSubnet == "" {
+		generated.ServiceSubnet = raw.ServiceSubnet
+	}
+	if generated.KubeProxyMode == "" {
+		generated.KubeProxyMode = raw.KubeProxyMode
+	}
+	generated.DisableDefaultCNI = generated.DisableDefaultCNI || raw.DisableDefaultCNI
+}
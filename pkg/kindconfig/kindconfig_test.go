@@ -0,0 +1,182 @@
+package kindconfig
+
+import (
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/kind/pkg/apis/config/v1alpha4"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		raw       string
+		wantError bool
+	}{
+		{
+			name: "valid config",
+			raw: `kind: Cluster
+apiVersion: kind.x-k8s.io/v1alpha4
+networking:
+  disableDefaultCNI: true`,
+			wantError: false,
+		},
+		{
+			name: "unknown apiVersion",
+			raw: `kind: Cluster
+apiVersion: kind.x-k8s.io/v1alpha3`,
+			wantError: true,
+		},
+		{
+			name: "unknown kind",
+			raw: `kind: Node
+apiVersion: kind.x-k8s.io/v1alpha4`,
+			wantError: true,
+		},
+		{
+			name: "unknown field",
+			raw: `kind: Cluster
+apiVersion: kind.x-k8s.io/v1alpha4
+notAField: true`,
+			wantError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.raw)
+			if (err != nil) != tt.wantError {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tt.wantError, err)
+			}
+		})
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	generated := &v1alpha4.Cluster{
+		FeatureGates:  map[string]bool{"EphemeralContainers": true},
+		RuntimeConfig: map[string]string{"api/alpha": "false"},
+		Networking: v1alpha4.Networking{
+			IPFamily:  v1alpha4.DualStackFamily,
+			PodSubnet: "10.244.0.0/16",
+		},
+		Nodes: []v1alpha4.Node{
+			{Role: v1alpha4.ControlPlaneRole},
+			{Role: v1alpha4.WorkerRole},
+		},
+	}
+
+	tests := []struct {
+		name string
+		raw  *v1alpha4.Cluster
+		want []string
+	}{
+		{
+			name: "no conflicts",
+			raw: &v1alpha4.Cluster{
+				FeatureGates: map[string]bool{"IPv6DualStack": true},
+				Networking: v1alpha4.Networking{
+					ServiceSubnet:     "10.96.0.0/12",
+					DisableDefaultCNI: true,
+				},
+				Nodes: []v1alpha4.Node{
+					{Role: v1alpha4.ControlPlaneRole},
+					{ExtraMounts: []v1alpha4.Mount{{HostPath: "/tmp", ContainerPath: "/tmp"}}},
+				},
+			},
+			want: []string{},
+		},
+		{
+			name: "conflicting fields",
+			raw: &v1alpha4.Cluster{
+				Name:          "other",
+				FeatureGates:  map[string]bool{"EphemeralContainers": false},
+				RuntimeConfig: map[string]string{"api/alpha": "true"},
+				Networking: v1alpha4.Networking{
+					IPFamily:  v1alpha4.IPv6Family,
+					PodSubnet: "192.168.0.0/16",
+				},
+			},
+			want: []string{"featureGates[EphemeralContainers]", "name", "networking.ipFamily", "networking.podSubnet", "runtimeConfig[api/alpha]"},
+		},
+		{
+			name: "different number of nodes",
+			raw: &v1alpha4.Cluster{
+				Nodes: []v1alpha4.Node{
+					{Role: v1alpha4.ControlPlaneRole},
+				},
+			},
+			want: []string{"nodes"},
+		},
+		{
+			name: "conflicting node fields",
+			raw: &v1alpha4.Cluster{
+				Nodes: []v1alpha4.Node{
+					{Role: v1alpha4.WorkerRole},
+					{Image: "kindest/node:v1.21.1"},
+				},
+			},
+			want: []string{"nodes[0].role", "nodes[1].image"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Conflicts(generated, tt.raw)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	generated := &v1alpha4.Cluster{
+		FeatureGates: map[string]bool{"EphemeralContainers": true},
+		Networking: v1alpha4.Networking{
+			PodSubnet: "10.244.0.0/16",
+		},
+		Nodes: []v1alpha4.Node{
+			{Role: v1alpha4.ControlPlaneRole, Image: "kindest/node:v1.21.1"},
+		},
+	}
+
+	raw, err := Parse(`kind: Cluster
+apiVersion: kind.x-k8s.io/v1alpha4
+featureGates:
+  IPv6DualStack: true
+networking:
+  disableDefaultCNI: true
+nodes:
+- role: control-plane
+  extraPortMappings:
+  - containerPort: 80
+    hostPort: 8080`)
+	if err != nil {
+		t.Fatalf("unexpected error parsing config - %+v", err)
+	}
+
+	if err := Merge(generated, raw); err != nil {
+		t.Fatalf("unexpected error merging config - %+v", err)
+	}
+
+	want := &v1alpha4.Cluster{
+		FeatureGates: map[string]bool{"EphemeralContainers": true, "IPv6DualStack": true},
+		Networking: v1alpha4.Networking{
+			PodSubnet:         "10.244.0.0/16",
+			DisableDefaultCNI: true,
+		},
+		Nodes: []v1alpha4.Node{
+			{
+				Role:              v1alpha4.ControlPlaneRole,
+				Image:             "kindest/node:v1.21.1",
+				ExtraPortMappings: []v1alpha4.PortMapping{{ContainerPort: 80, HostPort: 8080}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(generated, want) {
+		t.Errorf("unexpected result - wanted %+v, got %+v", want, generated)
+	}
+
+	if err := Merge(generated, &v1alpha4.Cluster{Name: "other"}); err == nil {
+		t.Errorf("was expecting an error when merging conflicting config")
+	}
+}
@@ -2,8 +2,9 @@ package kubeconfig
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"strconv"
-	"strings"
 
 	"gopkg.in/yaml.v2"
 )
@@ -38,29 +39,37 @@ func ExtractEndpoint(kubeconfig string, clusterName string) (*ClusterEndpoint, e
 
 	for _, cluster := range config.Clusters {
 		if cluster.Name == fmt.Sprintf("kind-%s", clusterName) {
-			serverEndpoint := cluster.Cluster.Server
-			serverEndpoint = strings.TrimPrefix(serverEndpoint, "https://")
-			serverEndpoint = strings.TrimPrefix(serverEndpoint, "http://")
-			parts := strings.Split(serverEndpoint, ":")
-
-			if len(parts) != 2 {
-				// Unexpected server endpoint URL, lets keep looking
-				continue
-			}
-
-			port, err := strconv.ParseInt(parts[1], 10, 32)
+			endpoint, err := parseServer(cluster.Cluster.Server)
 			if err != nil {
-				// Unable to parse port, lets keep looking
+				// Unexpected server endpoint URL, lets keep looking
 				continue
 			}
-
-			endpoint := &ClusterEndpoint{
-				Host: parts[0],
-				Port: int32(port),
-			}
 			return endpoint, nil
 		}
 	}
 
 	return nil, fmt.Errorf("Unable to find valid server details for %s", clusterName)
 }
+
+// parseServer splits the server URL into its host and port, the host of an IPv6 address being without brackets
+func parseServer(server string) (*ClusterEndpoint, error) {
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	host, portString, err := net.SplitHostPort(serverURL.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := strconv.ParseInt(portString, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ClusterEndpoint{
+		Host: host,
+		Port: int32(port),
+	}, nil
+}
@@ -52,6 +52,45 @@ func TestExtractEndpoint(t *testing.T) {
 			host:        "100.100.100.100",
 			port:        6000,
 		},
+		{
+			kubeConfig: `clusters:
+- name: kind-ipv6
+  cluster:
+    server: https://[::1]:6443`,
+			clusterName: "ipv6",
+			host:        "::1",
+			port:        6443,
+		},
+		{
+			kubeConfig: `clusters:
+- name: kind-ipv6-global
+  cluster:
+    server: https://[2001:db8::10]:38211`,
+			clusterName: "ipv6-global",
+			host:        "2001:db8::10",
+			port:        38211,
+		},
+		{
+			kubeConfig: `clusters:
+- name: kind-ipv4-loopback
+  cluster:
+    server: https://127.0.0.1:40123`,
+			clusterName: "ipv4-loopback",
+			host:        "127.0.0.1",
+			port:        40123,
+		},
+		{
+			kubeConfig: `clusters:
+- name: kind-skip-invalid
+  cluster:
+    server: https://::1:6443
+- name: kind-skip-invalid
+  cluster:
+    server: https://[::1]:6443`,
+			clusterName: "skip-invalid",
+			host:        "::1",
+			port:        6443,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.clusterName, func(t *testing.T) {
@@ -70,3 +109,28 @@ func TestExtractEndpoint(t *testing.T) {
 		})
 	}
 }
+
+func TestExtractEndpointInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		server string
+	}{
+		{name: "missing port", server: "https://1.2.3.4"},
+		{name: "unbracketed IPv6", server: "https://::1:6443"},
+		{name: "non-numeric port", server: "https://[::1]:api"},
+		{name: "missing scheme", server: "1.2.3.4:1000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kubeConfig := `clusters:
+- name: kind-test-cluster
+  cluster:
+    server: ` + tt.server
+
+			endpoint, err := ExtractEndpoint(kubeConfig, "test-cluster")
+			if err == nil {
+				t.Errorf("was expecting an error, got %+v", endpoint)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package nodeimage
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Repository is the image repository the official Kind node images are published to
+const Repository = "kindest/node"
+
+// digests maps each Kubernetes version to the digest of the kindest/node
+// image built for the vendored version of Kind (v0.11.1)
+//
+// See https://github.com/kubernetes-sigs/kind/releases/tag/v0.11.1
+var digests = map[string]string{
+	"v1.21.2":  "sha256:9d07ff05e4afefbba983fac311807b3c17a5f36e7061f6cb7e2ba756255b2be4",
+	"v1.21.1":  "sha256:69860bda5563ac81e3c0057d654b5253219618a22ec3a346306239bba8cfa1a6",
+	"v1.20.7":  "sha256:cbeaf907fc78ac97ce7b625e4bf0de16e3ea725daf6b04f930bd14c67c671ff9",
+	"v1.19.11": "sha256:07db187ae84b4b7de440a73886f008cf903fcf5764ba8106a9fd5243d6f32729",
+	"v1.18.19": "sha256:7af1492e19b3192a79f606e43c35fb741e520d195f96399284515f077b3b622c",
+	"v1.17.17": "sha256:66f1d0d91a88b8a001811e2f1054af60eef3b669a9a74f9b6db871f2f1eeed00",
+	"v1.16.15": "sha256:83067ed51bf2a3395b24687094e283a7c7c865ccc12a8b1d7aa673ba0c5e8861",
+	"v1.15.12": "sha256:b920920e1eda689d9936dfcf7332701e80be12566999152626b2c9d730397a95",
+	"v1.14.10": "sha256:f8a66ef82822ab4f7569e91a5bccaf27bceee135c1457c512e54de8c6f7219f8",
+}
+
+// IsSupported checks if a kindest/node image is available for the given Kubernetes version
+func IsSupported(version string) bool {
+	_, ok := digests[version]
+	return ok
+}
+
+// SupportedVersions returns the Kubernetes versions with an available kindest/node image
+func SupportedVersions() []string {
+	versions := []string{}
+	for version := range digests {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// Resolve returns the full image reference to use for the given image and Kubernetes version,
+// pinning the official node images by digest
+func Resolve(image, version string) string {
+	ref := fmt.Sprintf("%s:%s", image, version)
+	if digest, ok := digests[version]; ok && image == Repository {
+		ref = fmt.Sprintf("%s@%s", ref, digest)
+	}
+	return ref
+}
@@ -0,0 +1,36 @@
+package nodeimage
+
+import (
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		image   string
+		version string
+		want    string
+	}{
+		{
+			image:   "kindest/node",
+			version: "v1.21.1",
+			want:    "kindest/node:v1.21.1@sha256:69860bda5563ac81e3c0057d654b5253219618a22ec3a346306239bba8cfa1a6",
+		},
+		{
+			image:   "kindest/node",
+			version: "v1.99.0",
+			want:    "kindest/node:v1.99.0",
+		},
+		{
+			image:   "example.com/custom/node",
+			version: "v1.21.1",
+			want:    "example.com/custom/node:v1.21.1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := Resolve(tt.image, tt.version); got != tt.want {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ClusterNameKey is the attribute holding the name of the Kind cluster a span relates to
+const ClusterNameKey = attribute.Key("capk.cluster.name")
+
+// Exporter is where finished spans are sent
+type Exporter string
+
+const (
+	// ExporterNone disables tracing
+	ExporterNone Exporter = ""
+	// ExporterOTLP sends spans to an OpenTelemetry collector over OTLP/HTTP
+	ExporterOTLP Exporter = "otlp"
+	// ExporterStdout writes spans to stdout, useful for local testing
+	ExporterStdout Exporter = "stdout"
+)
+
+// Options contains the tracing configuration
+type Options struct {
+	// Exporter is where spans are sent, tracing is disabled if not set
+	Exporter Exporter
+	// Endpoint is the URL of the OTLP collector, e.g. http://localhost:4318. Defaults to the standard
+	// OTEL_EXPORTER_OTLP_ENDPOINT environment variable or https://localhost:4318.
+	Endpoint string
+	// ServiceName identifies the process in traces
+	ServiceName string
+}
+
+// Setup configures the global tracer provider and trace context propagation, returning a func that flushes any
+// remaining spans on shutdown
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	if opts.Exporter == ExporterNone {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch opts.Exporter {
+	case ExporterOTLP:
+		var exporterOpts []otlptracehttp.Option
+		exporterOpts, err = endpointOptions(opts.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		exporter, err = otlptracehttp.New(ctx, exporterOpts...)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q, must be %q or %q", opts.Exporter, ExporterOTLP, ExporterStdout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %w", opts.Exporter, err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(opts.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
+
+// endpointOptions converts the collector URL into OTLP exporter options
+func endpointOptions(endpoint string) ([]otlptracehttp.Option, error) {
+	if endpoint == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid tracing endpoint %q, must be a URL such as http://localhost:4318", endpoint)
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(u.Host)}
+	if u.Scheme == "http" {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if u.Path != "" && u.Path != "/" {
+		opts = append(opts, otlptracehttp.WithURLPath(u.Path))
+	}
+	return opts, nil
+}
+
+// StartSpan starts a span as a child of any span in the context, returning the span's context and a func that
+// ends the span, recording the error if there is one
+//
+// The tracer is looked up from the global provider each time, rather than held by the caller, so spans go to
+// whichever provider is currently set.
+func StartSpan(ctx context.Context, instrumentation, name string, opts ...trace.SpanStartOption) (context.Context, func(err error)) {
+	ctx, span := otel.Tracer(instrumentation).Start(ctx, name, opts...)
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
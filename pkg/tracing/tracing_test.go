@@ -0,0 +1,106 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestStartSpan(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status codes.Code
+		events int
+	}{
+		{
+			name:   "success",
+			status: codes.Unset,
+		},
+		{
+			name:   "failure",
+			err:    errors.New("boom"),
+			status: codes.Error,
+			events: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			previous := otel.GetTracerProvider()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+			defer otel.SetTracerProvider(previous)
+
+			ctx, endParent := StartSpan(context.Background(), "test", "parent")
+			_, end := StartSpan(ctx, "test", "child")
+			end(tc.err)
+			endParent(nil)
+
+			spans := recorder.Ended()
+			if len(spans) != 2 {
+				t.Fatalf("unexpected result - wanted %+v, got %+v", 2, len(spans))
+			}
+			child, parent := spans[0], spans[1]
+			if child.Parent().SpanID() != parent.SpanContext().SpanID() {
+				t.Errorf("unexpected result - wanted %+v, got %+v", parent.SpanContext().SpanID(), child.Parent().SpanID())
+			}
+			if child.Status().Code != tc.status {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tc.status, child.Status().Code)
+			}
+			if len(child.Events()) != tc.events {
+				t.Errorf("unexpected result - wanted %+v, got %+v", tc.events, len(child.Events()))
+			}
+		})
+	}
+}
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		{
+			name: "disabled",
+			opts: Options{},
+		},
+		{
+			name: "stdout",
+			opts: Options{Exporter: ExporterStdout, ServiceName: "test"},
+		},
+		{
+			name: "otlp",
+			opts: Options{Exporter: ExporterOTLP, Endpoint: "http://localhost:4318", ServiceName: "test"},
+		},
+		{
+			name:    "invalid endpoint",
+			opts:    Options{Exporter: ExporterOTLP, Endpoint: "localhost"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown exporter",
+			opts:    Options{Exporter: "jaeger"},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			shutdown, err := Setup(context.Background(), tc.opts)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("unexpected result - wanted error %+v, got %+v", tc.wantErr, err)
+			}
+			if err == nil {
+				if err := shutdown(context.Background()); err != nil {
+					t.Errorf("unexpected error - %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,5 @@
+package version
+
+// Version is the version of cluster-api-provider-kind, set at build time with
+// `-ldflags "-X github.com/AverageMarcus/cluster-api-provider-kind/pkg/version.Version=<version>"`
+var Version = "dev"